@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/gpt"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/chat"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/eventbus"
+	"github.com/jmoiron/sqlx"
+)
+
+// runCLI 在终端中与机器人对话，用于本地调试prompt及人设：bot cli -memory -user alice -lang en
+func runCLI(opt *Option, log *logger.Helper, args []string) {
+	fs := flag.NewFlagSet("cli", flag.ExitOnError)
+	memory := fs.Bool("memory", false, "keep chats in memory instead of MySQL")
+	user := fs.String("user", "local", "cli user id")
+	lang := fs.String("lang", os.Getenv("LANG"), "reply language, e.g. en or zh")
+	_ = fs.Parse(args)
+
+	eb := mediator.NewInMemMediator(1)
+	eventbus.SetDefault(eb)
+
+	var db *sqlx.DB
+	if !*memory {
+		db = mysql.NewMySQLDriver(opt.MySQL)
+		defer db.Close()
+	}
+	repl := chat.NewCLI(opt.Chat, log, db, eb, gpt.NewChatGPT(opt.ChatGPT), *user, *lang, os.Stdin, os.Stdout)
+
+	ctx, stop := signal.NotifyContext(context.RootContext(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := repl.Run(ctx); err != nil {
+		log.Error("failed to read from stdin", "error", err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/gpt"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/chat"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+// runIngest 将markdown目录导入知识库：bot ingest -dir ./runbooks
+func runIngest(opt *Option, log *logger.Helper, args []string) {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	dir := fs.String("dir", "", "directory of markdown files")
+	_ = fs.Parse(args)
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db := mysql.NewMySQLDriver(opt.MySQL)
+	defer db.Close()
+	ka := chat.NewKnowledgeApplication(opt.Chat, db, gpt.NewChatGPT(opt.ChatGPT))
+
+	total, err := ka.Ingest(context.RootContext(), log, *dir)
+	if err != nil {
+		log.Error("failed to ingest knowledge base", "dir", *dir, "error", err.Error())
+		os.Exit(1)
+	}
+	log.Info("knowledge base ingested", "dir", *dir, "chunks", total)
+}
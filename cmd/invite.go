@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/chat"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+// runInvite 生成一次性邀请码：bot invite -n 5
+func runInvite(opt *Option, log *logger.Helper, args []string) {
+	fs := flag.NewFlagSet("invite", flag.ExitOnError)
+	n := fs.Int("n", 1, "number of invitation codes")
+	_ = fs.Parse(args)
+	if *n <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db := mysql.NewMySQLDriver(opt.MySQL)
+	defer db.Close()
+	aa := chat.NewAccessApplication(opt.Chat, db)
+
+	codes, err := aa.Invite(context.RootContext(), log, *n)
+	for _, code := range codes {
+		fmt.Println(code)
+	}
+	if err != nil {
+		log.Error("failed to generate invitations", "error", err.Error())
+		os.Exit(1)
+	}
+}
@@ -7,11 +7,17 @@ import (
 
 	"github.com/go-jimu/components/logger"
 	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/discord"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/email"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/feishu"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/gpt"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/matrix"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/slack"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/telegram"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wechat"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wecom"
 	"github.com/jacexh/chatgpt-bot/internal/chat"
 	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
 	"github.com/jacexh/chatgpt-bot/internal/pkg/eventbus"
@@ -27,6 +33,13 @@ type Option struct {
 	Telegram   telegram.Option `json:"telegram" yaml:"telegram"`
 	ChatGPT    gpt.Option      `json:"chatgpt" yaml:"chatgpt"`
 	Wechat     wechat.Option   `json:"wechat" yaml:"wechat"`
+	Slack      slack.Option    `json:"slack" yaml:"slack"`
+	Discord    discord.Option  `json:"discord" yaml:"discord"`
+	Feishu     feishu.Option   `json:"feishu" yaml:"feishu"`
+	Wecom      wecom.Option    `json:"wecom" yaml:"wecom"`
+	Matrix     matrix.Option   `json:"matrix" yaml:"matrix"`
+	Email      email.Option    `json:"email" yaml:"email"`
+	Chat       chat.Option     `json:"chat" yaml:"chat"`
 }
 
 func main() {
@@ -42,6 +55,25 @@ func main() {
 
 	context.New(opt.Context)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cli":
+			runCLI(opt, log, os.Args[2:])
+		case "ingest":
+			runIngest(opt, log, os.Args[2:])
+		case "invite":
+			runInvite(opt, log, os.Args[2:])
+		case "quota":
+			runQuota(opt, log, os.Args[2:])
+		case "report":
+			runReport(opt, log, os.Args[2:])
+		default:
+			log.Errorf("unknown command: %s", os.Args[1])
+			os.Exit(2)
+		}
+		return
+	}
+
 	// eventbus layer
 	eb := mediator.NewInMemMediator(1)
 	eventbus.SetDefault(eb)
@@ -52,9 +84,15 @@ func main() {
 	bot := telegram.NewBotAPI(opt.Telegram, log)
 	gpt := gpt.NewChatGPT(opt.ChatGPT)
 	wc := wechat.NewWechatClient(opt.Wechat)
+	sc := slack.NewClient(opt.Slack, log)
+	dc := discord.NewClient(opt.Discord, log)
+	fc := feishu.NewClient(opt.Feishu, log)
+	wec := wecom.NewClient(opt.Wecom, log)
+	mc := matrix.NewClient(opt.Matrix, log)
+	ec := email.NewClient(opt.Email, log)
 
 	// each business layer
-	chat.Init(log, db, cg, eb, bot, gpt, wc)
+	chat.Init(opt.Chat, log, db, cg, eb, bot, gpt, wc, sc, dc, fc, wec, mc, ec)
 
 	// graceful shutdown
 	ctx, stop := signal.NotifyContext(context.RootContext(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
@@ -63,6 +101,9 @@ func main() {
 	if err := cg.Serve(ctx); err != nil {
 		log.Error("failed to shutdown http server", "error", err.Error())
 	}
+	if !context.StopWorkers() {
+		log.Warn("background workers did not stop in time")
+	}
 	log.Warnf("kill all available contexts in %s", opt.Context.ShutdownTimeout)
 	context.KillContextAfterTimeout()
 	log.Info("bye")
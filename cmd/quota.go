@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/chat"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+// runQuota 重置或调整用户额度：bot quota -channel telegram -user 123 -reset
+// bot quota -channel telegram -user 123 -daily 100000 -monthly 2000000
+func runQuota(opt *Option, log *logger.Helper, args []string) {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	channel := fs.String("channel", "", "channel name, e.g. telegram")
+	user := fs.String("user", "", "channel user id")
+	reset := fs.Bool("reset", false, "reset the usage of current periods")
+	daily := fs.Int64("daily", -1, "daily token limit, 0 means unlimited")
+	monthly := fs.Int64("monthly", -1, "monthly token limit, 0 means unlimited")
+	_ = fs.Parse(args)
+
+	ch, err := domain.ParseChannel(*channel)
+	if err != nil || *user == "" || (!*reset && *daily < 0 && *monthly < 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	from := domain.From{Channel: ch, ChannelUserID: domain.ChannelUserID(*user)}
+
+	db := mysql.NewMySQLDriver(opt.MySQL)
+	defer db.Close()
+	qa := chat.NewQuotaApplication(opt.Chat, db)
+	ctx := context.RootContext()
+
+	if *reset {
+		if err := qa.Reset(ctx, log, from); err != nil {
+			os.Exit(1)
+		}
+	}
+	if *daily >= 0 || *monthly >= 0 {
+		current, err := qa.Usage(ctx, log, from)
+		if err != nil {
+			os.Exit(1)
+		}
+		limit := domain.QuotaLimit{Daily: current.DailyLimit, Monthly: current.MonthlyLimit}
+		if *daily >= 0 {
+			limit.Daily = *daily
+		}
+		if *monthly >= 0 {
+			limit.Monthly = *monthly
+		}
+		if err := qa.Raise(ctx, log, from, limit); err != nil {
+			os.Exit(1)
+		}
+	}
+}
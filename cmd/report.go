@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/mysql"
+	"github.com/jacexh/chatgpt-bot/internal/chat"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+// runReport 输出用量及费用报表：bot report -from 2023-06-01 -to 2023-06-30 -group-by model -format csv
+func runReport(opt *Option, log *logger.Helper, args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	from := fs.String("from", "", "start time, YYYY-MM-DD or RFC3339, defaults to the first day of this month")
+	to := fs.String("to", "", "end time, YYYY-MM-DD or RFC3339, defaults to now")
+	groupBy := fs.String("group-by", "user", "user|channel|model")
+	format := fs.String("format", "json", "json|csv")
+	_ = fs.Parse(args)
+
+	period, err := domain.ParseReportPeriod(*from, *to, time.Now())
+	if err != nil {
+		log.Error("invalid period", "error", err.Error())
+		os.Exit(2)
+	}
+	dimension, err := domain.ParseReportDimension(*groupBy)
+	if err != nil || (*format != "json" && *format != "csv") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db := mysql.NewMySQLDriver(opt.MySQL)
+	defer db.Close()
+	ra := chat.NewReportApplication(opt.Chat, db)
+
+	report, err := ra.UsageReport(context.RootContext(), log, period, dimension)
+	if err != nil {
+		os.Exit(1)
+	}
+	if *format == "csv" {
+		err = report.WriteCSV(os.Stdout)
+	} else {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		log.Error("failed to write report", "error", err.Error())
+		os.Exit(1)
+	}
+}
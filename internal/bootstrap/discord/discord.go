@@ -0,0 +1,166 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+)
+
+type (
+	// Option public_key为开发者后台中应用的公钥(十六进制)，配置bot_token时启动时注册斜杠命令
+	Option struct {
+		ApplicationID string `json:"application_id" yaml:"application_id"`
+		PublicKey     string `json:"public_key" yaml:"public_key"`
+		BotToken      string `json:"bot_token" yaml:"bot_token"`
+		APIURL        string `json:"api_url" yaml:"api_url"`
+	}
+
+	// Client Discord Interactions所需的最小接口实现
+	Client struct {
+		applicationID string
+		publicKey     ed25519.PublicKey
+		botToken      string
+		apiURL        string
+		http          *http.Client
+	}
+
+	// Command DescriptionLocalizations的key为Discord的语言代码，例如en-US、zh-CN
+	Command struct {
+		Name                     string            `json:"name"`
+		Description              string            `json:"description"`
+		DescriptionLocalizations map[string]string `json:"description_localizations,omitempty"`
+		Options                  []CommandOption   `json:"options,omitempty"`
+	}
+
+	CommandOption struct {
+		Type                     int               `json:"type"`
+		Name                     string            `json:"name"`
+		Description              string            `json:"description"`
+		DescriptionLocalizations map[string]string `json:"description_localizations,omitempty"`
+		Required                 bool              `json:"required"`
+	}
+)
+
+const (
+	DefaultAPIURL = "https://discord.com/api/v10"
+
+	SignatureHeader = "X-Signature-Ed25519"
+	TimestampHeader = "X-Signature-Timestamp"
+	// 拒绝时间戳偏差超过5分钟的请求以防重放
+	signatureTolerance = 5 * time.Minute
+
+	// MessageLimit 单条消息的最大字符数
+	MessageLimit = 2000
+
+	OptionTypeString = 3
+)
+
+var ErrInvalidSignature = errors.New("invalid discord signature")
+
+// NewClient 未配置application_id时返回nil，表示不启用Discord通道
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.ApplicationID == "" {
+		return nil
+	}
+	c, err := New(opt, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		panic(err)
+	}
+	logger.NewHelper(log).Info(fmt.Sprintf("discord application id: %s", opt.ApplicationID))
+	return c
+}
+
+func New(opt Option, client *http.Client) (*Client, error) {
+	key, err := hex.DecodeString(opt.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid discord public key")
+	}
+	apiURL := opt.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{
+		applicationID: opt.ApplicationID,
+		publicKey:     key,
+		botToken:      opt.BotToken,
+		apiURL:        strings.TrimSuffix(apiURL, "/"),
+		http:          client,
+	}, nil
+}
+
+// Verify 校验Interactions请求: Ed25519(timestamp + body)
+func (c *Client) Verify(header http.Header, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > signatureTolerance || d < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+	msg := append([]byte(header.Get(TimestampHeader)), body...)
+	if !ed25519.Verify(c.publicKey, msg, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// RegisterCommands 覆盖应用的全局斜杠命令，未配置bot_token时跳过
+func (c *Client) RegisterCommands(ctx context.Context, commands []Command) error {
+	if c.botToken == "" {
+		return nil
+	}
+	return c.do(ctx, http.MethodPut, "/applications/"+c.applicationID+"/commands", commands, true)
+}
+
+// EditOriginal 完成延迟响应，替换"思考中"的占位消息
+func (c *Client) EditOriginal(ctx context.Context, token, content string) error {
+	return c.do(ctx, http.MethodPatch, "/webhooks/"+c.applicationID+"/"+token+"/messages/@original",
+		map[string]string{"content": content}, false)
+}
+
+// CreateFollowup 在原始响应之后追加消息
+func (c *Client) CreateFollowup(ctx context.Context, token, content string) error {
+	return c.do(ctx, http.MethodPost, "/webhooks/"+c.applicationID+"/"+token,
+		map[string]string{"content": content}, false)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, auth bool) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth {
+		req.Header.Set("Authorization", "Bot "+c.botToken)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord %s %s: status %d: %s", method, strings.SplitN(path, "/", 3)[1], resp.StatusCode, msg)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
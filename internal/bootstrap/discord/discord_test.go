@@ -0,0 +1,75 @@
+package discord_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/discord"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	c, err := discord.New(discord.Option{ApplicationID: "1", PublicKey: hex.EncodeToString(pub)}, http.DefaultClient)
+	assert.NoError(t, err)
+
+	body := []byte(`{"type":1}`)
+	header := http.Header{}
+	header.Set(discord.TimestampHeader, "1686355200")
+	header.Set(discord.SignatureHeader, hex.EncodeToString(ed25519.Sign(priv, append([]byte("1686355200"), body...))))
+	now := time.Unix(1686355200, 0)
+	assert.NoError(t, c.Verify(header, body, now))
+	assert.ErrorIs(t, c.Verify(header, []byte(`{"type":2}`), now), discord.ErrInvalidSignature)
+	// 超过5分钟的请求视为重放
+	assert.ErrorIs(t, c.Verify(header, body, now.Add(6*time.Minute)), discord.ErrInvalidSignature)
+
+	header.Set(discord.TimestampHeader, "1686355201")
+	assert.ErrorIs(t, c.Verify(header, body, now), discord.ErrInvalidSignature)
+	header.Set(discord.SignatureHeader, "zz")
+	assert.ErrorIs(t, c.Verify(header, body, now), discord.ErrInvalidSignature)
+
+	_, err = discord.New(discord.Option{PublicKey: "abc"}, http.DefaultClient)
+	assert.Error(t, err)
+}
+
+func TestWebhooks(t *testing.T) {
+	type call struct{ method, path, auth, body string }
+	var calls []call
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, call{r.Method, r.URL.Path, r.Header.Get("Authorization"), string(body)})
+		if r.URL.Path == "/webhooks/1/expired" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Unknown Webhook"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	pub, _, _ := ed25519.GenerateKey(nil)
+	c, err := discord.New(discord.Option{ApplicationID: "1", PublicKey: hex.EncodeToString(pub), BotToken: "bot", APIURL: srv.URL}, srv.Client())
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, c.RegisterCommands(ctx, []discord.Command{{Name: "reset", Description: "reset"}}))
+	assert.NoError(t, c.EditOriginal(ctx, "tok", "hello"))
+	assert.NoError(t, c.CreateFollowup(ctx, "tok", "world"))
+	err = c.CreateFollowup(ctx, "expired", "world")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "expired")
+
+	assert.Equal(t, []call{
+		{http.MethodPut, "/applications/1/commands", "Bot bot", `[{"name":"reset","description":"reset"}]`},
+		{http.MethodPatch, "/webhooks/1/tok/messages/@original", "", `{"content":"hello"}`},
+		{http.MethodPost, "/webhooks/1/tok", "", `{"content":"world"}`},
+		{http.MethodPost, "/webhooks/1/expired", "", `{"content":"world"}`},
+	}, calls)
+}
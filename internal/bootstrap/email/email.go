@@ -0,0 +1,202 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+)
+
+type (
+	// Option imap_tls为true时使用993端口的隐式TLS；smtp_security可选starttls(587端口)、tls(465端口)或none，
+	// address为发件地址，默认与username相同
+	Option struct {
+		IMAPAddr     string `json:"imap_addr" yaml:"imap_addr"`
+		IMAPTLS      bool   `json:"imap_tls" yaml:"imap_tls"`
+		SMTPAddr     string `json:"smtp_addr" yaml:"smtp_addr"`
+		SMTPSecurity string `json:"smtp_security" yaml:"smtp_security"`
+		Username     string `json:"username" yaml:"username"`
+		Password     string `json:"password" yaml:"password"`
+		Address      string `json:"address" yaml:"address"`
+		Mailbox      string `json:"mailbox" yaml:"mailbox"`
+		PollInterval string `json:"poll_interval" yaml:"poll_interval"`
+	}
+
+	// Client 定期拉取收件箱中的未读邮件，通过SMTP回复
+	Client struct {
+		opt          Option
+		PollInterval time.Duration
+	}
+)
+
+const (
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+)
+
+// NewClient 未配置imap_addr时返回nil，表示不启用邮件通道
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.IMAPAddr == "" {
+		return nil
+	}
+	c, err := New(opt)
+	if err != nil {
+		panic(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := c.login(ctx)
+	if err != nil {
+		panic(err)
+	}
+	_ = conn.Logout()
+	logger.NewHelper(log).Info(fmt.Sprintf("email channel address: %s", c.opt.Address))
+	return c
+}
+
+func New(opt Option) (*Client, error) {
+	if opt.Address == "" {
+		opt.Address = opt.Username
+	}
+	if opt.Mailbox == "" {
+		opt.Mailbox = "INBOX"
+	}
+	switch opt.SMTPSecurity {
+	case "":
+		opt.SMTPSecurity = SecurityStartTLS
+	case SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown smtp_security %q", opt.SMTPSecurity)
+	}
+	interval := time.Minute
+	if opt.PollInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(opt.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{opt: opt, PollInterval: interval}, nil
+}
+
+// Address 发件地址，收到自己发出的邮件时应忽略
+func (c *Client) Address() string {
+	return c.opt.Address
+}
+
+// Poll 逐封处理未读邮件，handle返回nil后标记为已读，失败的邮件在下次拉取时重试；
+// 无法解析的邮件直接标记为已读
+func (c *Client) Poll(ctx context.Context, handle func(*Message) error) error {
+	conn, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Logout()
+
+	uids, err := conn.SearchUnseen()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			break
+		}
+		raw, err := conn.Fetch(uid)
+		if err != nil {
+			return err
+		}
+		msg, err := Parse(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("message %d: %w", uid, err))
+		} else if err = handle(msg); err != nil {
+			errs = append(errs, fmt.Errorf("message %d: %w", uid, err))
+			continue
+		}
+		if err = conn.MarkSeen(uid); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reply 回复邮件，保持在原邮件的线程中
+func (c *Client) Reply(ctx context.Context, target ReplyTarget, text string) error {
+	return c.send(ctx, target.To, ComposeReply(c.opt.Address, target, text, time.Now()))
+}
+
+func (c *Client) login(ctx context.Context) (*imapConn, error) {
+	conn, err := dialIMAP(ctx, c.opt.IMAPAddr, c.opt.IMAPTLS)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.Login(c.opt.Username, c.opt.Password); err != nil {
+		conn.conn.Close()
+		return nil, err
+	}
+	if err = conn.Select(c.opt.Mailbox); err != nil {
+		_ = conn.Logout()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) send(ctx context.Context, to string, msg []byte) error {
+	host, _, err := net.SplitHostPort(c.opt.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if c.opt.SMTPSecurity == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.opt.SMTPAddr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.opt.SMTPAddr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if c.opt.SMTPSecurity == SecurityStartTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if c.opt.Password != "" {
+		if err = client.Auth(smtp.PlainAuth("", c.opt.Username, c.opt.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(c.opt.Address); err != nil {
+		return err
+	}
+	if err = client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = bytes.NewReader(msg).WriteTo(w); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,223 @@
+package email_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/email"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// fakeIMAP 进程内的IMAP服务，仅实现Client用到的命令
+	fakeIMAP struct {
+		ln       net.Listener
+		mu       sync.Mutex
+		messages map[uint32]string
+		seen     map[uint32]bool
+	}
+
+	// fakeSMTP 进程内的SMTP服务，记录收到的邮件
+	fakeSMTP struct {
+		ln   net.Listener
+		mu   sync.Mutex
+		rcpt []string
+		data []string
+	}
+)
+
+func newFakeIMAP(t *testing.T, messages map[uint32]string) *fakeIMAP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &fakeIMAP{ln: ln, messages: messages, seen: make(map[uint32]bool)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeIMAP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		fields := strings.Fields(cmd)
+		s.mu.Lock()
+		switch {
+		case fields[0] == "LOGIN":
+			if cmd != `LOGIN "bot@example.org" "p\"w"` {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] invalid credentials\r\n", tag)
+				s.mu.Unlock()
+				continue
+			}
+		case fields[0] == "SELECT":
+			fmt.Fprintf(conn, "* %d EXISTS\r\n", len(s.messages))
+		case cmd == "UID SEARCH UNSEEN":
+			var uids []string
+			for uid := range s.messages {
+				if !s.seen[uid] {
+					uids = append(uids, strconv.Itoa(int(uid)))
+				}
+			}
+			sort.Strings(uids)
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case fields[0] == "UID" && fields[1] == "FETCH":
+			uid, _ := strconv.Atoi(fields[2])
+			raw := s.messages[uint32(uid)]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", uid, len(raw), raw)
+		case fields[0] == "UID" && fields[1] == "STORE":
+			uid, _ := strconv.Atoi(fields[2])
+			s.seen[uint32(uid)] = true
+		case fields[0] == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			s.mu.Unlock()
+			return
+		}
+		fmt.Fprintf(conn, "%s OK completed\r\n", tag)
+		s.mu.Unlock()
+	}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line)[0])
+		switch verb {
+		case "EHLO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			_ = tp.PrintfLine("235 authenticated")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, line)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, _ := tp.ReadDotBytes()
+			s.mu.Lock()
+			s.data = append(s.data, string(data))
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestPollAndReply(t *testing.T) {
+	imap := newFakeIMAP(t, map[uint32]string{
+		1: "From: Alice <Alice@example.org>\r\nTo: bot@example.org\r\nSubject: =?UTF-8?B?5L2g5aW9?=\r\n" +
+			"Message-ID: <r2@example.org>\r\nIn-Reply-To: <r1@example.org>\r\nReferences: <root@example.org> <r1@example.org>\r\n" +
+			"Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+			"=E4=BD=A0=E5=A5=BD\r\n\r\n> quoted\r\n",
+		2: "From: bob@example.org\r\nSubject: html\r\nMessage-ID: <h1@example.org>\r\n" +
+			"Content-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+			"--b1\r\nContent-Type: text/html; charset=gbk\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+			"PHA+xOO6wzwvcD48YmxvY2txdW90ZT5vbGQ8L2Jsb2NrcXVvdGU+\r\n--b1--\r\n",
+		3: "Subject: broken\r\n\r\nno from header\r\n",
+		4: "From: carol@example.org\r\nMessage-ID: <c1@example.org>\r\nAuto-Submitted: auto-replied\r\n\r\nout of office\r\n",
+	})
+	defer imap.ln.Close()
+	smtpSrv := newFakeSMTP(t)
+	defer smtpSrv.ln.Close()
+
+	c, err := email.New(email.Option{
+		IMAPAddr:     imap.ln.Addr().String(),
+		SMTPAddr:     smtpSrv.ln.Addr().String(),
+		SMTPSecurity: email.SecurityNone,
+		Username:     "bot@example.org",
+		Password:     `p"w`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, c.PollInterval)
+
+	ctx := context.Background()
+	var got []*email.Message
+	err = c.Poll(ctx, func(msg *email.Message) error {
+		got = append(got, msg)
+		if msg.From == "carol@example.org" {
+			return errors.New("try again later")
+		}
+		return nil
+	})
+	// 无法解析及处理失败的邮件会返回错误
+	assert.ErrorContains(t, err, "message 3: invalid from address")
+	assert.ErrorContains(t, err, "message 4: try again later")
+	assert.Equal(t, map[uint32]bool{1: true, 2: true, 3: true}, imap.seen)
+
+	assert.Len(t, got, 3)
+	assert.Equal(t, &email.Message{
+		MessageID:  "r2@example.org",
+		InReplyTo:  "r1@example.org",
+		References: []string{"root@example.org", "r1@example.org"},
+		From:       "alice@example.org",
+		Subject:    "你好",
+		Text:       "你好\n\n> quoted\n",
+	}, got[0])
+	assert.Equal(t, "你好", got[1].Text)
+	assert.True(t, got[2].Automated)
+
+	assert.NoError(t, c.Reply(ctx, got[0].ReplyTarget(), "回复内容"))
+	assert.Equal(t, []string{"RCPT TO:<alice@example.org>"}, smtpSrv.rcpt)
+	reply, err := email.Parse([]byte(smtpSrv.data[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, "r2@example.org", reply.InReplyTo)
+	assert.Equal(t, []string{"root@example.org", "r1@example.org", "r2@example.org"}, reply.References)
+	assert.Equal(t, "Re: 你好", reply.Subject)
+	assert.Equal(t, "bot@example.org", reply.From)
+	assert.Equal(t, "回复内容", strings.TrimSpace(reply.Text))
+	assert.True(t, reply.Automated)
+
+	bad, err := email.New(email.Option{IMAPAddr: imap.ln.Addr().String(), Username: "bot@example.org", Password: "wrong"})
+	assert.NoError(t, err)
+	err = bad.Poll(ctx, func(*email.Message) error { return nil })
+	assert.EqualError(t, err, "imap LOGIN: NO [AUTHENTICATIONFAILED] invalid credentials")
+}
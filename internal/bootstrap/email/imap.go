@@ -0,0 +1,198 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// imapConn IMAP4rev1的最小实现，仅支持机器人用到的命令
+	imapConn struct {
+		conn net.Conn
+		r    *bufio.Reader
+		tag  int
+	}
+
+	// imapResponse 一条untagged响应，literals为其中按顺序出现的字面量
+	imapResponse struct {
+		line     string
+		literals [][]byte
+	}
+)
+
+// 单封邮件的上限，超出时放弃该连接
+const maxLiteral = 25 << 20
+
+func dialIMAP(ctx context.Context, addr string, useTLS bool) (*imapConn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected imap greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapConn) Login(username, password string) error {
+	_, err := c.command("LOGIN " + quote(username) + " " + quote(password))
+	return err
+}
+
+func (c *imapConn) Select(mailbox string) error {
+	_, err := c.command("SELECT " + quote(mailbox))
+	return err
+}
+
+// SearchUnseen 返回未读邮件的UID
+func (c *imapConn) SearchUnseen() ([]uint32, error) {
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, resp := range responses {
+		fields := strings.Fields(resp.line)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, f := range fields[2:] {
+			uid, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid uid in search response: %s", f)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+// Fetch 获取邮件原文，使用BODY.PEEK避免在处理完成前被标记为已读
+func (c *imapConn) Fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if strings.Contains(strings.ToUpper(resp.line), " FETCH ") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("message %d not found", uid)
+}
+
+func (c *imapConn) MarkSeen(uid uint32) error {
+	_, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+func (c *imapConn) Logout() error {
+	defer c.conn.Close()
+	_, err := c.command("LOGOUT")
+	return err
+}
+
+func (c *imapConn) command(cmd string) ([]*imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var responses []*imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(resp.line, tag+" ") {
+			responses = append(responses, resp)
+			continue
+		}
+		status := strings.TrimPrefix(resp.line, tag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			// 避免在错误中暴露LOGIN命令的密码
+			name, _, _ := strings.Cut(cmd, " ")
+			return nil, fmt.Errorf("imap %s: %s", name, status)
+		}
+		return responses, nil
+	}
+}
+
+// readResponse 读取一条完整的响应，行尾为{n}时随后是n字节的字面量
+func (c *imapConn) readResponse() (*imapResponse, error) {
+	resp := new(imapResponse)
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		resp.line += line
+		n, ok := literalSize(line)
+		if !ok {
+			return resp, nil
+		}
+		if n > maxLiteral {
+			return nil, errors.New("imap literal too large")
+		}
+		literal := make([]byte, n)
+		if _, err = io.ReadFull(c.r, literal); err != nil {
+			return nil, err
+		}
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	start := strings.LastIndexByte(line, '{')
+	if start < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[start+1 : len(line)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,250 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+type (
+	// Message 收到的邮件，Text为解码后的纯文本正文
+	Message struct {
+		MessageID  string
+		InReplyTo  string
+		References []string
+		From       string
+		Subject    string
+		Text       string
+		// 自动回复、邮件列表等不应触发prompt
+		Automated bool
+	}
+
+	// ReplyTarget 回复一封邮件所需的信息
+	ReplyTarget struct {
+		To         string
+		Subject    string
+		MessageID  string
+		References []string
+	}
+)
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// Parse 解析邮件原文，multipart邮件优先使用text/plain部分
+func Parse(raw []byte) (*Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		MessageID:  firstID(m.Header.Get("Message-Id")),
+		InReplyTo:  firstID(m.Header.Get("In-Reply-To")),
+		References: parseIDs(m.Header.Get("References")),
+		Automated:  automated(m.Header),
+	}
+	if msg.Subject, err = wordDecoder.DecodeHeader(m.Header.Get("Subject")); err != nil {
+		msg.Subject = m.Header.Get("Subject")
+	}
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	from, err := parser.Parse(m.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	msg.From = strings.ToLower(from.Address)
+
+	text, isHTML, err := readBody(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		return nil, err
+	}
+	if isHTML {
+		text = htmlText(text)
+	}
+	msg.Text = strings.ReplaceAll(text, "\r\n", "\n")
+	return msg, nil
+}
+
+// ReplyTarget References为原邮件的References加上原邮件的Message-ID
+func (m *Message) ReplyTarget() ReplyTarget {
+	subject := m.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	refs := append([]string{}, m.References...)
+	if len(refs) == 0 && m.InReplyTo != "" {
+		refs = append(refs, m.InReplyTo)
+	}
+	if m.MessageID != "" {
+		refs = append(refs, m.MessageID)
+	}
+	return ReplyTarget{To: m.From, Subject: subject, MessageID: m.MessageID, References: refs}
+}
+
+// ComposeReply 生成纯文本回复，携带In-Reply-To及References使邮件客户端归入同一线程
+func ComposeReply(from string, target ReplyTarget, text string, now time.Time) []byte {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = from[i+1:]
+	}
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", target.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", target.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", ulid.Make().String(), domain))
+	if target.MessageID != "" {
+		header("In-Reply-To", "<"+target.MessageID+">")
+	}
+	if len(target.References) > 0 {
+		refs := make([]string, len(target.References))
+		for i, ref := range target.References {
+			refs[i] = "<" + ref + ">"
+		}
+		header("References", strings.Join(refs, " "))
+	}
+	// RFC 3834，避免与对方的自动回复形成循环
+	header("Auto-Submitted", "auto-replied")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	_, _ = w.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func readBody(contentType, encoding string, body io.Reader) (string, bool, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var htmlPart string
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", false, err
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			text, isHTML, err := readBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", false, err
+			}
+			if !isHTML && text != "" {
+				return text, false, nil
+			}
+			if isHTML && htmlPart == "" {
+				htmlPart = text
+			}
+		}
+		return htmlPart, htmlPart != "", nil
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	if cs := params["charset"]; cs != "" {
+		if body, err = charset.NewReaderLabel(cs, body); err != nil {
+			return "", false, err
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxLiteral))
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), mediaType == "text/html", nil
+}
+
+// htmlText 提取html正文的文本，块级元素转为换行，引用的原邮件被忽略
+func htmlText(s string) string {
+	var buf strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(buf.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head", "blockquote":
+				skip++
+			case "br", "p", "div", "li", "tr":
+				buf.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head", "blockquote":
+				skip--
+			}
+		case html.TextToken:
+			if skip <= 0 {
+				buf.Write(z.Text())
+			}
+		}
+	}
+}
+
+func automated(h mail.Header) bool {
+	if v := strings.ToLower(h.Get("Auto-Submitted")); v != "" && v != "no" {
+		return true
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "list", "junk", "auto_reply":
+		return true
+	}
+	return h.Get("List-Id") != "" || h.Get("X-Autoreply") != ""
+}
+
+func firstID(value string) string {
+	if ids := parseIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// parseIDs 提取<...>形式的Message-ID，不含尖括号
+func parseIDs(value string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			return ids
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			return ids
+		}
+		ids = append(ids, value[start+1:start+end])
+		value = value[start+end+1:]
+	}
+}
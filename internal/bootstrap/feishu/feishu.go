@@ -0,0 +1,273 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+)
+
+type (
+	// Option api_url默认为飞书https://open.feishu.cn，Lark使用https://open.larksuite.com；
+	// 配置encrypt_key时事件以AES加密推送并校验签名
+	Option struct {
+		AppID             string `json:"app_id" yaml:"app_id"`
+		AppSecret         string `json:"app_secret" yaml:"app_secret"`
+		VerificationToken string `json:"verification_token" yaml:"verification_token"`
+		EncryptKey        string `json:"encrypt_key" yaml:"encrypt_key"`
+		APIURL            string `json:"api_url" yaml:"api_url"`
+	}
+
+	// Client 飞书开放平台的最小实现，tenant_access_token在过期前自动刷新
+	Client struct {
+		opt       Option
+		http      *http.Client
+		BotOpenID string
+
+		mu        sync.Mutex
+		token     string
+		expiresAt time.Time
+	}
+
+	response struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+)
+
+const (
+	DefaultAPIURL = "https://open.feishu.cn"
+
+	SignatureHeader = "X-Lark-Signature"
+	TimestampHeader = "X-Lark-Request-Timestamp"
+	NonceHeader     = "X-Lark-Request-Nonce"
+
+	MsgTypeText        = "text"
+	MsgTypeInteractive = "interactive"
+
+	// 提前刷新token，避免请求途中过期
+	tokenRefreshAhead = 5 * time.Minute
+	// 拒绝时间戳偏差超过5分钟的请求以防重放
+	signatureTolerance = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid feishu signature")
+	// ErrInvalidEvent 解密或解析事件失败时统一返回，不暴露具体原因
+	ErrInvalidEvent = errors.New("invalid feishu event")
+)
+
+// NewClient 未配置app_id时返回nil，表示不启用飞书通道；启用时必须配置verification_token
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.AppID == "" {
+		return nil
+	}
+	if opt.VerificationToken == "" {
+		panic(errors.New("feishu verification_token is required"))
+	}
+	c := New(opt, &http.Client{Timeout: 10 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	openID, err := c.BotInfo(ctx)
+	if err != nil {
+		panic(err)
+	}
+	c.BotOpenID = openID
+	logger.NewHelper(log).Info(fmt.Sprintf("feishu bot open id: %s", openID))
+	return c
+}
+
+func New(opt Option, client *http.Client) *Client {
+	if opt.APIURL == "" {
+		opt.APIURL = DefaultAPIURL
+	}
+	opt.APIURL = strings.TrimSuffix(opt.APIURL, "/")
+	return &Client{opt: opt, http: client}
+}
+
+// VerifyToken 校验事件中携带的verification token，未配置时拒绝所有请求
+func (c *Client) VerifyToken(token string) bool {
+	return c.opt.VerificationToken != "" && subtle.ConstantTimeCompare([]byte(c.opt.VerificationToken), []byte(token)) == 1
+}
+
+// VerifySignature 配置encrypt_key时校验sha256(timestamp + nonce + encrypt_key + body)，缺少签名视为校验失败
+func (c *Client) VerifySignature(header http.Header, body []byte, now time.Time) error {
+	if c.opt.EncryptKey == "" {
+		return nil
+	}
+	if header.Get(SignatureHeader) == "" {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > signatureTolerance || d < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+	expected := Sign(header.Get(TimestampHeader), header.Get(NonceHeader), c.opt.EncryptKey, body)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(header.Get(SignatureHeader))) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func Sign(timestamp, nonce, encryptKey string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(timestamp + nonce + encryptKey))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Decrypt 解密事件: AES-256-CBC，密钥为sha256(encrypt_key)，密文前16字节为IV
+func (c *Client) Decrypt(encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted event")
+	}
+	key := sha256.Sum256([]byte(c.opt.EncryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+	padding := int(plain[len(plain)-1])
+	if padding < 1 || padding > aes.BlockSize || padding > len(plain) {
+		return nil, errors.New("invalid padding")
+	}
+	return plain[:len(plain)-padding], nil
+}
+
+// Encrypted 是否启用了事件加密
+func (c *Client) Encrypted() bool {
+	return c.opt.EncryptKey != ""
+}
+
+// BotInfo 返回机器人的open_id
+func (c *Client) BotInfo(ctx context.Context) (string, error) {
+	var ret struct {
+		response
+		Bot struct {
+			OpenID string `json:"open_id"`
+		} `json:"bot"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/open-apis/bot/v3/info", nil, &ret); err != nil {
+		return "", err
+	}
+	return ret.Bot.OpenID, nil
+}
+
+// Reply 回复指定消息，content为对应msg_type的JSON字符串
+func (c *Client) Reply(ctx context.Context, messageID, msgType, content string) error {
+	var ret response
+	return c.call(ctx, http.MethodPost, "/open-apis/im/v1/messages/"+messageID+"/reply",
+		map[string]string{"msg_type": msgType, "content": content}, &ret)
+}
+
+// ReplyText 以纯文本回复
+func (c *Client) ReplyText(ctx context.Context, messageID, text string) error {
+	content, _ := json.Marshal(map[string]string{"text": text})
+	return c.Reply(ctx, messageID, MsgTypeText, string(content))
+}
+
+// ReplyCard 以包含markdown内容的消息卡片回复
+func (c *Client) ReplyCard(ctx context.Context, messageID, markdown string) error {
+	card := map[string]interface{}{
+		"config":   map[string]bool{"wide_screen_mode": true},
+		"elements": []map[string]string{{"tag": "markdown", "content": markdown}},
+	}
+	content, _ := json.Marshal(card)
+	return c.Reply(ctx, messageID, MsgTypeInteractive, string(content))
+}
+
+func (c *Client) tenantToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	var ret struct {
+		response
+		TenantAccessToken string `json:"tenant_access_token"`
+		Expire            int    `json:"expire"`
+	}
+	err := c.do(ctx, http.MethodPost, "/open-apis/auth/v3/tenant_access_token/internal", "",
+		map[string]string{"app_id": c.opt.AppID, "app_secret": c.opt.AppSecret}, &ret)
+	if err != nil {
+		return "", err
+	}
+	if ret.Code != 0 {
+		return "", fmt.Errorf("feishu tenant_access_token: %d %s", ret.Code, ret.Msg)
+	}
+	c.token = ret.TenantAccessToken
+	c.expiresAt = time.Now().Add(time.Duration(ret.Expire)*time.Second - tokenRefreshAhead)
+	return c.token, nil
+}
+
+func (c *Client) call(ctx context.Context, method, path string, body interface{}, ret interface{ result() response }) error {
+	token, err := c.tenantToken(ctx)
+	if err != nil {
+		return err
+	}
+	if err = c.do(ctx, method, path, token, body, ret); err != nil {
+		return err
+	}
+	if r := ret.result(); r.Code != 0 {
+		return fmt.Errorf("feishu %s: %d %s", path, r.Code, r.Msg)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, body interface{}, ret interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.opt.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 业务错误同样以非200状态码返回，响应体中的code更有参考价值
+	if err = json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		return fmt.Errorf("feishu %s: status %d: %w", path, resp.StatusCode, err)
+	}
+	return nil
+}
+
+func (r response) result() response {
+	return r
+}
@@ -0,0 +1,90 @@
+package feishu_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/feishu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecrypt(t *testing.T) {
+	// 开放平台文档中的示例
+	c := feishu.New(feishu.Option{EncryptKey: "test key"}, http.DefaultClient)
+	plain, err := c.Decrypt("P37w+VZImNgPEO1RBhJ6RtKl7n6zymIbEG1pReEzghk=")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(plain))
+
+	_, err = c.Decrypt("aGVsbG8=")
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	c := feishu.New(feishu.Option{VerificationToken: "vt", EncryptKey: "ek"}, http.DefaultClient)
+	assert.True(t, c.VerifyToken("vt"))
+	assert.False(t, c.VerifyToken("other"))
+
+	body := []byte(`{"encrypt":"abc"}`)
+	header := http.Header{}
+	header.Set(feishu.TimestampHeader, "1686355200")
+	header.Set(feishu.NonceHeader, "nonce")
+	header.Set(feishu.SignatureHeader, feishu.Sign("1686355200", "nonce", "ek", body))
+	now := time.Unix(1686355200, 0)
+	assert.NoError(t, c.VerifySignature(header, body, now))
+	assert.NoError(t, c.VerifySignature(header, body, now.Add(4*time.Minute)))
+	assert.ErrorIs(t, c.VerifySignature(header, []byte(`{}`), now), feishu.ErrInvalidSignature)
+	// 时间戳过期的请求视为重放
+	assert.ErrorIs(t, c.VerifySignature(header, body, now.Add(6*time.Minute)), feishu.ErrInvalidSignature)
+	assert.ErrorIs(t, c.VerifySignature(header, body, now.Add(-6*time.Minute)), feishu.ErrInvalidSignature)
+	header.Del(feishu.SignatureHeader)
+	assert.ErrorIs(t, c.VerifySignature(header, body, now), feishu.ErrInvalidSignature)
+
+	// 未配置verification_token时拒绝所有请求
+	assert.False(t, feishu.New(feishu.Option{}, http.DefaultClient).VerifyToken(""))
+}
+
+func TestClient(t *testing.T) {
+	var tokens int
+	var replies []map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","tenant_access_token":"t-123","expire":7200}`))
+	})
+	mux.HandleFunc("/open-apis/bot/v3/info", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer t-123", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","bot":{"open_id":"ou_bot"}}`))
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages/om_1/reply", func(w http.ResponseWriter, r *http.Request) {
+		msg := make(map[string]string)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		replies = append(replies, msg)
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok"}`))
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages/om_2/reply", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":230002,"msg":"The bot can not be outside the group."}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := feishu.New(feishu.Option{AppID: "cli_1", AppSecret: "secret", APIURL: srv.URL}, srv.Client())
+	ctx := context.Background()
+	openID, err := c.BotInfo(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "ou_bot", openID)
+
+	assert.NoError(t, c.ReplyText(ctx, "om_1", "hello"))
+	assert.NoError(t, c.ReplyCard(ctx, "om_1", "**hello**"))
+	assert.EqualError(t, c.ReplyText(ctx, "om_2", "hello"), "feishu /open-apis/im/v1/messages/om_2/reply: 230002 The bot can not be outside the group.")
+	assert.Equal(t, 1, tokens)
+
+	assert.Len(t, replies, 2)
+	assert.Equal(t, map[string]string{"msg_type": "text", "content": `{"text":"hello"}`}, replies[0])
+	assert.Equal(t, "interactive", replies[1]["msg_type"])
+	assert.JSONEq(t, `{"config":{"wide_screen_mode":true},"elements":[{"tag":"markdown","content":"**hello**"}]}`, replies[1]["content"])
+}
@@ -27,7 +27,6 @@ func (rc *rootController) Slug() string {
 
 func (rc *rootController) Middlewares() []Middleware {
 	return []Middleware{
-		{Middleware: InjectContext, Scope: ScopeGlobal},
 		{Middleware: middleware.RequestID, Scope: ScopeGlobal},
 		{Middleware: middleware.RealIP, Scope: ScopeGlobal},
 		{Middleware: CarryLog(rc.logger), Scope: ScopeGlobal},
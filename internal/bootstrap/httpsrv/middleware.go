@@ -1,7 +1,9 @@
 package httpsrv
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -69,6 +71,7 @@ func RequestLog(log logger.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// InjectContext 为请求设置默认超时，由路由按API.LongLived决定是否使用
 func InjectContext(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.GenDefaultContext()
@@ -81,3 +84,51 @@ func InjectContext(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(fn)
 }
+
+// APIKeyAuth 校验Authorization: Bearer <key>、X-API-Key请求头或Basic认证的密码，未配置任何key时拒绝所有请求。
+// 返回Basic质询以便浏览器弹出登录框，同源的后续请求会复用该凭据。
+// 浏览器会自动携带Basic凭据，因此使用Basic认证的非只读请求还须带有X-Requested-With请求头，
+// 跨站请求无法在不经CORS预检的情况下设置该头，以此防止CSRF
+func APIKeyAuth(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var basic bool
+			key := r.Header.Get("X-API-Key")
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				key = strings.TrimSpace(bearer)
+			} else if _, password, ok := r.BasicAuth(); ok {
+				key = password
+				basic = true
+			}
+			if key == "" || !matchKey(keys, key) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="chatgpt-bot admin", charset="UTF-8"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if basic && !isSafeMethod(r.Method) && r.Header.Get("X-Requested-With") == "" {
+				http.Error(w, "missing X-Requested-With header", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func matchKey(keys []string, key string) bool {
+	var matched bool
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
@@ -22,6 +22,8 @@ type (
 		Pattern string
 		Method  string
 		Func    http.HandlerFunc
+		// LongLived 长连接(如SSE)不设置默认超时，由处理函数自行控制时长
+		LongLived bool
 	}
 
 	MiddlewareScope int
@@ -95,7 +97,7 @@ func (g *router) lazyLoad() {
 	}
 
 	for _, api := range g.root.APIs() {
-		g.router.Method(api.Method, api.Pattern, api.Func)
+		g.router.Method(api.Method, api.Pattern, api.handler())
 	}
 
 	// each child controller
@@ -108,12 +110,19 @@ func (g *router) lazyLoad() {
 			}
 
 			for _, api := range controller.APIs() {
-				r.Method(api.Method, api.Pattern, api.Func)
+				r.Method(api.Method, api.Pattern, api.handler())
 			}
 		})
 	}
 }
 
+func (api API) handler() http.Handler {
+	if api.LongLived {
+		return api.Func
+	}
+	return InjectContext(api.Func)
+}
+
 func (g *router) Serve(ctx context.Context) error {
 	g.lazyLoad()
 
@@ -0,0 +1,261 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// Option 机器人账号的access_token可通过/_matrix/client/v3/login获取；
+	// auto_join为true时自动接受房间邀请。不支持端到端加密的房间
+	Option struct {
+		HomeserverURL string `json:"homeserver_url" yaml:"homeserver_url"`
+		AccessToken   string `json:"access_token" yaml:"access_token"`
+		AutoJoin      bool   `json:"auto_join" yaml:"auto_join"`
+	}
+
+	// Client Matrix client-server API的最小实现
+	Client struct {
+		opt         Option
+		http        *http.Client
+		UserID      string
+		DisplayName string
+	}
+
+	// SyncResponse /sync响应中机器人需要的部分
+	SyncResponse struct {
+		NextBatch   string `json:"next_batch"`
+		AccountData struct {
+			Events []Event `json:"events"`
+		} `json:"account_data"`
+		Rooms struct {
+			Join   map[string]JoinedRoom  `json:"join"`
+			Invite map[string]InvitedRoom `json:"invite"`
+		} `json:"rooms"`
+	}
+
+	JoinedRoom struct {
+		Summary struct {
+			JoinedMemberCount *int `json:"m.joined_member_count"`
+		} `json:"summary"`
+		Timeline struct {
+			Events []Event `json:"events"`
+		} `json:"timeline"`
+	}
+
+	InvitedRoom struct {
+		InviteState struct {
+			Events []Event `json:"events"`
+		} `json:"invite_state"`
+	}
+
+	Event struct {
+		Type     string          `json:"type"`
+		EventID  string          `json:"event_id"`
+		Sender   string          `json:"sender"`
+		StateKey *string         `json:"state_key"`
+		Content  json.RawMessage `json:"content"`
+	}
+
+	// MessageContent m.room.message事件的内容
+	MessageContent struct {
+		MsgType   string     `json:"msgtype"`
+		Body      string     `json:"body"`
+		RelatesTo *RelatesTo `json:"m.relates_to,omitempty"`
+		Mentions  *Mentions  `json:"m.mentions,omitempty"`
+	}
+
+	RelatesTo struct {
+		RelType       string     `json:"rel_type,omitempty"`
+		EventID       string     `json:"event_id,omitempty"`
+		IsFallingBack bool       `json:"is_falling_back,omitempty"`
+		InReplyTo     *InReplyTo `json:"m.in_reply_to,omitempty"`
+	}
+
+	InReplyTo struct {
+		EventID string `json:"event_id"`
+	}
+
+	Mentions struct {
+		UserIDs []string `json:"user_ids,omitempty"`
+	}
+
+	errorResponse struct {
+		ErrCode string `json:"errcode"`
+		Error   string `json:"error"`
+	}
+)
+
+const (
+	EventMessage   = "m.room.message"
+	EventEncrypted = "m.room.encrypted"
+	EventMember    = "m.room.member"
+	EventDirect    = "m.direct"
+
+	MsgTypeText = "m.text"
+
+	RelThread  = "m.thread"
+	RelReplace = "m.replace"
+
+	// SyncTimeout 长轮询的服务端等待时间，HTTP超时需大于该值
+	SyncTimeout = 30 * time.Second
+)
+
+// SyncFilter 时间线仅同步消息事件，忽略presence
+const SyncFilter = `{"presence":{"types":[]},"room":{"timeline":{"types":["m.room.message","m.room.encrypted"]},"state":{"lazy_load_members":true}}}`
+
+// NewClient 未配置homeserver_url时返回nil，表示不启用Matrix通道
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.HomeserverURL == "" {
+		return nil
+	}
+	c := New(opt, &http.Client{Timeout: SyncTimeout + 30*time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userID, err := c.Whoami(ctx)
+	if err != nil {
+		panic(err)
+	}
+	c.UserID = userID
+	// 客户端生成的提及文本通常为显示名称，获取失败时仅匹配用户ID
+	if c.DisplayName, err = c.Displayname(ctx, userID); err != nil {
+		logger.NewHelper(log).Warn("failed to get matrix display name", "error", err.Error())
+	}
+	logger.NewHelper(log).Info(fmt.Sprintf("matrix bot user id: %s", userID))
+	return c
+}
+
+func New(opt Option, client *http.Client) *Client {
+	opt.HomeserverURL = strings.TrimSuffix(opt.HomeserverURL, "/")
+	return &Client{opt: opt, http: client}
+}
+
+func (c *Client) AutoJoin() bool {
+	return c.opt.AutoJoin
+}
+
+// Whoami 返回access_token对应的用户ID
+func (c *Client) Whoami(ctx context.Context) (string, error) {
+	var ret struct {
+		UserID string `json:"user_id"`
+	}
+	err := c.do(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, nil, &ret)
+	return ret.UserID, err
+}
+
+func (c *Client) Displayname(ctx context.Context, userID string) (string, error) {
+	var ret struct {
+		Displayname string `json:"displayname"`
+	}
+	err := c.do(ctx, http.MethodGet, "/_matrix/client/v3/profile/"+url.PathEscape(userID)+"/displayname", nil, nil, &ret)
+	return ret.Displayname, err
+}
+
+// Sync since为空时返回当前状态，timeout为0时立即返回
+func (c *Client) Sync(ctx context.Context, since string, timeout time.Duration) (*SyncResponse, error) {
+	query := url.Values{"filter": {SyncFilter}, "timeout": {strconv.FormatInt(timeout.Milliseconds(), 10)}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	ret := new(SyncResponse)
+	if err := c.do(ctx, http.MethodGet, "/_matrix/client/v3/sync", query, nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *Client) JoinRoom(ctx context.Context, roomID string) error {
+	return c.do(ctx, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(roomID), nil, struct{}{}, nil)
+}
+
+// SendMessage 发送m.room.message事件，返回事件ID
+func (c *Client) SendMessage(ctx context.Context, roomID string, content *MessageContent) (string, error) {
+	var ret struct {
+		EventID string `json:"event_id"`
+	}
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/%s/%s", url.PathEscape(roomID), EventMessage, ulid.Make().String())
+	err := c.do(ctx, http.MethodPut, path, nil, content, &ret)
+	return ret.EventID, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, ret interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	link := c.opt.HomeserverURL + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, link, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.opt.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err = json.NewDecoder(resp.Body).Decode(&e); err != nil || e.ErrCode == "" {
+			return fmt.Errorf("matrix %s: status %d", path, resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, ErrCode: e.ErrCode, Message: e.Error}
+	}
+	if ret == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(ret)
+}
+
+// Error homeserver返回的标准错误
+type Error struct {
+	StatusCode int
+	ErrCode    string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("matrix %d %s: %s", e.StatusCode, e.ErrCode, e.Message)
+}
+
+// IsUnknownToken access_token失效，重试没有意义
+func IsUnknownToken(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.ErrCode == "M_UNKNOWN_TOKEN"
+}
+
+// TextReply 回复指定消息，threadRoot不为空时回复到该thread中，
+// 并按规范附带m.in_reply_to供不支持thread的客户端展示
+func TextReply(body, eventID, threadRoot string) *MessageContent {
+	content := &MessageContent{MsgType: MsgTypeText, Body: body, RelatesTo: &RelatesTo{InReplyTo: &InReplyTo{EventID: eventID}}}
+	if threadRoot != "" {
+		content.RelatesTo.RelType = RelThread
+		content.RelatesTo.EventID = threadRoot
+		content.RelatesTo.IsFallingBack = true
+	}
+	return content
+}
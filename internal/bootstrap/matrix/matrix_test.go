@@ -0,0 +1,80 @@
+package matrix_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/matrix"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextReply(t *testing.T) {
+	data, _ := json.Marshal(matrix.TextReply("hello", "$e1", ""))
+	assert.JSONEq(t, `{"msgtype":"m.text","body":"hello","m.relates_to":{"m.in_reply_to":{"event_id":"$e1"}}}`, string(data))
+
+	data, _ = json.Marshal(matrix.TextReply("hello", "$e2", "$root"))
+	assert.JSONEq(t, `{"msgtype":"m.text","body":"hello","m.relates_to":{"rel_type":"m.thread","event_id":"$root","is_falling_back":true,"m.in_reply_to":{"event_id":"$e2"}}}`, string(data))
+}
+
+func TestClient(t *testing.T) {
+	var sent []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_matrix/client/v3/account/whoami", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"user_id":"@bot:example.org"}`))
+	})
+	mux.HandleFunc("/_matrix/client/v3/sync", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "s1", r.URL.Query().Get("since"))
+		assert.Equal(t, "30000", r.URL.Query().Get("timeout"))
+		assert.Equal(t, matrix.SyncFilter, r.URL.Query().Get("filter"))
+		_, _ = w.Write([]byte(`{"next_batch":"s2","rooms":{"join":{"!r:example.org":{"summary":{"m.joined_member_count":2},
+			"timeline":{"events":[{"type":"m.room.message","event_id":"$e1","sender":"@alice:example.org","content":{"msgtype":"m.text","body":"hi"}}]}}}}}`))
+	})
+	mux.HandleFunc("/_matrix/client/v3/rooms/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.True(t, strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/rooms/!r:example.org/send/m.room.message/"))
+		var content matrix.MessageContent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&content))
+		sent = append(sent, content.Body)
+		_, _ = w.Write([]byte(`{"event_id":"$e2"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := matrix.New(matrix.Option{HomeserverURL: srv.URL + "/", AccessToken: "tok"}, srv.Client())
+	uid, err := c.Whoami(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "@bot:example.org", uid)
+
+	resp, err := c.Sync(ctx, "s1", matrix.SyncTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, "s2", resp.NextBatch)
+	room := resp.Rooms.Join["!r:example.org"]
+	assert.Equal(t, 2, *room.Summary.JoinedMemberCount)
+	assert.Len(t, room.Timeline.Events, 1)
+	assert.Equal(t, "@alice:example.org", room.Timeline.Events[0].Sender)
+
+	eventID, err := c.SendMessage(ctx, "!r:example.org", matrix.TextReply("hello", "$e1", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, "$e2", eventID)
+	assert.Equal(t, []string{"hello"}, sent)
+
+	_, err = matrix.New(matrix.Option{HomeserverURL: srv.URL, AccessToken: "bad"}, srv.Client()).Whoami(ctx)
+	assert.True(t, matrix.IsUnknownToken(err))
+	assert.NotContains(t, err.Error(), "bad")
+
+	cancelled, cancel := context.WithTimeout(ctx, time.Millisecond)
+	cancel()
+	_, err = c.Sync(cancelled, "s1", matrix.SyncTimeout)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,163 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+)
+
+type (
+	// Option api_url默认为https://slack.com/api/，测试时可指向本地的模拟服务
+	Option struct {
+		BotToken      string `json:"bot_token" yaml:"bot_token"`
+		SigningSecret string `json:"signing_secret" yaml:"signing_secret"`
+		APIURL        string `json:"api_url" yaml:"api_url"`
+	}
+
+	// Client Slack Web API的最小实现，仅包含机器人需要的接口
+	Client struct {
+		token     string
+		secret    []byte
+		apiURL    string
+		http      *http.Client
+		BotUserID string
+	}
+
+	response struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+)
+
+const (
+	DefaultAPIURL = "https://slack.com/api/"
+
+	SignatureHeader = "X-Slack-Signature"
+	TimestampHeader = "X-Slack-Request-Timestamp"
+	// 官方建议拒绝超过5分钟的请求以防重放
+	signatureTolerance = 5 * time.Minute
+)
+
+var ErrInvalidSignature = errors.New("invalid slack signature")
+
+// NewClient 未配置bot_token时返回nil，表示不启用Slack通道
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.BotToken == "" {
+		return nil
+	}
+	c := New(opt, &http.Client{Timeout: 10 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	uid, err := c.AuthTest(ctx)
+	if err != nil {
+		panic(err)
+	}
+	c.BotUserID = uid
+	logger.NewHelper(log).Info(fmt.Sprintf("slack bot user id: %s", uid))
+	return c
+}
+
+func New(opt Option, client *http.Client) *Client {
+	apiURL := opt.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return &Client{token: opt.BotToken, secret: []byte(opt.SigningSecret), apiURL: apiURL, http: client}
+}
+
+// AuthTest 返回机器人自身的用户ID
+func (c *Client) AuthTest(ctx context.Context) (string, error) {
+	var ret struct {
+		response
+		UserID string `json:"user_id"`
+	}
+	if err := c.call(ctx, "auth.test", struct{}{}, &ret); err != nil {
+		return "", err
+	}
+	return ret.UserID, nil
+}
+
+// PostMessage 发送消息，threadTS不为空时回复到对应的thread，返回消息的ts
+func (c *Client) PostMessage(ctx context.Context, channel, threadTS, text string) (string, error) {
+	req := struct {
+		Channel  string `json:"channel"`
+		ThreadTS string `json:"thread_ts,omitempty"`
+		Text     string `json:"text"`
+	}{Channel: channel, ThreadTS: threadTS, Text: text}
+	var ret struct {
+		response
+		TS string `json:"ts"`
+	}
+	if err := c.call(ctx, "chat.postMessage", req, &ret); err != nil {
+		return "", err
+	}
+	return ret.TS, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, body interface{}, ret interface{ result() response }) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s: unexpected status %d", method, resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		return err
+	}
+	if r := ret.result(); !r.OK {
+		return fmt.Errorf("slack %s: %s", method, r.Error)
+	}
+	return nil
+}
+
+func (r response) result() response {
+	return r
+}
+
+// Verify 校验Events API请求的签名: v0=HMAC-SHA256(signing_secret, "v0:{timestamp}:{body}")
+func (c *Client) Verify(header http.Header, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > signatureTolerance || d < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(Sign(c.secret, ts, body)), []byte(header.Get(SignatureHeader))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v0:%d:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSlack 模拟Slack Web API，记录收到的消息
+func fakeSlack(t *testing.T, posted *[]map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth.test", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"ok":true,"user_id":"U0BOT"}`))
+	})
+	mux.HandleFunc("/api/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		msg := make(map[string]string)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		if msg["channel"] == "" {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		*posted = append(*posted, msg)
+		_, _ = w.Write([]byte(`{"ok":true,"ts":"1686355200.000100"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient(t *testing.T) {
+	var posted []map[string]string
+	srv := fakeSlack(t, &posted)
+	defer srv.Close()
+
+	c := slack.New(slack.Option{BotToken: "xoxb-test", APIURL: srv.URL + "/api"}, srv.Client())
+	uid, err := c.AuthTest(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "U0BOT", uid)
+
+	ts, err := c.PostMessage(context.Background(), "C1", "1686355100.000001", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "1686355200.000100", ts)
+	assert.Equal(t, []map[string]string{{"channel": "C1", "thread_ts": "1686355100.000001", "text": "hello"}}, posted)
+
+	_, err = c.PostMessage(context.Background(), "", "", "hello")
+	assert.EqualError(t, err, "slack chat.postMessage: channel_not_found")
+}
+
+func TestVerify(t *testing.T) {
+	c := slack.New(slack.Option{SigningSecret: "8f742231b10e8888abcd99yyyzzz85a5"}, http.DefaultClient)
+	body := []byte(`{"type":"url_verification","challenge":"abc"}`)
+	now := time.Unix(1686355200, 0)
+
+	header := http.Header{}
+	header.Set(slack.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	header.Set(slack.SignatureHeader, slack.Sign([]byte("8f742231b10e8888abcd99yyyzzz85a5"), now.Unix(), body))
+	assert.NoError(t, c.Verify(header, body, now))
+	assert.ErrorIs(t, c.Verify(header, []byte(`{}`), now), slack.ErrInvalidSignature)
+	assert.ErrorIs(t, c.Verify(header, body, now.Add(10*time.Minute)), slack.ErrInvalidSignature)
+
+	header.Set(slack.SignatureHeader, "v0=deadbeef")
+	assert.ErrorIs(t, c.Verify(header, body, now), slack.ErrInvalidSignature)
+}
@@ -35,3 +35,20 @@ func NewBotAPI(opt Option, log logger.Logger) *tgbotapi.BotAPI {
 	logger.NewHelper(log).Info(fmt.Sprintf("telegram webhook link: %s", info.URL))
 	return bot
 }
+
+// Command 客户端命令菜单中的一项，名称不含/
+type Command struct {
+	Name        string
+	Description string
+}
+
+// SetCommands 调用setMyCommands覆盖客户端中显示的命令菜单，language为空时对所有语言的用户生效，
+// 否则仅对客户端语言为language的用户生效
+func SetCommands(bot *tgbotapi.BotAPI, language string, commands []Command) error {
+	botCommands := make([]tgbotapi.BotCommand, len(commands))
+	for i, cmd := range commands {
+		botCommands[i] = tgbotapi.BotCommand{Command: cmd.Name, Description: cmd.Description}
+	}
+	_, err := bot.Request(tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), language, botCommands...))
+	return err
+}
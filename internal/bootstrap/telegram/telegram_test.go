@@ -0,0 +1,45 @@
+package telegram_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/telegram"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCommands(t *testing.T) {
+	var commands []tgbotapi.BotCommand
+	var language string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+		case strings.HasSuffix(r.URL.Path, "/setMyCommands"):
+			assert.NoError(t, r.ParseForm())
+			assert.NoError(t, json.Unmarshal([]byte(r.PostForm.Get("commands")), &commands))
+			language = r.PostForm.Get("language_code")
+			_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", srv.URL+"/bot%s/%s")
+	assert.NoError(t, err)
+	err = telegram.SetCommands(bot, "en", []telegram.Command{
+		{Name: "start", Description: "Start a new conversation"},
+		{Name: "help", Description: "List available commands"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []tgbotapi.BotCommand{
+		{Command: "start", Description: "Start a new conversation"},
+		{Command: "help", Description: "List available commands"},
+	}, commands)
+	assert.Equal(t, "en", language)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/silenceper/wechat/v2/cache"
 	"github.com/silenceper/wechat/v2/officialaccount"
 	offConfig "github.com/silenceper/wechat/v2/officialaccount/config"
+	"github.com/silenceper/wechat/v2/officialaccount/menu"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,3 +29,37 @@ func NewWechatClient(opt Option) *officialaccount.OfficialAccount {
 	logrus.SetLevel(logrus.PanicLevel)
 	return wx.GetOfficialAccount(cfg)
 }
+
+// MenuCommand 自定义菜单中的命令按钮，点击后公众号推送CLICK事件，EventKey为Key
+type MenuCommand struct {
+	Name string
+	Key  string
+}
+
+const (
+	// 一级菜单最多5个子菜单，子菜单名称不超过60字节
+	maxSubButtons    = 5
+	maxSubButtonName = 60
+)
+
+// NewCommandMenu 将命令放入名为title的一级菜单，超出子菜单数量上限的命令被忽略
+func NewCommandMenu(title string, commands []MenuCommand) []*menu.Button {
+	if len(commands) > maxSubButtons {
+		commands = commands[:maxSubButtons]
+	}
+	buttons := make([]*menu.Button, len(commands))
+	for i, cmd := range commands {
+		name := cmd.Name
+		for len(name) > maxSubButtonName {
+			r := []rune(name)
+			name = string(r[:len(r)-1])
+		}
+		buttons[i] = menu.NewClickButton(name, cmd.Key)
+	}
+	return []*menu.Button{menu.NewSubButton(title, buttons)}
+}
+
+// SetCommandMenu 覆盖公众号的自定义菜单，需要公众号具备自定义菜单接口权限
+func SetCommandMenu(oa *officialaccount.OfficialAccount, title string, commands []MenuCommand) error {
+	return oa.GetMenu().SetMenu(NewCommandMenu(title, commands))
+}
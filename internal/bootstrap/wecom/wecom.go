@@ -0,0 +1,189 @@
+package wecom
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/util"
+	"github.com/silenceper/wechat/v2/work"
+	"github.com/silenceper/wechat/v2/work/config"
+)
+
+type (
+	// Option 企业微信自建应用，token及encoding_aes_key为应用"接收消息"中配置的回调参数
+	Option struct {
+		CorpID         string `json:"corp_id" yaml:"corp_id"`
+		CorpSecret     string `json:"corp_secret" yaml:"corp_secret"`
+		AgentID        string `json:"agent_id" yaml:"agent_id"`
+		Token          string `json:"token" yaml:"token"`
+		EncodingAESKey string `json:"encoding_aes_key" yaml:"encoding_aes_key"`
+		APIURL         string `json:"api_url" yaml:"api_url"`
+	}
+
+	// Client access_token由SDK的work包获取并缓存，应用消息及素材接口直接调用
+	Client struct {
+		opt     Option
+		agentID int
+		work    *work.Work
+		http    *http.Client
+	}
+
+	response struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+)
+
+const (
+	DefaultAPIURL = "https://qyapi.weixin.qq.com"
+
+	// MessageLimit 文本消息上限为2048字节，按中文UTF-8编码折算为字符数
+	MessageLimit = 680
+)
+
+var ErrInvalidSignature = errors.New("invalid wecom signature")
+
+// NewClient 未配置corp_id时返回nil，表示不启用企业微信通道
+func NewClient(opt Option, log logger.Logger) *Client {
+	if opt.CorpID == "" {
+		return nil
+	}
+	c, err := New(opt, cache.NewMemory(), &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		panic(err)
+	}
+	if _, err = c.work.GetContext().GetAccessToken(); err != nil {
+		panic(err)
+	}
+	logger.NewHelper(log).Info(fmt.Sprintf("wecom agent id: %d", c.agentID))
+	return c
+}
+
+func New(opt Option, memory cache.Cache, client *http.Client) (*Client, error) {
+	agentID, err := strconv.Atoi(opt.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wecom agent_id %q: %w", opt.AgentID, err)
+	}
+	if opt.APIURL == "" {
+		opt.APIURL = DefaultAPIURL
+	}
+	opt.APIURL = strings.TrimSuffix(opt.APIURL, "/")
+	wk := work.NewWork(&config.Config{
+		CorpID:         opt.CorpID,
+		CorpSecret:     opt.CorpSecret,
+		AgentID:        opt.AgentID,
+		Token:          opt.Token,
+		EncodingAESKey: opt.EncodingAESKey,
+		Cache:          memory,
+	})
+	return &Client{opt: opt, agentID: agentID, work: wk, http: client}, nil
+}
+
+// VerifySignature msg_signature为sha1(sort(token, timestamp, nonce, encrypt))
+func (c *Client) VerifySignature(signature, timestamp, nonce, encrypted string) error {
+	expected := util.Signature(c.opt.Token, timestamp, nonce, encrypted)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Decrypt 解密回调中的echostr或Encrypt字段，并校验明文末尾的corp_id
+func (c *Client) Decrypt(encrypted string) ([]byte, error) {
+	_, plain, err := util.DecryptMsg(c.opt.CorpID, encrypted, c.opt.EncodingAESKey)
+	return plain, err
+}
+
+// SendText 通过应用消息接口向成员发送文本消息，不受48小时的会话窗口限制
+func (c *Client) SendText(ctx context.Context, toUser, content string) error {
+	msg := map[string]interface{}{
+		"touser":  toUser,
+		"msgtype": "text",
+		"agentid": c.agentID,
+		"text":    map[string]string{"content": content},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/cgi-bin/message/send", nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var ret response
+	if err = json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return fmt.Errorf("wecom /cgi-bin/message/send: status %d: %w", resp.StatusCode, err)
+	}
+	return ret.err("/cgi-bin/message/send")
+}
+
+// Media 下载临时素材，语音消息为amr格式；失败时接口以JSON返回错误码
+func (c *Client) Media(ctx context.Context, mediaID string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/cgi-bin/media/get", url.Values{"media_id": {mediaID}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") || resp.StatusCode != http.StatusOK {
+		var ret response
+		if err = json.Unmarshal(data, &ret); err != nil {
+			return nil, fmt.Errorf("wecom /cgi-bin/media/get: status %d", resp.StatusCode)
+		}
+		if err = ret.err("/cgi-bin/media/get"); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	token, err := c.work.GetContext().GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("access_token", token)
+	req, err := http.NewRequestWithContext(ctx, method, c.opt.APIURL+path+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// url.Error中包含带access_token的完整地址
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("wecom %s: %w", path, urlErr.Err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r response) err(path string) error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("wecom %s: %d %s", path, r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package wecom_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wecom"
+	"github.com/silenceper/wechat/v2/cache"
+	"github.com/silenceper/wechat/v2/credential"
+	"github.com/silenceper/wechat/v2/util"
+	"github.com/stretchr/testify/assert"
+)
+
+const aesKey = "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C"
+
+func TestCallback(t *testing.T) {
+	c, err := wecom.New(wecom.Option{CorpID: "ww1", AgentID: "1000002", Token: "tk", EncodingAESKey: aesKey}, cache.NewMemory(), http.DefaultClient)
+	assert.NoError(t, err)
+
+	encrypted, err := util.EncryptMsg([]byte("0123456789abcdef"), []byte("<xml>hello</xml>"), "ww1", aesKey)
+	assert.NoError(t, err)
+	signature := util.Signature("tk", "1686355200", "nonce", string(encrypted))
+	assert.NoError(t, c.VerifySignature(signature, "1686355200", "nonce", string(encrypted)))
+	assert.ErrorIs(t, c.VerifySignature(signature, "1686355201", "nonce", string(encrypted)), wecom.ErrInvalidSignature)
+
+	plain, err := c.Decrypt(string(encrypted))
+	assert.NoError(t, err)
+	assert.Equal(t, "<xml>hello</xml>", string(plain))
+
+	// 其他企业的消息
+	other, _ := util.EncryptMsg([]byte("0123456789abcdef"), []byte("<xml>hello</xml>"), "ww2", aesKey)
+	_, err = c.Decrypt(string(other))
+	assert.Error(t, err)
+	_, err = c.Decrypt(base64.StdEncoding.EncodeToString([]byte("hello")))
+	assert.Error(t, err)
+
+	_, err = wecom.New(wecom.Option{CorpID: "ww1", AgentID: "abc"}, cache.NewMemory(), http.DefaultClient)
+	assert.Error(t, err)
+}
+
+func TestClient(t *testing.T) {
+	var sent []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/message/send", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tok", r.URL.Query().Get("access_token"))
+		msg := make(map[string]interface{})
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		sent = append(sent, msg)
+		if msg["touser"] == "nobody" {
+			_, _ = w.Write([]byte(`{"errcode":81013,"errmsg":"user & party & tag all invalid"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	})
+	mux.HandleFunc("/cgi-bin/media/get", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("media_id") != "m1" {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			_, _ = w.Write([]byte(`{"errcode":40007,"errmsg":"invalid media_id"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "voice/amr")
+		_, _ = w.Write([]byte("#!AMR\n"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// SDK优先从缓存读取access_token
+	memory := cache.NewMemory()
+	assert.NoError(t, memory.Set(credential.CacheKeyWorkPrefix+"_access_token_ww1", "tok", time.Hour))
+	c, err := wecom.New(wecom.Option{CorpID: "ww1", CorpSecret: "secret", AgentID: "1000002", APIURL: srv.URL}, memory, srv.Client())
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, c.SendText(ctx, "zhangsan", "hello"))
+	assert.EqualError(t, c.SendText(ctx, "nobody", "hello"), "wecom /cgi-bin/message/send: 81013 user & party & tag all invalid")
+	assert.Equal(t, map[string]interface{}{
+		"touser":  "zhangsan",
+		"msgtype": "text",
+		"agentid": float64(1000002),
+		"text":    map[string]interface{}{"content": "hello"},
+	}, sent[0])
+
+	audio, err := c.Media(ctx, "m1")
+	assert.NoError(t, err)
+	assert.Equal(t, "#!AMR\n", string(audio))
+	_, err = c.Media(ctx, "m2")
+	assert.EqualError(t, err, "wecom /cgi-bin/media/get: 40007 invalid media_id")
+}
@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type AccessApplication struct {
+	repo   domain.AccessRepository
+	policy *domain.AccessPolicy
+}
+
+func NewAccessApplication(repo domain.AccessRepository, policy *domain.AccessPolicy) *AccessApplication {
+	return &AccessApplication{repo: repo, policy: policy}
+}
+
+// Check 判断用户是否有权使用机器人，被拒绝的请求会记录日志
+func (aa *AccessApplication) Check(ctx context.Context, log logger.Logger, f domain.From) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	rule, err := aa.repo.GetRule(ctx, f)
+	if err != nil {
+		helper.Error("failed to get access rule", "error", err.Error())
+		return err
+	}
+	if !aa.policy.Permit(f, rule) {
+		helper.Warn("access denied", "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID)
+		return domain.ErrAccessDenied
+	}
+	return nil
+}
+
+// Redeem 使用邀请码放行用户，被拒绝的用户不能通过邀请码解除限制，且不会消耗邀请码
+func (aa *AccessApplication) Redeem(ctx context.Context, log logger.Logger, f domain.From, code string) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	rule, err := aa.repo.GetRule(ctx, f)
+	if err != nil {
+		helper.Error("failed to get access rule", "error", err.Error())
+		return err
+	}
+	if aa.policy.Blocked(f, rule) {
+		helper.Warn("blocked user tried to redeem invitation", "code", code, "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID)
+		return domain.ErrAccessBlocked
+	}
+
+	inv, err := aa.repo.GetInvitation(ctx, code)
+	if err != nil {
+		helper.Warn("failed to get invitation", "code", code, "error", err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidInvitation
+		}
+		return err
+	}
+	if err = inv.Redeem(f); err != nil {
+		helper.Warn("failed to redeem invitation", "code", code, "error", err.Error())
+		return err
+	}
+	if err = aa.repo.RedeemInvitation(ctx, inv); err != nil {
+		helper.Warn("failed to redeem invitation and grant access", "code", code, "error", err.Error())
+		return err
+	}
+	helper.Info("invitation redeemed", "code", code, "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID)
+	return nil
+}
+
+// Invite 生成n个一次性邀请码
+func (aa *AccessApplication) Invite(ctx context.Context, log logger.Logger, n int) ([]string, error) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		inv := domain.NewInvitation()
+		if err := aa.repo.SaveInvitation(ctx, inv); err != nil {
+			helper.Error("failed to save invitation", "error", err.Error())
+			return codes, err
+		}
+		codes = append(codes, inv.Code)
+	}
+	helper.Info("generated invitations", "counts", len(codes))
+	return codes, nil
+}
+
+func (aa *AccessApplication) SetRule(ctx context.Context, log logger.Logger, f domain.From, rule domain.AccessRule) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	if err := aa.repo.SaveRule(ctx, f, rule); err != nil {
+		helper.Error("failed to save access rule", "error", err.Error())
+		return err
+	}
+	helper.Info("updated access rule", "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID, "rule", int(rule))
+	return nil
+}
@@ -0,0 +1,180 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+// AdminApplication 供管理员使用的运维操作
+type AdminApplication struct {
+	app       *Application
+	admins    *domain.AdminList
+	repo      domain.AdminRepository
+	access    *AccessApplication
+	broadcast *BroadcastApplication
+}
+
+// NewAdminApplication access为nil(未使用MySQL)时无法封禁用户
+func NewAdminApplication(app *Application, admins *domain.AdminList, repo domain.AdminRepository,
+	access *AccessApplication, broadcast *BroadcastApplication) *AdminApplication {
+	return &AdminApplication{app: app, admins: admins, repo: repo, access: access, broadcast: broadcast}
+}
+
+func (aa *AdminApplication) Authorize(ctx context.Context, log logger.Logger, f domain.From) error {
+	if !aa.admins.IsAdmin(f) {
+		logger.NewHelper(log).WithContext(ctx).Warn("non-admin user attempted admin command",
+			"channel", f.Channel.String(), "channel_user_id", f.ChannelUserID)
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+// IsAdmin 与Authorize相同，但不记录日志
+func (aa *AdminApplication) IsAdmin(f domain.From) bool {
+	return aa.admins.IsAdmin(f)
+}
+
+// Stats 用户及会话总量，以及今日的对话数和token用量
+func (aa *AdminApplication) Stats(ctx context.Context, log logger.Logger) (*AdminStats, error) {
+	stats, err := aa.repo.Stats(ctx, domain.PeriodStart(domain.PeriodDaily, time.Now()))
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to get stats", "error", err.Error())
+		return nil, err
+	}
+	return &AdminStats{
+		Users:              stats.Users,
+		ActiveChats:        stats.ActiveChats,
+		TodayConversations: stats.Conversations,
+		TodayTokens:        stats.Usage.Total(),
+	}, nil
+}
+
+// End 强制结束用户进行中的会话
+func (aa *AdminApplication) End(ctx context.Context, log logger.Logger, target domain.From) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	chat, err := aa.app.repo.Get(ctx, target)
+	if err != nil {
+		helper.Warn("failed to get chat to end", "channel", target.Channel.String(), "channel_user_id", target.ChannelUserID, "error", err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNoActiveChat
+		}
+		return err
+	}
+
+	chat.Shutdown()
+	if err = aa.app.repo.Save(ctx, chat); err != nil {
+		helper.Error("failed to save ended chat", "chat_id", chat.ID, "error", err.Error())
+		return err
+	}
+	chat.Event.Raise(aa.app.mediator)
+	helper.Info("chat was ended by admin", "chat_id", chat.ID)
+	return nil
+}
+
+// Block 拒绝用户后续访问，并结束其进行中的会话
+func (aa *AdminApplication) Block(ctx context.Context, log logger.Logger, target domain.From) error {
+	if aa.access == nil {
+		return ErrAccessDisabled
+	}
+	if err := aa.access.SetRule(ctx, log, target, domain.AccessDenied); err != nil {
+		return err
+	}
+	if err := aa.End(ctx, log, target); err != nil {
+		logger.NewHelper(log).WithContext(ctx).Info("no chat to end for blocked user")
+	}
+	return nil
+}
+
+// Broadcast 向符合条件的用户群发消息
+func (aa *AdminApplication) Broadcast(ctx context.Context, log logger.Logger, text string, filter domain.UserFilter) (*Broadcast, error) {
+	return aa.broadcast.Start(ctx, log, text, filter)
+}
+
+// BroadcastProgress 查询群发进度
+func (aa *AdminApplication) BroadcastProgress(ctx context.Context, log logger.Logger, id string) (*Broadcast, error) {
+	return aa.broadcast.Get(ctx, log, id)
+}
+
+func (aa *AdminApplication) Chat(ctx context.Context, log logger.Logger, cid string) (*Chat, error) {
+	return aa.app.GetByChatID(ctx, log, cid)
+}
+
+func (aa *AdminApplication) ListChats(ctx context.Context, log logger.Logger, filter domain.ChatFilter, page domain.Pagination) (*ChatPage, error) {
+	chats, total, err := aa.repo.ListChats(ctx, filter, page)
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to list chats", "error", err.Error())
+		return nil, err
+	}
+	dto := &ChatPage{Page: page.Page, PageSize: page.PageSize, Total: total, Chats: make([]*ChatSummary, len(chats))}
+	for index, chat := range chats {
+		dto.Chats[index] = &ChatSummary{
+			ID:            chat.ID,
+			Channel:       chat.From.Channel.String(),
+			ChannelUserID: string(chat.From.ChannelUserID),
+			Ended:         chat.Status == domain.StatusEnded,
+			Pending:       chat.Pending,
+			Counts:        chat.Counts,
+			CreatedAt:     chat.CreatedAt,
+			UpdatedAt:     chat.UpdatedAt,
+		}
+	}
+	return dto, nil
+}
+
+// EndChat 按会话ID强制结束会话
+func (aa *AdminApplication) EndChat(ctx context.Context, log logger.Logger, cid string) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	chat, err := aa.app.repo.GetByChatID(ctx, cid)
+	if err != nil {
+		helper.Warn("failed to get chat to end", "chat_id", cid, "error", err.Error())
+		return err
+	}
+	if chat.Status == domain.StatusEnded {
+		return ErrChatEnded
+	}
+
+	chat.Shutdown()
+	if err = aa.app.repo.Save(ctx, chat); err != nil {
+		helper.Error("failed to save ended chat", "chat_id", chat.ID, "error", err.Error())
+		return err
+	}
+	chat.Event.Raise(aa.app.mediator)
+	helper.Info("chat was ended by admin", "chat_id", chat.ID)
+	return nil
+}
+
+func (aa *AdminApplication) DeleteChat(ctx context.Context, log logger.Logger, cid string) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	if err := aa.repo.DeleteChat(ctx, cid); err != nil {
+		helper.Warn("failed to delete chat", "chat_id", cid, "error", err.Error())
+		return err
+	}
+	helper.Info("chat was deleted by admin", "chat_id", cid)
+	return nil
+}
+
+func (aa *AdminApplication) ListUsers(ctx context.Context, log logger.Logger, ch domain.Channel, page domain.Pagination) (*UserPage, error) {
+	users, total, err := aa.repo.ListUserUsage(ctx, ch, page)
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to list users", "error", err.Error())
+		return nil, err
+	}
+	dto := &UserPage{Page: page.Page, PageSize: page.PageSize, Total: total, Users: make([]*UserUsage, len(users))}
+	for index, user := range users {
+		dto.Users[index] = &UserUsage{
+			Channel:          user.From.Channel.String(),
+			ChannelUserID:    string(user.From.ChannelUserID),
+			Chats:            user.Chats,
+			Conversations:    user.Conversations,
+			PromptTokens:     user.Usage.PromptTokens,
+			CompletionTokens: user.Usage.CompletionTokens,
+			LastActiveAt:     user.LastActiveAt,
+		}
+	}
+	return dto, nil
+}
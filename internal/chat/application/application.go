@@ -12,18 +12,160 @@ import (
 	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
 )
 
-type Application struct {
-	repo     domain.Repository
-	mediator mediator.Mediator
-	api      domain.ChatGTPService
+type (
+	Application struct {
+		repo      domain.Repository
+		mediator  mediator.Mediator
+		api       domain.ChatGTPService
+		reader    domain.WebReader
+		maxPages  int
+		moderator domain.Moderator
+		access    *AccessApplication
+		invite    bool // 是否允许使用邀请码，仅启用访问控制时为true
+		quota     *QuotaApplication
+		limiter   domain.RateLimiter
+		limits    *domain.RateLimitPolicy
+		voice     domain.Transcriber
+		localizer *Localizer
+	}
+
+	Option func(*Application)
+)
+
+func NewApplication(repo domain.Repository, mediator mediator.Mediator, api domain.ChatGTPService, opts ...Option) *Application {
+	app := &Application{repo: repo, mediator: mediator, api: api}
+	for _, opt := range opts {
+		opt(app)
+	}
+	return app
+}
+
+// WithWebReader 读取prompt中的链接作为上下文，单次最多读取maxPages个网页
+func WithWebReader(reader domain.WebReader, maxPages int) Option {
+	return func(app *Application) {
+		app.reader = reader
+		app.maxPages = maxPages
+	}
+}
+
+// WithModerator 在接受prompt前进行内容审核
+func WithModerator(moderator domain.Moderator) Option {
+	return func(app *Application) {
+		app.moderator = moderator
+	}
+}
+
+// WithAccessControl 在创建会话及prompt前校验用户的访问权限
+func WithAccessControl(access *AccessApplication) Option {
+	return func(app *Application) {
+		app.access = access
+		app.invite = true
+	}
+}
+
+// WithBlocklist 未启用访问控制时仍拒绝被管理员封禁的用户，access应使用空的访问策略
+func WithBlocklist(access *AccessApplication) Option {
+	return func(app *Application) {
+		app.access = access
+	}
+}
+
+// WithQuota 在prompt前校验token额度
+func WithQuota(quota *QuotaApplication) Option {
+	return func(app *Application) {
+		app.quota = quota
+	}
+}
+
+// WithRateLimiter 按通道配置对用户的prompt限速
+func WithRateLimiter(limiter domain.RateLimiter, limits *domain.RateLimitPolicy) Option {
+	return func(app *Application) {
+		app.limiter = limiter
+		app.limits = limits
+	}
+}
+
+// WithTranscriber 支持将语音消息转写为prompt
+func WithTranscriber(transcriber domain.Transcriber) Option {
+	return func(app *Application) {
+		app.voice = transcriber
+	}
+}
+
+// WithLocalizer 以用户的语言展示回复中的参考资料等附加内容
+func WithLocalizer(localizer *Localizer) Option {
+	return func(app *Application) {
+		app.localizer = localizer
+	}
+}
+
+// locale 未配置Localizer时为空，即使用缺省语言
+func (app *Application) locale(ctx context.Context, log logger.Logger, f domain.From) string {
+	if app.localizer == nil {
+		return ""
+	}
+	return app.localizer.Locale(ctx, log, f)
+}
+
+func (app *Application) checkAccess(ctx context.Context, log logger.Logger, f domain.From) error {
+	if app.access == nil {
+		return nil
+	}
+	return app.access.Check(ctx, log, f)
 }
 
-func NewApplication(repo domain.Repository, mediator mediator.Mediator, api domain.ChatGTPService) *Application {
-	return &Application{repo: repo, mediator: mediator, api: api}
+// Redeem 使用邀请码获取访问权限
+func (app *Application) Redeem(ctx context.Context, log logger.Logger, f domain.From, code string) error {
+	if !app.invite {
+		return ErrAccessDisabled
+	}
+	return app.access.Redeem(ctx, log, f, code)
+}
+
+// Usage 查询用户的token用量
+func (app *Application) Usage(ctx context.Context, log logger.Logger, f domain.From) (*QuotaUsage, error) {
+	if app.quota == nil {
+		return nil, ErrQuotaDisabled
+	}
+	return app.quota.Usage(ctx, log, f)
+}
+
+// Transcribe 将语音转写为文本，转写前先校验访问权限、限速与配额，避免为无权限或已超额的用户产生费用；
+// 转写本身也会消耗一次限速令牌
+func (app *Application) Transcribe(ctx context.Context, log logger.Logger, f domain.From, format string, audio []byte) (string, error) {
+	if app.voice == nil {
+		return "", ErrVoiceDisabled
+	}
+	if err := app.checkAccess(ctx, log, f); err != nil {
+		return "", err
+	}
+	helper := logger.NewHelper(log).WithContext(ctx)
+	if err := app.checkRateLimit(ctx, helper, f); err != nil {
+		return "", err
+	}
+	if app.quota != nil {
+		if err := app.quota.Check(ctx, log, f); err != nil {
+			return "", err
+		}
+	}
+	text, err := app.voice.Transcribe(ctx, format, audio)
+	if err != nil {
+		helper.Error("failed to transcribe voice", "format", format, "error", err.Error())
+		return "", err
+	}
+	return text, nil
 }
 
 func (app *Application) NewChat(ctx context.Context, log logger.Logger, f domain.From) error {
-	chat := domain.NewChat(f)
+	return app.NewThreadChat(ctx, log, f, "")
+}
+
+// NewThreadChat 在thread中开始新的会话，thread为空时等同于NewChat
+func (app *Application) NewThreadChat(ctx context.Context, log logger.Logger, f domain.From, thread string) error {
+	if err := app.checkAccess(ctx, log, f); err != nil {
+		return err
+	}
+	chat := domain.NewThreadChat(f, thread)
 	err := app.repo.Save(ctx, chat)
 	if err != nil {
 		logger.NewHelper(log).WithContext(ctx).Error("failed to start new chat", "chat_id", chat.ID, "error", err.Error())
@@ -34,12 +176,16 @@ func (app *Application) NewChat(ctx context.Context, log logger.Logger, f domain
 }
 
 func (app *Application) Get(ctx context.Context, log logger.Logger, f domain.From) (*Chat, error) {
+	return app.GetThread(ctx, log, f, "")
+}
+
+func (app *Application) GetThread(ctx context.Context, log logger.Logger, f domain.From, thread string) (*Chat, error) {
 	helper := logger.NewHelper(log).WithContext(ctx)
-	chat, err := app.repo.Get(ctx, f)
+	chat, err := app.repo.GetThread(ctx, f, thread)
 	if err != nil {
 		helper.Error("failed to get chat", "error", err.Error())
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("没有进行中的会话")
+			return nil, ErrNoActiveChat
 		}
 		return nil, err
 	}
@@ -59,13 +205,43 @@ func (app *Application) GetByChatID(ctx context.Context, log logger.Logger, cid
 }
 
 func (app *Application) Prompt(ctx context.Context, log logger.Logger, f domain.From, q string, msgID domain.ChannelMessageID) error {
+	return app.PromptThread(ctx, log, f, "", q, msgID)
+}
+
+// PromptThread 向thread中的会话发送prompt，访问控制、额度及限速以f计算
+func (app *Application) PromptThread(ctx context.Context, log logger.Logger, f domain.From, thread, q string, msgID domain.ChannelMessageID) error {
+	if err := app.checkAccess(ctx, log, f); err != nil {
+		return err
+	}
 	helper := logger.NewHelper(log).WithContext(ctx)
-	chat, err := app.repo.Get(ctx, f)
+	if err := app.checkRateLimit(ctx, helper, f); err != nil {
+		return err
+	}
+	if app.quota != nil {
+		if err := app.quota.Check(ctx, log, f); err != nil {
+			return err
+		}
+	}
+	chat, err := app.repo.GetThread(ctx, f, thread)
 	if err != nil {
 		helper.Error("failed to get chat", "error", err.Error())
 		return err
 	}
 
+	if app.moderator != nil {
+		result, err := app.moderator.Moderate(ctx, q)
+		if err != nil {
+			helper.Error("failed to moderate prompt", "chat_id", chat.ID, "error", err.Error())
+			return err
+		}
+		if result.Flagged {
+			helper.Warn("prompt was blocked by moderation", "chat_id", chat.ID, "category", result.Category)
+			chat.Reject(q, msgID, result.Category)
+			chat.Event.Raise(app.mediator)
+			return nil
+		}
+	}
+
 	if err = chat.Prompt(q, msgID); err != nil {
 		helper.Error("failed to prompt", "chat_id", chat.ID, "error", err.Error())
 		return err
@@ -83,11 +259,13 @@ func (app *Application) Prompt(ctx context.Context, log logger.Logger, f domain.
 		defer cancel()
 		helper := logger.NewHelper(log).WithContext(ctx)
 
-		chat, err := app.repo.Get(ctx, f)
+		chat, err := app.repo.GetThread(ctx, f, thread)
 		if err != nil {
 			helper.Error("failed to get chat from repository to call chatgpt api", "chat_id", chat.ID, "error", err.Error())
 			return
 		}
+		chat.Locale = app.locale(ctx, log, f)
+		app.readPages(ctx, helper, chat)
 		conv, err := app.api.Chat(ctx, chat)
 		if err != nil {
 			helper.Error("failed to get completion from chatgpt", "chat_id", chat.ID, "error", err.Error())
@@ -106,9 +284,144 @@ func (app *Application) Prompt(ctx context.Context, log logger.Logger, f domain.
 	return nil
 }
 
+// checkRateLimit 限速器异常时放行，避免影响正常使用
+func (app *Application) checkRateLimit(ctx context.Context, helper *logger.Helper, f domain.From) error {
+	if app.limiter == nil {
+		return nil
+	}
+	limit := app.limits.Limit(f.Channel)
+	if !limit.Enabled() {
+		return nil
+	}
+	wait, err := app.limiter.Take(ctx, f, limit)
+	if err != nil {
+		helper.Error("failed to take token from rate limiter", "error", err.Error())
+		return nil
+	}
+	if wait > 0 {
+		helper.Warn("prompt was rate limited", "retry_after", wait.String())
+		return &domain.RateLimitedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Complete 同步完成一次无状态的对话，历史对话由调用方提供，结束后会话即关闭并记录用量
+func (app *Application) Complete(ctx context.Context, log logger.Logger, f domain.From, history []*domain.Conversation,
+	q string, msgID domain.ChannelMessageID) (*Completion, error) {
+	if err := app.checkAccess(ctx, log, f); err != nil {
+		return nil, err
+	}
+	helper := logger.NewHelper(log).WithContext(ctx)
+	if err := app.checkRateLimit(ctx, helper, f); err != nil {
+		return nil, err
+	}
+	if app.quota != nil {
+		if err := app.quota.Check(ctx, log, f); err != nil {
+			return nil, err
+		}
+	}
+
+	chat := domain.NewStatelessChat(f, history)
+	if app.moderator != nil {
+		result, err := app.moderator.Moderate(ctx, q)
+		if err != nil {
+			helper.Error("failed to moderate prompt", "chat_id", chat.ID, "error", err.Error())
+			return nil, err
+		}
+		if result.Flagged {
+			helper.Warn("prompt was blocked by moderation", "chat_id", chat.ID, "category", result.Category)
+			chat.Reject(q, msgID, result.Category)
+			chat.Event.Raise(app.mediator)
+			return nil, domain.ErrContentBlocked
+		}
+	}
+	if err := chat.Prompt(q, msgID); err != nil {
+		helper.Error("failed to prompt", "chat_id", chat.ID, "error", err.Error())
+		return nil, err
+	}
+
+	// 请求自带的默认超时不足以等待completion
+	callCtx, cancel := pkgCtx.GenContextWithTimeout(3 * time.Minute)
+	defer cancel()
+	chat.Locale = app.locale(callCtx, log, f)
+	app.readPages(callCtx, helper, chat)
+	conv, err := app.api.Chat(callCtx, chat)
+	chat.Shutdown()
+	if err != nil {
+		// 失败的对话没有需要记录的内容，调用方的历史对话也不落库
+		chat.Event.Raise(app.mediator)
+		helper.Error("failed to get completion from chatgpt", "chat_id", chat.ID, "error", err.Error())
+		return nil, err
+	}
+	if err = app.repo.Save(context.Background(), chat); err != nil {
+		helper.Error("failed to save chat", "chat_id", chat.ID, "error", err.Error())
+		return nil, err
+	}
+	chat.Event.Raise(app.mediator)
+	helper.Info("got completion", "chat_id", chat.ID, "completion", conv.Completion)
+	return &Completion{
+		ChatID:           chat.ID,
+		Text:             conv.Completion,
+		Model:            conv.Model,
+		PromptTokens:     conv.PromptTokens,
+		CompletionTokens: conv.CompletionTokens,
+	}, nil
+}
+
+func (app *Application) ToggleKnowledge(ctx context.Context, log logger.Logger, f domain.From, on bool) error {
+	return app.ToggleThreadKnowledge(ctx, log, f, "", on)
+}
+
+func (app *Application) ToggleThreadKnowledge(ctx context.Context, log logger.Logger, f domain.From, thread string, on bool) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	chat, err := app.repo.GetThread(ctx, f, thread)
+	if err != nil {
+		helper.Error("failed to get chat", "error", err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoActiveChat
+		}
+		return err
+	}
+
+	if err = chat.ToggleKnowledge(on); err != nil {
+		helper.Error("failed to toggle knowledge base", "chat_id", chat.ID, "error", err.Error())
+		return err
+	}
+	if err = app.repo.Save(ctx, chat); err != nil {
+		helper.Error("failed to save chat", "chat_id", chat.ID, "error", err.Error())
+		return err
+	}
+	helper.Info("toggled knowledge base", "chat_id", chat.ID, "knowledge", on)
+	return nil
+}
+
+// readPages 将prompt中的网页正文附加到当前对话，读取失败的链接会被忽略
+func (app *Application) readPages(ctx context.Context, helper *logger.Helper, chat *domain.Chat) {
+	if app.reader == nil {
+		return
+	}
+	current, err := chat.CurrentConversation()
+	if err != nil {
+		return
+	}
+	for _, link := range domain.ExtractURLs(current.Prompt, app.maxPages) {
+		doc, err := app.reader.Read(ctx, link)
+		if err != nil {
+			helper.Warn("failed to read web page", "chat_id", chat.ID, "url", link, "error", err.Error())
+			continue
+		}
+		current.Attach(doc)
+		helper.Info("read web page", "chat_id", chat.ID, "url", link, "title", doc.Title)
+	}
+}
+
 func (app *Application) End(ctx context.Context, log logger.Logger, f domain.From) {
+	app.EndThread(ctx, log, f, "")
+}
+
+func (app *Application) EndThread(ctx context.Context, log logger.Logger, f domain.From, thread string) {
 	helper := logger.NewHelper(log).WithContext(ctx)
-	chat, err := app.repo.Get(ctx, f)
+	chat, err := app.repo.GetThread(ctx, f, thread)
 	if err != nil {
 		helper.Warn("failed to get chat to shutdown", "error", err.Error())
 		return
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// BroadcastApplication 群发任务在后台执行，进程退出后可通过Resume继续未完成的任务
+	BroadcastApplication struct {
+		repo     domain.BroadcastRepository
+		users    domain.AdminRepository
+		notifier domain.ChannelNotifier
+		pacer    *pacer
+		owner    string // 当前实例的标识，用于认领投递
+	}
+
+	// pacer 所有群发任务共享的通道级发送节奏，例如Telegram全局每秒最多30条
+	pacer struct {
+		mu        sync.Mutex
+		intervals map[domain.Channel]time.Duration
+		next      map[domain.Channel]time.Time
+	}
+)
+
+const (
+	defaultBroadcastInterval = 100 * time.Millisecond
+	deliveryBatchSize        = 100
+	// 认领投递的租约，实例异常退出后到期的投递由其他实例继续
+	deliveryLease = 5 * time.Minute
+)
+
+func NewBroadcastApplication(repo domain.BroadcastRepository, users domain.AdminRepository, notifier domain.ChannelNotifier,
+	intervals map[domain.Channel]time.Duration) *BroadcastApplication {
+	return &BroadcastApplication{
+		repo:     repo,
+		users:    users,
+		notifier: notifier,
+		pacer:    &pacer{intervals: intervals, next: make(map[domain.Channel]time.Time)},
+		owner:    ulid.Make().String(),
+	}
+}
+
+// Start 按筛选条件确定接收人并创建群发任务，微信用户额外受客服消息时间窗口限制
+func (ba *BroadcastApplication) Start(ctx context.Context, log logger.Logger, text string, filter domain.UserFilter) (*Broadcast, error) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	// 未指定通道时仅群发给支持主动推送的通道
+	channels := ba.notifier.Channels()
+	if filter.Channel != 0 {
+		if !containsChannel(channels, filter.Channel) {
+			helper.Warn("channel does not support broadcast", "channel", filter.Channel.String())
+			return nil, domain.ErrBroadcastUnsupported
+		}
+		channels = []domain.Channel{filter.Channel}
+	}
+
+	now := time.Now()
+	var recipients []domain.From
+	for _, channel := range channels {
+		users, err := ba.users.ListUsers(ctx, filter.Within(channel, now))
+		if err != nil {
+			helper.Error("failed to list users", "channel", channel.String(), "error", err.Error())
+			return nil, err
+		}
+		recipients = append(recipients, users...)
+	}
+
+	b, deliveries, err := domain.NewBroadcast(text, filter, recipients)
+	if err != nil {
+		helper.Warn("failed to create broadcast", "error", err.Error())
+		return nil, err
+	}
+	if err = ba.repo.Create(ctx, b, deliveries); err != nil {
+		helper.Error("failed to save broadcast", "broadcast_id", b.ID, "error", err.Error())
+		return nil, err
+	}
+	helper.Info("broadcast started", "broadcast_id", b.ID, "recipients", b.Total)
+
+	go ba.run(log, b)
+	return assembleBroadcast(b), nil
+}
+
+func containsChannel(channels []domain.Channel, ch domain.Channel) bool {
+	for _, c := range channels {
+		if c == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume 继续执行中断的群发任务，多个实例可同时执行同一任务，每个投递仅由认领它的实例发送
+func (ba *BroadcastApplication) Resume(ctx context.Context, log logger.Logger) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	broadcasts, err := ba.repo.ListUnfinished(ctx)
+	if err != nil {
+		helper.Error("failed to list unfinished broadcasts", "error", err.Error())
+		return
+	}
+	for _, b := range broadcasts {
+		helper.Info("resume broadcast", "broadcast_id", b.ID, "sent", b.Sent, "failed", b.Failed, "total", b.Total)
+		go ba.run(log, b)
+	}
+}
+
+func (ba *BroadcastApplication) Get(ctx context.Context, log logger.Logger, id string) (*Broadcast, error) {
+	b, err := ba.repo.Get(ctx, id)
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to get broadcast", "broadcast_id", id, "error", err.Error())
+		return nil, err
+	}
+	return assembleBroadcast(b), nil
+}
+
+// run 逐批投递，进程退出时根context被取消，剩余的接收人保持待发送状态
+func (ba *BroadcastApplication) run(log logger.Logger, b *domain.Broadcast) {
+	ctx := pkgCtx.RootContext()
+	helper := logger.NewHelper(log).WithContext(ctx)
+
+	for {
+		deliveries, err := ba.repo.ListPendingDeliveries(ctx, b.ID, deliveryBatchSize)
+		if err != nil {
+			helper.Error("failed to list pending deliveries", "broadcast_id", b.ID, "error", err.Error())
+			return
+		}
+		if len(deliveries) == 0 {
+			break
+		}
+		for _, d := range deliveries {
+			if err = ba.pacer.wait(ctx, d.To.Channel); err != nil {
+				helper.Warn("broadcast was interrupted", "broadcast_id", b.ID, "sent", b.Sent, "failed", b.Failed)
+				return
+			}
+			claimed, err := ba.repo.ClaimDelivery(ctx, d, ba.owner, time.Now().Add(deliveryLease))
+			if err != nil {
+				helper.Error("failed to claim delivery", "broadcast_id", b.ID, "error", err.Error())
+				return
+			}
+			if !claimed {
+				continue
+			}
+			err = ba.notifier.Notify(ctx, d.To, b.Text)
+			if err != nil {
+				helper.Warn("failed to deliver broadcast", "broadcast_id", b.ID, "channel", d.To.Channel.String(),
+					"channel_user_id", d.To.ChannelUserID, "error", err.Error())
+			}
+			b.Record(d, err)
+			if err = ba.repo.SaveDelivery(ctx, b, d); err != nil {
+				helper.Error("failed to save delivery", "broadcast_id", b.ID, "error", err.Error())
+				return
+			}
+		}
+	}
+
+	b.Finish()
+	finished, err := ba.repo.Finish(ctx, b)
+	if err != nil {
+		helper.Error("failed to finish broadcast", "broadcast_id", b.ID, "error", err.Error())
+		return
+	}
+	if !finished {
+		helper.Info("broadcast deliveries are still in progress on other instances", "broadcast_id", b.ID)
+		return
+	}
+	helper.Info("broadcast finished", "broadcast_id", b.ID, "sent", b.Sent, "failed", b.Failed, "total", b.Total)
+}
+
+// wait 为通道预留下一个发送时间点并等待
+func (p *pacer) wait(ctx context.Context, ch domain.Channel) error {
+	interval, ok := p.intervals[ch]
+	if !ok {
+		interval = defaultBroadcastInterval
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	slot := p.next[ch]
+	if slot.Before(now) {
+		slot = now
+	}
+	p.next[ch] = slot.Add(interval)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,242 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// CallbackApplication 将REST通道的会话事件投递到调用方的回调地址，同一用户的回调按顺序逐个投递，
+	// 不同用户的回调地址互不影响
+	CallbackApplication struct {
+		repo        domain.CallbackRepository
+		senders     map[string]domain.CallbackSender
+		secrets     map[string][]byte
+		maxAttempts int
+		mu          sync.Mutex
+		queues      map[domain.From]*callbackQueue
+		owner       string // 当前实例的标识，用于认领投递
+	}
+
+	// callbackQueue 用户待投递的回调，running表示已有goroutine在投递
+	callbackQueue struct {
+		pending []*domain.CallbackDelivery
+		running bool
+	}
+
+	CallbackEventHandler struct {
+		app *CallbackApplication
+		log logger.Logger
+	}
+)
+
+const (
+	// 单次回调请求的超时时间
+	callbackTimeout = 10 * time.Second
+	// 认领投递的租约，每次尝试前续期，需覆盖一次请求及其后的退避
+	callbackLease = 5 * time.Minute
+)
+
+// NewCallbackApplication senders、secrets分别为客户端名称到回调发送方、签名密钥的映射
+func NewCallbackApplication(repo domain.CallbackRepository, senders map[string]domain.CallbackSender, secrets map[string][]byte, maxAttempts int) *CallbackApplication {
+	if maxAttempts <= 0 {
+		maxAttempts = domain.DefaultCallbackAttempts
+	}
+	return &CallbackApplication{repo: repo, senders: senders, secrets: secrets, maxAttempts: maxAttempts, queues: make(map[domain.From]*callbackQueue), owner: ulid.Make().String()}
+}
+
+// Register 保存用户的回调地址，后续事件均投递到最新的地址
+func (app *CallbackApplication) Register(ctx context.Context, log logger.Logger, ep *domain.CallbackEndpoint) error {
+	if err := domain.ValidateCallbackURL(ep.URL); err != nil {
+		return err
+	}
+	if err := app.repo.SaveEndpoint(ctx, ep); err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to save callback endpoint", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (app *CallbackApplication) Deliveries(ctx context.Context, log logger.Logger, client string, p domain.Pagination) (*CallbackDeliveryPage, error) {
+	deliveries, total, err := app.repo.ListDeliveries(ctx, client, p)
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to list callback deliveries", "error", err.Error())
+		return nil, err
+	}
+	page := &CallbackDeliveryPage{Page: p.Page, PageSize: p.PageSize, Total: total, Deliveries: make([]*CallbackDelivery, len(deliveries))}
+	for index, d := range deliveries {
+		page.Deliveries[index] = assembleCallbackDelivery(d)
+	}
+	return page, nil
+}
+
+// Deliver 记录一个事件并加入用户的投递队列，进程退出时未完成的投递保持pending状态
+func (app *CallbackApplication) Deliver(ctx context.Context, log logger.Logger, e domain.MetaEvent) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	event, ok := domain.NewCallbackEvent(e, time.Now())
+	if !ok {
+		return
+	}
+	ep, err := app.repo.GetEndpoint(ctx, e.From)
+	if err != nil {
+		helper.Error("failed to get callback endpoint", "chat_id", e.ChatID, "error", err.Error())
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		helper.Error("failed to marshal callback event", "chat_id", e.ChatID, "error", err.Error())
+		return
+	}
+	delivery := domain.NewCallbackDelivery(event, ep, body)
+	delivery.Owner = app.owner
+	delivery.LeaseUntil = time.Now().Add(callbackLease)
+	if err = app.repo.SaveDelivery(ctx, delivery); err != nil {
+		helper.Error("failed to save callback delivery", "chat_id", e.ChatID, "error", err.Error())
+		return
+	}
+	app.enqueue(log, delivery)
+}
+
+// Resume 继续投递未完成且无实例认领(或租约已过期)的回调，应在订阅事件前调用以保证顺序
+func (app *CallbackApplication) Resume(ctx context.Context, log logger.Logger) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	deliveries, err := app.repo.ListPending(ctx)
+	if err != nil {
+		helper.Error("failed to list pending callback deliveries", "error", err.Error())
+		return
+	}
+	var resumed int
+	for _, delivery := range deliveries {
+		claimed, err := app.repo.ClaimDelivery(ctx, delivery, app.owner, time.Now().Add(callbackLease))
+		if err != nil {
+			helper.Error("failed to claim callback delivery", "delivery_id", delivery.ID, "error", err.Error())
+			return
+		}
+		if claimed {
+			app.enqueue(log, delivery)
+			resumed++
+		}
+	}
+	if resumed > 0 {
+		helper.Info("resume callback deliveries", "count", resumed)
+	}
+}
+
+func (app *CallbackApplication) enqueue(log logger.Logger, delivery *domain.CallbackDelivery) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	queue, ok := app.queues[delivery.From]
+	if !ok {
+		queue = new(callbackQueue)
+		app.queues[delivery.From] = queue
+	}
+	queue.pending = append(queue.pending, delivery)
+	if !queue.running {
+		queue.running = true
+		go app.drain(pkgCtx.RootContext(), log, delivery.From, queue)
+	}
+}
+
+// drain 逐个投递队列中的回调，前一个成功或最终失败后才投递下一个，队列为空时移除
+func (app *CallbackApplication) drain(ctx context.Context, log logger.Logger, f domain.From, queue *callbackQueue) {
+	for {
+		app.mu.Lock()
+		if len(queue.pending) == 0 || ctx.Err() != nil {
+			queue.running = false
+			if len(queue.pending) == 0 {
+				delete(app.queues, f)
+			}
+			app.mu.Unlock()
+			return
+		}
+		delivery := queue.pending[0]
+		queue.pending = queue.pending[1:]
+		app.mu.Unlock()
+
+		app.attempt(ctx, log, delivery)
+	}
+}
+
+// attempt 投递失败后按退避间隔重试
+func (app *CallbackApplication) attempt(ctx context.Context, log logger.Logger, delivery *domain.CallbackDelivery) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	for delivery.Status == domain.CallbackPending {
+		// 排队期间租约可能已过期并被其他实例认领
+		claimed, err := app.repo.ClaimDelivery(ctx, delivery, app.owner, time.Now().Add(callbackLease))
+		if err != nil {
+			helper.Error("failed to renew callback delivery lease", "delivery_id", delivery.ID, "error", err.Error())
+			return
+		}
+		if !claimed {
+			return
+		}
+		status, err := app.send(ctx, delivery)
+		delivery.Record(status, err, app.maxAttempts)
+		if err := app.repo.SaveDelivery(context.Background(), delivery); err != nil {
+			helper.Error("failed to save callback delivery", "delivery_id", delivery.ID, "error", err.Error())
+		}
+		switch delivery.Status {
+		case domain.CallbackSucceeded:
+			return
+		case domain.CallbackFailed:
+			helper.Error("callback delivery failed", "delivery_id", delivery.ID, "url", delivery.URL, "attempts", delivery.Attempts, "error", delivery.Error)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(domain.CallbackBackoff(delivery.Attempts)):
+		}
+	}
+}
+
+func (app *CallbackApplication) send(ctx context.Context, delivery *domain.CallbackDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+
+	client := domain.RESTClient(delivery.From)
+	sender, ok := app.senders[client]
+	if !ok {
+		return 0, fmt.Errorf("unknown rest client: %s", client)
+	}
+	// 每次重试重新签名，避免接收方因时间戳过期而拒绝
+	timestamp := time.Now().Unix()
+	header := map[string]string{
+		"Content-Type":                 "application/json",
+		domain.CallbackTimestampHeader: strconv.FormatInt(timestamp, 10),
+		domain.CallbackSignatureHeader: domain.SignCallback(app.secrets[client], timestamp, delivery.Payload),
+	}
+	return sender.Send(ctx, delivery.URL, header, delivery.Payload)
+}
+
+func NewCallbackEventHandler(log logger.Logger, app *CallbackApplication) mediator.EventHandler {
+	return &CallbackEventHandler{log: log, app: app}
+}
+
+func (ev *CallbackEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindConversationCreated,
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+func (ev *CallbackEventHandler) Handle(_ context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelREST {
+		return
+	}
+	ev.app.Deliver(pkgCtx.RootContext(), ev.log, e)
+}
@@ -0,0 +1,74 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+// CLIEventHandler 将回复打印到终端，并通知REPL读取下一条输入
+type CLIEventHandler struct {
+	mu        sync.Mutex
+	out       io.Writer
+	replied   chan struct{}
+	localizer *Localizer
+	log       logger.Logger
+}
+
+func NewCLIEventHandler(log logger.Logger, localizer *Localizer, out io.Writer) *CLIEventHandler {
+	return &CLIEventHandler{log: log, localizer: localizer, out: out, replied: make(chan struct{}, 1)}
+}
+
+// Replied 每次打印回复、错误或拦截提示后收到一个信号
+func (ev *CLIEventHandler) Replied() <-chan struct{} {
+	return ev.replied
+}
+
+func (ev *CLIEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+func (ev *CLIEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelCLI {
+		return
+	}
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, ev.log, e.From, e.Error)
+		logger.NewHelper(ev.log).Error("current conversation was interrupted", "chat_id", e.ChatID, "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, ev.log, e.From, e.Category)
+		logger.NewHelper(ev.log).Warn("conversation was blocked by moderation", "chat_id", e.ChatID, "category", e.Category)
+	}
+
+	ev.mu.Lock()
+	_, _ = fmt.Fprintf(ev.out, "bot> %s\n", text)
+	ev.mu.Unlock()
+	select {
+	case ev.replied <- struct{}{}:
+	default:
+	}
+}
+
+// Print 与回复共用输出，避免交错
+func (ev *CLIEventHandler) Print(text string) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	_, _ = fmt.Fprintf(ev.out, "bot> %s\n", text)
+}
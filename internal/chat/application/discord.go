@@ -0,0 +1,124 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/discord"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type (
+	// DiscordInteractions 保存延迟响应的interaction token，token过长无法作为消息ID落库。
+	// 回复事件与interaction由同一实例处理，因此仅保存在内存中
+	DiscordInteractions struct {
+		mu     sync.Mutex
+		tokens map[domain.ChannelMessageID]discordToken
+	}
+
+	discordToken struct {
+		token     string
+		expiresAt time.Time
+	}
+
+	DiscordEventHandler struct {
+		client       *discord.Client
+		interactions *DiscordInteractions
+		localizer    *Localizer
+		log          logger.Logger
+	}
+)
+
+// Discord的interaction token有效期为15分钟
+const discordTokenTTL = 15 * time.Minute
+
+func NewDiscordInteractions() *DiscordInteractions {
+	return &DiscordInteractions{tokens: make(map[domain.ChannelMessageID]discordToken)}
+}
+
+func (di *DiscordInteractions) Put(msgID domain.ChannelMessageID, token string) {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range di.tokens {
+		if now.After(t.expiresAt) {
+			delete(di.tokens, id)
+		}
+	}
+	di.tokens[msgID] = discordToken{token: token, expiresAt: now.Add(discordTokenTTL)}
+}
+
+// Take 取出并删除token，每个interaction只完成一次
+func (di *DiscordInteractions) Take(msgID domain.ChannelMessageID) (string, bool) {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	t, ok := di.tokens[msgID]
+	delete(di.tokens, msgID)
+	if !ok || time.Now().After(t.expiresAt) {
+		return "", false
+	}
+	return t.token, true
+}
+
+func NewDiscordEventHandler(log logger.Logger, localizer *Localizer, client *discord.Client, interactions *DiscordInteractions) mediator.EventHandler {
+	return &DiscordEventHandler{log: log, localizer: localizer, client: client, interactions: interactions}
+}
+
+func (ev *DiscordEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 以回复替换延迟响应的占位消息，超出长度的部分以follow-up消息发送
+func (ev *DiscordEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelDiscord {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "discord_user_id", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	token, ok := ev.interactions.Take(e.Conversation.MessageID)
+	if !ok {
+		helper.Warn("discord interaction token is missing or expired")
+		return
+	}
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, log, e.From, e.Error)
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, log, e.From, e.Category)
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	for index, part := range domain.SplitText(text, discord.MessageLimit) {
+		var err error
+		if index == 0 {
+			err = ev.client.EditOriginal(ctx, token, part)
+		} else {
+			err = ev.client.CreateFollowup(ctx, token, part)
+		}
+		if err != nil {
+			helper.Error("failed to send message to discord", "error", err.Error())
+			return
+		}
+	}
+}
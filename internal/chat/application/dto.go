@@ -1,16 +1,32 @@
 package application
 
-import "github.com/jacexh/chatgpt-bot/internal/chat/domain"
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
 
 type Converstaion struct {
-	Prompt     string `json:"prompt"`
-	Completion string `json:"completion"`
+	Prompt     string      `json:"prompt"`
+	Completion string      `json:"completion"`
+	ToolCalls  []*ToolCall `json:"tool_calls,omitempty"`
 }
 
 type Chat struct {
 	ID            string          `json:"id"`
 	Channel       int             `json:"channel"`
 	ChannelUserID string          `json:"channel_user_id"`
+	Ended         bool            `json:"ended,omitempty"`
 	Current       *Converstaion   `json:"current,omitempty"`
 	Previous      []*Converstaion `json:"previous,omitempty"`
 }
@@ -20,16 +36,186 @@ func AssembleEntidy(entity *domain.Chat) *Chat {
 		ID:            entity.ID,
 		Channel:       int(entity.From.Channel),
 		ChannelUserID: string(entity.From.ChannelUserID),
+		Ended:         entity.Status == domain.StatusEnded,
 		Previous:      make([]*Converstaion, len(entity.PreviousConversations())),
 	}
 	if cov, err := entity.CurrentConversation(); err == nil {
-		c.Current = &Converstaion{Prompt: cov.Prompt}
+		c.Current = &Converstaion{Prompt: cov.Prompt, ToolCalls: assembleToolCalls(cov.ToolCalls)}
 	}
 	for index, conv := range entity.PreviousConversations() {
 		c.Previous[index] = &Converstaion{
 			Prompt:     conv.Prompt,
 			Completion: conv.Completion,
+			ToolCalls:  assembleToolCalls(conv.ToolCalls),
 		}
 	}
 	return c
 }
+
+func assembleToolCalls(calls []*domain.ToolCall) []*ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	dtos := make([]*ToolCall, len(calls))
+	for index, call := range calls {
+		dtos[index] = &ToolCall{
+			Name:      call.Name,
+			Arguments: call.Arguments,
+			Result:    call.Result,
+			Error:     call.Error,
+		}
+	}
+	return dtos
+}
+
+// QuotaUsage 用户当前周期的token用量，Limit为0表示不限制
+type QuotaUsage struct {
+	DailyUsed    int64 `json:"daily_used"`
+	DailyLimit   int64 `json:"daily_limit"`
+	MonthlyUsed  int64 `json:"monthly_used"`
+	MonthlyLimit int64 `json:"monthly_limit"`
+}
+
+type UsageReportRow struct {
+	Key              string  `json:"key"`
+	Conversations    int64   `json:"conversations"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageReport 指定时间段内按维度汇总的用量及费用
+type UsageReport struct {
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	GroupBy string            `json:"group_by"`
+	Rows    []*UsageReportRow `json:"rows"`
+	Total   *UsageReportRow   `json:"total"`
+}
+
+func (ur *UsageReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{ur.GroupBy, "conversations", "prompt_tokens", "completion_tokens", "cost"}); err != nil {
+		return err
+	}
+	for _, row := range append(ur.Rows, ur.Total) {
+		if err := writer.Write([]string{
+			row.Key,
+			strconv.FormatInt(row.Conversations, 10),
+			strconv.FormatInt(row.PromptTokens, 10),
+			strconv.FormatInt(row.CompletionTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 4, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type AdminStats struct {
+	Users              int64 `json:"users"`
+	ActiveChats        int64 `json:"active_chats"`
+	TodayConversations int64 `json:"today_conversations"`
+	TodayTokens        int64 `json:"today_tokens"`
+}
+
+type Broadcast struct {
+	ID        string    `json:"id"`
+	Finished  bool      `json:"finished"`
+	Total     int       `json:"total"`
+	Sent      int       `json:"sent"`
+	Failed    int       `json:"failed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func assembleBroadcast(b *domain.Broadcast) *Broadcast {
+	return &Broadcast{
+		ID:        b.ID,
+		Finished:  b.IsFinished(),
+		Total:     b.Total,
+		Sent:      b.Sent,
+		Failed:    b.Failed,
+		CreatedAt: b.CreatedAt,
+	}
+}
+
+type ChatSummary struct {
+	ID            string    `json:"id"`
+	Channel       string    `json:"channel"`
+	ChannelUserID string    `json:"channel_user_id"`
+	Ended         bool      `json:"ended"`
+	Pending       bool      `json:"pending"`
+	Counts        int       `json:"counts"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ChatPage struct {
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	Total    int64          `json:"total"`
+	Chats    []*ChatSummary `json:"chats"`
+}
+
+type UserUsage struct {
+	Channel          string    `json:"channel"`
+	ChannelUserID    string    `json:"channel_user_id"`
+	Chats            int64     `json:"chats"`
+	Conversations    int64     `json:"conversations"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	LastActiveAt     time.Time `json:"last_active_at"`
+}
+
+type UserPage struct {
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int64        `json:"total"`
+	Users    []*UserUsage `json:"users"`
+}
+
+type Completion struct {
+	ChatID           string `json:"chat_id"`
+	Text             string `json:"text"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+type CallbackDelivery struct {
+	ID             int64     `json:"id"`
+	UserID         string    `json:"user_id"`
+	ChatID         string    `json:"chat_id"`
+	Event          string    `json:"event"`
+	URL            string    `json:"url"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	ResponseStatus int       `json:"response_status"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func assembleCallbackDelivery(d *domain.CallbackDelivery) *CallbackDelivery {
+	return &CallbackDelivery{
+		ID:             d.ID,
+		UserID:         domain.RESTUserID(d.From),
+		ChatID:         d.ChatID,
+		Event:          d.Event,
+		URL:            d.URL,
+		Status:         d.Status.String(),
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		Error:          d.Error,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+type CallbackDeliveryPage struct {
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	Total      int64               `json:"total"`
+	Deliveries []*CallbackDelivery `json:"deliveries"`
+}
@@ -0,0 +1,113 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/email"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type (
+	// EmailReplies 记录回复邮件所需的收件人、主题及线程信息
+	EmailReplies struct {
+		mu      sync.Mutex
+		targets map[domain.ChannelMessageID]emailTarget
+	}
+
+	emailTarget struct {
+		target    email.ReplyTarget
+		expiresAt time.Time
+	}
+
+	EmailEventHandler struct {
+		client    *email.Client
+		replies   *EmailReplies
+		localizer *Localizer
+		log       logger.Logger
+	}
+)
+
+const emailReplyTTL = time.Hour
+
+func NewEmailReplies() *EmailReplies {
+	return &EmailReplies{targets: make(map[domain.ChannelMessageID]emailTarget)}
+}
+
+func (er *EmailReplies) Put(msgID domain.ChannelMessageID, target email.ReplyTarget) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range er.targets {
+		if now.After(t.expiresAt) {
+			delete(er.targets, id)
+		}
+	}
+	er.targets[msgID] = emailTarget{target: target, expiresAt: now.Add(emailReplyTTL)}
+}
+
+// Take 取出并删除回复信息，每封邮件只回复一次
+func (er *EmailReplies) Take(msgID domain.ChannelMessageID) (email.ReplyTarget, bool) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	t, ok := er.targets[msgID]
+	delete(er.targets, msgID)
+	if !ok || time.Now().After(t.expiresAt) {
+		return email.ReplyTarget{}, false
+	}
+	return t.target, true
+}
+
+func NewEmailEventHandler(log logger.Logger, localizer *Localizer, client *email.Client, replies *EmailReplies) mediator.EventHandler {
+	return &EmailEventHandler{log: log, localizer: localizer, client: client, replies: replies}
+}
+
+func (ev *EmailEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 在原邮件的线程中回复
+func (ev *EmailEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelEmail {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "email_from", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	target, ok := ev.replies.Take(e.Conversation.MessageID)
+	if !ok {
+		helper.Warn("email reply target is missing or expired")
+		return
+	}
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, log, e.From, e.Error)
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, log, e.From, e.Category)
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+
+	ctx, cancel := pkgCtx.GenContextWithTimeout(time.Minute)
+	defer cancel()
+	if err := ev.client.Reply(ctx, target, text); err != nil {
+		helper.Error("failed to send email", "to", target.To, "error", err.Error())
+	}
+}
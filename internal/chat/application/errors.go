@@ -0,0 +1,15 @@
+package application
+
+import "errors"
+
+// 应用层返回给用户的错误，通道通过Localizer转换为用户语言的文本
+var (
+	ErrNoActiveChat      = errors.New("没有进行中的会话")
+	ErrUserNoActiveChat  = errors.New("该用户没有进行中的会话")
+	ErrChatEnded         = errors.New("会话已结束")
+	ErrInvalidInvitation = errors.New("无效的邀请码")
+	ErrAccessDisabled    = errors.New("未启用访问控制")
+	ErrQuotaDisabled     = errors.New("未启用额度统计")
+	ErrVoiceDisabled     = errors.New("未启用语音消息")
+	ErrUnsupportedLocale = errors.New("unsupported locale")
+)
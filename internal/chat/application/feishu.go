@@ -0,0 +1,66 @@
+package application
+
+import (
+	"context"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/feishu"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type FeishuEventHandler struct {
+	client    *feishu.Client
+	localizer *Localizer
+	card      bool
+	log       logger.Logger
+}
+
+// NewFeishuEventHandler card为true时以消息卡片回复，可渲染markdown
+func NewFeishuEventHandler(log logger.Logger, localizer *Localizer, client *feishu.Client, card bool) mediator.EventHandler {
+	return &FeishuEventHandler{log: log, localizer: localizer, client: client, card: card}
+}
+
+func (ev *FeishuEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 回复触发prompt的消息，单聊及群聊均适用
+func (ev *FeishuEventHandler) Handle(_ context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelFeishu {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "feishu_user_id", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	msgID := string(e.Conversation.MessageID)
+
+	var err error
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		if ev.card {
+			err = ev.client.ReplyCard(ctx, msgID, e.Conversation.Completion)
+		} else {
+			err = ev.client.ReplyText(ctx, msgID, e.Conversation.Completion)
+		}
+
+	case domain.KindCoversationInterrupted:
+		err = ev.client.ReplyText(ctx, msgID, ev.localizer.ErrorReply(ctx, log, e.From, e.Error))
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		err = ev.client.ReplyText(ctx, msgID, ev.localizer.BlockedReply(ctx, log, e.From, e.Category))
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+	if err != nil {
+		helper.Error("failed to send message to feishu", "error", err.Error())
+	}
+}
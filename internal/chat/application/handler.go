@@ -2,7 +2,6 @@ package application
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -15,17 +14,19 @@ import (
 )
 
 type TelegramEventHandler struct {
-	bot *tgbotapi.BotAPI
-	log logger.Logger
+	bot       *tgbotapi.BotAPI
+	localizer *Localizer
+	log       logger.Logger
 }
 
 type WechatEventHandler struct {
-	wechat *officialaccount.OfficialAccount
-	log    logger.Logger
+	wechat    *officialaccount.OfficialAccount
+	localizer *Localizer
+	log       logger.Logger
 }
 
-func NewTelegramEventHandler(log logger.Logger, bot *tgbotapi.BotAPI) mediator.EventHandler {
-	return &TelegramEventHandler{log: log, bot: bot}
+func NewTelegramEventHandler(log logger.Logger, localizer *Localizer, bot *tgbotapi.BotAPI) mediator.EventHandler {
+	return &TelegramEventHandler{log: log, localizer: localizer, bot: bot}
 }
 
 func (ev *TelegramEventHandler) Listening() []mediator.EventKind {
@@ -33,6 +34,7 @@ func (ev *TelegramEventHandler) Listening() []mediator.EventKind {
 		domain.KindConversationCreated,
 		domain.KindCoversationInterrupted,
 		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
 	}
 }
 
@@ -78,11 +80,18 @@ func (ev *TelegramEventHandler) Handle(ctx context.Context, event mediator.Event
 		chattable = msg
 
 	case domain.KindCoversationInterrupted:
-		chattable = tgbotapi.NewMessage(chatID, fmt.Sprintf("[ERR] %s", e.Error.Error()))
+		chattable = tgbotapi.NewMessage(chatID, ev.localizer.ErrorReply(ctx, log, e.From, e.Error))
 		msg := chattable.(tgbotapi.MessageConfig)
 		msg.ReplyToMessageID = int(msgID)
 		chattable = msg
 		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		chattable = tgbotapi.NewMessage(chatID, ev.localizer.BlockedReply(ctx, log, e.From, e.Category))
+		msg := chattable.(tgbotapi.MessageConfig)
+		msg.ReplyToMessageID = int(msgID)
+		chattable = msg
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
 	}
 
 	if chattable != nil {
@@ -92,10 +101,11 @@ func (ev *TelegramEventHandler) Handle(ctx context.Context, event mediator.Event
 	}
 }
 
-func NewWechatEventHandler(log logger.Logger, wechat *officialaccount.OfficialAccount) mediator.EventHandler {
+func NewWechatEventHandler(log logger.Logger, localizer *Localizer, wechat *officialaccount.OfficialAccount) mediator.EventHandler {
 	return &WechatEventHandler{
-		log:    log,
-		wechat: wechat,
+		log:       log,
+		localizer: localizer,
+		wechat:    wechat,
 	}
 }
 
@@ -104,6 +114,7 @@ func (w *WechatEventHandler) Listening() []mediator.EventKind {
 		domain.KindConversationCreated,
 		domain.KindCoversationInterrupted,
 		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
 	}
 }
 
@@ -130,8 +141,16 @@ func (w *WechatEventHandler) Handle(ctx context.Context, ev mediator.Event) {
 		msg = &message.CustomerMessage{
 			ToUser:  string(event.Conversation.MessageID),
 			Msgtype: message.MsgTypeText,
-			Text:    &message.MediaText{Content: "[ERR] " + event.Error.Error()},
+			Text:    &message.MediaText{Content: w.localizer.ErrorReply(ctx, log, event.From, event.Error)},
+		}
+
+	case domain.KindConversationBlocked:
+		msg = &message.CustomerMessage{
+			ToUser:  string(event.Conversation.MessageID),
+			Msgtype: message.MsgTypeText,
+			Text:    &message.MediaText{Content: w.localizer.BlockedReply(ctx, log, event.From, event.Category)},
 		}
+		helper.Warn("conversation was blocked by moderation", "category", event.Category)
 	}
 
 	if msg != nil {
@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type KnowledgeApplication struct {
+	repo      domain.KnowledgeRepository
+	embedding domain.EmbeddingService
+	chunkSize int
+}
+
+// embedBatchSize 单次调用embedding接口的最大片段数
+const embedBatchSize = 16
+
+func NewKnowledgeApplication(repo domain.KnowledgeRepository, es domain.EmbeddingService, chunkSize int) *KnowledgeApplication {
+	return &KnowledgeApplication{repo: repo, embedding: es, chunkSize: chunkSize}
+}
+
+// Ingest 导入目录下的全部markdown文件，同名来源的旧片段会被替换
+func (ka *KnowledgeApplication) Ingest(ctx context.Context, log logger.Logger, dir string) (int, error) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	total := 0
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(d.Name()))
+		if ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+
+		source, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		source = filepath.ToSlash(source)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		chunks := domain.SplitMarkdown(source, string(data), ka.chunkSize)
+		if err := ka.embed(ctx, chunks); err != nil {
+			helper.Error("failed to embed document", "source", source, "error", err.Error())
+			return err
+		}
+		if err := ka.repo.Replace(ctx, source, chunks...); err != nil {
+			helper.Error("failed to save chunks", "source", source, "error", err.Error())
+			return err
+		}
+		helper.Info("ingested document", "source", source, "chunks", len(chunks))
+		total += len(chunks)
+		return nil
+	})
+	return total, err
+}
+
+func (ka *KnowledgeApplication) embed(ctx context.Context, chunks []*domain.Chunk) error {
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		inputs := make([]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			text := chunk.Content
+			if chunk.Heading != "" {
+				text = chunk.Heading + "\n" + text
+			}
+			inputs = append(inputs, text)
+		}
+		vectors, err := ka.embedding.Embed(ctx, inputs...)
+		if err != nil {
+			return err
+		}
+		for index, vector := range vectors {
+			chunks[start+index].Vector = vector
+		}
+	}
+	return nil
+}
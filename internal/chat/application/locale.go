@@ -0,0 +1,150 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
+)
+
+type (
+	// Localizer 决定回复用户时使用的语言：优先使用/lang保存的设置，其次是客户端上报的语言，
+	// 最后是缺省语言。客户端语言仅缓存在内存中，事件回调没有该信息时从缓存中获取
+	Localizer struct {
+		catalog *i18n.Catalog
+		repo    domain.LocaleRepository
+		mu      sync.RWMutex
+		hints   map[domain.From]localeHint
+		pruneAt time.Time
+	}
+
+	localeHint struct {
+		locale    string
+		expiresAt time.Time
+	}
+)
+
+const (
+	// 客户端语言在用户最后一次发消息后保留的时间
+	localeHintTTL = 24 * time.Hour
+	// 定期清理过期的客户端语言，避免每次写入都遍历
+	localeHintPruneInterval = 10 * time.Minute
+	// 缓存的上限，达到上限后不再记录新用户的客户端语言
+	maxLocaleHints = 100000
+)
+
+// errorKeys 已知错误对应的消息key，未列出的错误原样展示
+var errorKeys = []struct {
+	err error
+	key string
+}{
+	{domain.ErrAccessDenied, "error.access_denied"},
+	{domain.ErrAccessBlocked, "error.access_blocked"},
+	{domain.ErrInvitationRedeemed, "error.invitation_redeemed"},
+	{domain.ErrPermissionDenied, "error.permission_denied"},
+	{domain.ErrQuotaExceeded, "error.quota_exceeded"},
+	{domain.ErrBroadcastUnsupported, "error.broadcast_unsupported"},
+	{ErrNoActiveChat, "error.no_active_chat"},
+	{sql.ErrNoRows, "error.no_active_chat"},
+	{ErrUserNoActiveChat, "error.user_no_active_chat"},
+	{ErrChatEnded, "error.chat_ended"},
+	{ErrInvalidInvitation, "error.invalid_invitation"},
+	{ErrAccessDisabled, "error.access_disabled"},
+	{ErrQuotaDisabled, "error.quota_disabled"},
+	{ErrVoiceDisabled, "error.voice_disabled"},
+}
+
+func NewLocalizer(catalog *i18n.Catalog, repo domain.LocaleRepository) *Localizer {
+	return &Localizer{catalog: catalog, repo: repo, hints: make(map[domain.From]localeHint)}
+}
+
+func (l *Localizer) Catalog() *i18n.Catalog {
+	return l.catalog
+}
+
+// Hint 记录客户端上报的语言标签，例如Telegram的language_code，无法匹配时忽略
+func (l *Localizer) Hint(f domain.From, tag string) {
+	locale, ok := l.catalog.Match(tag)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.After(l.pruneAt) {
+		for from, hint := range l.hints {
+			if now.After(hint.expiresAt) {
+				delete(l.hints, from)
+			}
+		}
+		l.pruneAt = now.Add(localeHintPruneInterval)
+	}
+	if _, ok := l.hints[f]; !ok && len(l.hints) >= maxLocaleHints {
+		return
+	}
+	l.hints[f] = localeHint{locale: locale, expiresAt: now.Add(localeHintTTL)}
+}
+
+func (l *Localizer) Locale(ctx context.Context, log logger.Logger, f domain.From) string {
+	saved, err := l.repo.Get(ctx, f)
+	if err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to get user locale", "error", err.Error())
+	}
+	if locale, ok := l.catalog.Match(saved); ok {
+		return locale
+	}
+	l.mu.RLock()
+	hint, ok := l.hints[f]
+	l.mu.RUnlock()
+	if ok && time.Now().Before(hint.expiresAt) {
+		return hint.locale
+	}
+	return l.catalog.Fallback()
+}
+
+// SetLocale 保存用户选择的语言，tag无法匹配已有语言时返回ErrUnsupportedLocale
+func (l *Localizer) SetLocale(ctx context.Context, log logger.Logger, f domain.From, tag string) (string, error) {
+	locale, ok := l.catalog.Match(tag)
+	if !ok {
+		return "", ErrUnsupportedLocale
+	}
+	if err := l.repo.Save(ctx, f, locale); err != nil {
+		logger.NewHelper(log).WithContext(ctx).Error("failed to save user locale", "locale", locale, "error", err.Error())
+		return "", err
+	}
+	return locale, nil
+}
+
+func (l *Localizer) T(locale, key string, args ...interface{}) string {
+	return l.catalog.T(locale, key, args...)
+}
+
+// ErrorText 限速属于正常的业务反馈，不以[ERR]形式返回
+func (l *Localizer) ErrorText(locale string, err error) string {
+	var limited *domain.RateLimitedError
+	if errors.As(err, &limited) {
+		return l.T(locale, "error.rate_limited", int(math.Ceil(limited.RetryAfter.Seconds())))
+	}
+	for _, ek := range errorKeys {
+		if errors.Is(err, ek.err) {
+			return "[ERR] " + l.T(locale, ek.key)
+		}
+	}
+	return "[ERR] " + err.Error()
+}
+
+// ErrorReply 事件回调中以用户的语言展示错误
+func (l *Localizer) ErrorReply(ctx context.Context, log logger.Logger, f domain.From, err error) string {
+	return l.ErrorText(l.Locale(ctx, log, f), err)
+}
+
+// BlockedReply 内容未通过审核时的回复
+func (l *Localizer) BlockedReply(ctx context.Context, log logger.Logger, f domain.From, category string) string {
+	return l.T(l.Locale(ctx, log, f), "reply.blocked", category)
+}
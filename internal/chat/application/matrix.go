@@ -0,0 +1,115 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/matrix"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type (
+	// MatrixReplies 记录prompt所在的房间及thread，房间ID与事件ID过长无法作为消息ID落库
+	MatrixReplies struct {
+		mu      sync.Mutex
+		targets map[domain.ChannelMessageID]matrixTarget
+	}
+
+	matrixTarget struct {
+		roomID     string
+		threadRoot string
+		expiresAt  time.Time
+	}
+
+	MatrixEventHandler struct {
+		client    *matrix.Client
+		replies   *MatrixReplies
+		localizer *Localizer
+		log       logger.Logger
+	}
+)
+
+const matrixReplyTTL = time.Hour
+
+func NewMatrixReplies() *MatrixReplies {
+	return &MatrixReplies{targets: make(map[domain.ChannelMessageID]matrixTarget)}
+}
+
+// Put msgID为触发prompt的事件ID，threadRoot为空时直接回复该消息
+func (mr *MatrixReplies) Put(msgID domain.ChannelMessageID, roomID, threadRoot string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range mr.targets {
+		if now.After(t.expiresAt) {
+			delete(mr.targets, id)
+		}
+	}
+	mr.targets[msgID] = matrixTarget{roomID: roomID, threadRoot: threadRoot, expiresAt: now.Add(matrixReplyTTL)}
+}
+
+// Take 取出并删除回复位置，每个prompt只回复一次
+func (mr *MatrixReplies) Take(msgID domain.ChannelMessageID) (roomID, threadRoot string, ok bool) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	t, ok := mr.targets[msgID]
+	delete(mr.targets, msgID)
+	if !ok || time.Now().After(t.expiresAt) {
+		return "", "", false
+	}
+	return t.roomID, t.threadRoot, true
+}
+
+func NewMatrixEventHandler(log logger.Logger, localizer *Localizer, client *matrix.Client, replies *MatrixReplies) mediator.EventHandler {
+	return &MatrixEventHandler{log: log, localizer: localizer, client: client, replies: replies}
+}
+
+func (ev *MatrixEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 以m.relates_to回复触发prompt的消息
+func (ev *MatrixEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelMatrix {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "matrix_user_id", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	roomID, threadRoot, ok := ev.replies.Take(e.Conversation.MessageID)
+	if !ok {
+		helper.Warn("matrix reply target is missing or expired")
+		return
+	}
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, log, e.From, e.Error)
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, log, e.From, e.Category)
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	if _, err := ev.client.SendMessage(ctx, roomID, matrix.TextReply(text, string(e.Conversation.MessageID), threadRoot)); err != nil {
+		helper.Error("failed to send message to matrix", "error", err.Error())
+	}
+}
@@ -0,0 +1,153 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	QuotaApplication struct {
+		repo   domain.QuotaRepository
+		policy *domain.QuotaPolicy
+	}
+
+	// UsageEventHandler 被中断或拦截的对话不会保存为conversation，由该handler单独记录已消耗的token，
+	// 避免额度及用量报表漏算
+	UsageEventHandler struct {
+		repo domain.QuotaRepository
+		log  logger.Logger
+	}
+)
+
+func NewQuotaApplication(repo domain.QuotaRepository, policy *domain.QuotaPolicy) *QuotaApplication {
+	return &QuotaApplication{repo: repo, policy: policy}
+}
+
+// Check 用户或通道在任一周期内的用量达到上限时拒绝prompt
+func (qa *QuotaApplication) Check(ctx context.Context, log logger.Logger, f domain.From) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	uq, err := qa.repo.GetUserQuota(ctx, f)
+	if err != nil {
+		helper.Error("failed to get user quota", "error", err.Error())
+		return err
+	}
+
+	now := time.Now()
+	userLimit := qa.policy.UserLimit(uq)
+	channelLimit := qa.policy.ChannelLimit(f.Channel)
+	for _, period := range []domain.Period{domain.PeriodDaily, domain.PeriodMonthly} {
+		if limit := userLimit.Of(period); limit > 0 {
+			used, err := qa.repo.SumUserUsage(ctx, f, uq.Since(period, now))
+			if err != nil {
+				helper.Error("failed to sum user usage", "error", err.Error())
+				return err
+			}
+			if domain.Exceeded(used, limit) {
+				helper.Warn("user quota exceeded", "period", int(period), "used", used.Total(), "limit", limit)
+				return domain.ErrQuotaExceeded
+			}
+		}
+		if limit := channelLimit.Of(period); limit > 0 {
+			used, err := qa.repo.SumChannelUsage(ctx, f.Channel, domain.PeriodStart(period, now))
+			if err != nil {
+				helper.Error("failed to sum channel usage", "error", err.Error())
+				return err
+			}
+			if domain.Exceeded(used, limit) {
+				helper.Warn("channel quota exceeded", "period", int(period), "used", used.Total(), "limit", limit)
+				return domain.ErrQuotaExceeded
+			}
+		}
+	}
+	return nil
+}
+
+func (qa *QuotaApplication) Usage(ctx context.Context, log logger.Logger, f domain.From) (*QuotaUsage, error) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	uq, err := qa.repo.GetUserQuota(ctx, f)
+	if err != nil {
+		helper.Error("failed to get user quota", "error", err.Error())
+		return nil, err
+	}
+
+	now := time.Now()
+	limit := qa.policy.UserLimit(uq)
+	daily, err := qa.repo.SumUserUsage(ctx, f, uq.Since(domain.PeriodDaily, now))
+	if err != nil {
+		helper.Error("failed to sum user usage", "error", err.Error())
+		return nil, err
+	}
+	monthly, err := qa.repo.SumUserUsage(ctx, f, uq.Since(domain.PeriodMonthly, now))
+	if err != nil {
+		helper.Error("failed to sum user usage", "error", err.Error())
+		return nil, err
+	}
+	return &QuotaUsage{
+		DailyUsed:    daily.Total(),
+		DailyLimit:   limit.Daily,
+		MonthlyUsed:  monthly.Total(),
+		MonthlyLimit: limit.Monthly,
+	}, nil
+}
+
+// Reset 清零用户当前周期的用量
+func (qa *QuotaApplication) Reset(ctx context.Context, log logger.Logger, f domain.From) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	uq, err := qa.repo.GetUserQuota(ctx, f)
+	if err != nil {
+		helper.Error("failed to get user quota", "error", err.Error())
+		return err
+	}
+	uq.Reset()
+	if err = qa.repo.SaveUserQuota(ctx, uq); err != nil {
+		helper.Error("failed to save user quota", "error", err.Error())
+		return err
+	}
+	helper.Info("reset user quota", "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID)
+	return nil
+}
+
+// Raise 为用户设置独立的额度上限
+func (qa *QuotaApplication) Raise(ctx context.Context, log logger.Logger, f domain.From, limit domain.QuotaLimit) error {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	uq, err := qa.repo.GetUserQuota(ctx, f)
+	if err != nil {
+		helper.Error("failed to get user quota", "error", err.Error())
+		return err
+	}
+	uq.Raise(limit)
+	if err = qa.repo.SaveUserQuota(ctx, uq); err != nil {
+		helper.Error("failed to save user quota", "error", err.Error())
+		return err
+	}
+	helper.Info("raised user quota", "channel", f.Channel.String(), "channel_user_id", f.ChannelUserID,
+		"daily", limit.Daily, "monthly", limit.Monthly)
+	return nil
+}
+
+func NewUsageEventHandler(log logger.Logger, repo domain.QuotaRepository) mediator.EventHandler {
+	return &UsageEventHandler{log: log, repo: repo}
+}
+
+func (ev *UsageEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationBlocked,
+	}
+}
+
+func (ev *UsageEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	conv := e.Conversation
+	if conv.PromptTokens+conv.CompletionTokens == 0 {
+		return
+	}
+	if err := ev.repo.AddDiscardedUsage(ctx, e.ChatID, e.From, conv); err != nil {
+		logger.NewHelper(ev.log).Error("failed to add discarded usage", "chat_id", e.ChatID, "model", conv.Model,
+			"prompt_tokens", conv.PromptTokens, "completion_tokens", conv.CompletionTokens, "error", err.Error())
+	}
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type ReportApplication struct {
+	repo   domain.ReportRepository
+	prices domain.PriceTable
+}
+
+func NewReportApplication(repo domain.ReportRepository, prices domain.PriceTable) *ReportApplication {
+	return &ReportApplication{repo: repo, prices: prices}
+}
+
+// UsageReport 汇总时间段内的用量，费用按模型分别计算后再合并到分组
+func (ra *ReportApplication) UsageReport(ctx context.Context, log logger.Logger, period domain.ReportPeriod, d domain.ReportDimension) (*UsageReport, error) {
+	helper := logger.NewHelper(log).WithContext(ctx)
+	records, err := ra.repo.AggregateUsage(ctx, period, d)
+	if err != nil {
+		helper.Error("failed to aggregate usage", "group_by", string(d), "error", err.Error())
+		return nil, err
+	}
+
+	report := &UsageReport{From: period.From, To: period.To, GroupBy: string(d), Total: &UsageReportRow{Key: "total"}}
+	rows := make(map[string]*UsageReportRow)
+	for _, rec := range records {
+		key := rec.Key(d)
+		row, ok := rows[key]
+		if !ok {
+			row = &UsageReportRow{Key: key}
+			rows[key] = row
+			report.Rows = append(report.Rows, row)
+		}
+		cost := ra.prices.Cost(rec.Model, rec.Usage)
+		for _, r := range []*UsageReportRow{row, report.Total} {
+			r.Conversations += rec.Conversations
+			r.PromptTokens += rec.Usage.PromptTokens
+			r.CompletionTokens += rec.Usage.CompletionTokens
+			r.Cost += cost
+		}
+	}
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Cost > report.Rows[j].Cost
+	})
+	return report, nil
+}
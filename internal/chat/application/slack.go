@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/slack"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type SlackEventHandler struct {
+	client    *slack.Client
+	localizer *Localizer
+	log       logger.Logger
+}
+
+func NewSlackEventHandler(log logger.Logger, localizer *Localizer, client *slack.Client) mediator.EventHandler {
+	return &SlackEventHandler{log: log, localizer: localizer, client: client}
+}
+
+func (ev *SlackEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 将回复发送到prompt所在的thread
+func (ev *SlackEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelSlack {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "slack_user_id", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, log, e.From, e.Error)
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, log, e.From, e.Category)
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	channel, thread := domain.ParseSlackMessageID(e.Conversation.MessageID)
+	if _, err := ev.client.PostMessage(ctx, channel, thread, text); err != nil {
+		helper.Error("failed to send message to slack", "error", err.Error())
+	}
+}
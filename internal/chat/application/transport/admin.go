@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+// adminCommand 处理/admin子命令，权限由CommandRouter校验
+func adminCommand(admin *application.AdminApplication) CommandFunc {
+	return func(ctx context.Context, req *CommandRequest) (string, error) {
+		return handleAdmin(ctx, admin, req)
+	}
+}
+
+// handleAdmin 返回回复给管理员的文本
+func handleAdmin(ctx context.Context, admin *application.AdminApplication, req *CommandRequest) (string, error) {
+	log := req.Log
+	adminUsage := req.T("admin.usage")
+	sub, arg, _ := strings.Cut(req.Args, " ")
+	arg = strings.TrimSpace(arg)
+	switch sub {
+	case "stats":
+		stats, err := admin.Stats(ctx, log)
+		if err != nil {
+			return "", err
+		}
+		return req.T("admin.stats", stats.Users, stats.ActiveChats, stats.TodayConversations, stats.TodayTokens), nil
+
+	case "end", "block":
+		target, err := domain.ParseFrom(arg, req.From.Channel)
+		if err != nil {
+			return adminUsage, nil
+		}
+		if sub == "end" {
+			err = admin.End(ctx, log, target)
+		} else {
+			err = admin.Block(ctx, log, target)
+		}
+		if err != nil {
+			return "", err
+		}
+		return req.T("admin.handled", target.Channel.String(), target.ChannelUserID), nil
+
+	case "broadcast":
+		if arg == "" {
+			return adminUsage, nil
+		}
+		filter, text, err := parseBroadcastArgs(arg, time.Now())
+		if err != nil || text == "" {
+			return adminUsage, nil
+		}
+		b, err := admin.Broadcast(ctx, log, text, filter)
+		if err != nil {
+			return "", err
+		}
+		return req.T("admin.broadcast", b.Total, b.ID), nil
+
+	case "progress":
+		if arg == "" {
+			return adminUsage, nil
+		}
+		b, err := admin.BroadcastProgress(ctx, log, arg)
+		if err != nil {
+			return "", err
+		}
+		key := "admin.progress_running"
+		if b.Finished {
+			key = "admin.progress_finished"
+		}
+		return req.T(key, b.Sent, b.Failed, b.Total), nil
+
+	case "chat":
+		if arg == "" {
+			return adminUsage, nil
+		}
+		details, err := admin.Chat(ctx, log, arg)
+		if err != nil {
+			return "", err
+		}
+		data, _ := json.Marshal(details)
+		return string(data), nil
+
+	default:
+		return adminUsage, nil
+	}
+}
+
+// parseBroadcastArgs 解析群发内容前的channel=、active=筛选项，active支持Nd或time.Duration格式
+func parseBroadcastArgs(args string, now time.Time) (domain.UserFilter, string, error) {
+	var filter domain.UserFilter
+	for {
+		args = strings.TrimSpace(args)
+		token, rest, _ := strings.Cut(args, " ")
+		key, value, ok := strings.Cut(token, "=")
+		if !ok {
+			return filter, args, nil
+		}
+		switch key {
+		case "channel":
+			channel, err := domain.ParseChannel(value)
+			if err != nil {
+				return filter, "", err
+			}
+			filter.Channel = channel
+		case "active":
+			var d time.Duration
+			var err error
+			if days, found := strings.CutSuffix(value, "d"); found {
+				var n int
+				n, err = strconv.Atoi(days)
+				d = time.Duration(n) * 24 * time.Hour
+			} else {
+				d, err = time.ParseDuration(value)
+			}
+			if err != nil {
+				return filter, "", err
+			}
+			filter.ActiveSince = now.Add(-d)
+		default:
+			return filter, args, nil
+		}
+		args = rest
+	}
+}
@@ -0,0 +1,187 @@
+package transport
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type adminController struct {
+	admin *application.AdminApplication
+	keys  []string
+}
+
+var _ httpsrv.Controller = (*adminController)(nil)
+
+func NewAdminController(admin *application.AdminApplication, keys []string) httpsrv.Controller {
+	return &adminController{admin: admin, keys: keys}
+}
+
+func (ctrl *adminController) Slug() string {
+	return "/api/v1/admin"
+}
+
+func (ctrl *adminController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodGet,
+			Pattern: "/chats",
+			Func:    ctrl.ListChats,
+		},
+		{
+			Method:  http.MethodGet,
+			Pattern: "/chats/{chatID}",
+			Func:    ctrl.GetChat,
+		},
+		{
+			Method:  http.MethodPost,
+			Pattern: "/chats/{chatID}/end",
+			Func:    ctrl.EndChat,
+		},
+		{
+			Method:  http.MethodDelete,
+			Pattern: "/chats/{chatID}",
+			Func:    ctrl.DeleteChat,
+		},
+		{
+			Method:  http.MethodGet,
+			Pattern: "/users",
+			Func:    ctrl.ListUsers,
+		},
+	}
+}
+
+func (ctrl *adminController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: httpsrv.APIKeyAuth(ctrl.keys), Scope: httpsrv.ScopeController},
+	}
+}
+
+// ListChats 支持channel、user、status(active|ended)、pending、from、to过滤及page、page_size分页
+func (ctrl *adminController) ListChats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.ChatFilter{
+		ChannelUserID: domain.ChannelUserID(query.Get("user")),
+		Pending:       query.Get("pending") == "true",
+	}
+	var err error
+	if filter.Channel, err = parseChannelQuery(query.Get("channel")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch query.Get("status") {
+	case "":
+	case "active":
+		status := domain.StatusReady
+		filter.Status = &status
+	case "ended":
+		status := domain.StatusEnded
+		filter.Status = &status
+	default:
+		http.Error(w, "status must be active or ended", http.StatusBadRequest)
+		return
+	}
+	if from := query.Get("from"); from != "" {
+		if filter.CreatedFrom, _, err = domain.ParseTime(from); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		t, dateOnly, err := domain.ParseTime(to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.CreatedTo = t
+	}
+
+	page, err := ctrl.admin.ListChats(r.Context(), logger.FromContext(r.Context()), filter, parsePagination(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (ctrl *adminController) GetChat(w http.ResponseWriter, r *http.Request) {
+	dto, err := ctrl.admin.Chat(r.Context(), logger.FromContext(r.Context()), chi.URLParam(r, "chatID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
+
+func (ctrl *adminController) EndChat(w http.ResponseWriter, r *http.Request) {
+	if err := ctrl.admin.EndChat(r.Context(), logger.FromContext(r.Context()), chi.URLParam(r, "chatID")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ctrl *adminController) DeleteChat(w http.ResponseWriter, r *http.Request) {
+	if err := ctrl.admin.DeleteChat(r.Context(), logger.FromContext(r.Context()), chi.URLParam(r, "chatID")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ctrl *adminController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	channel, err := parseChannelQuery(r.URL.Query().Get("channel"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := ctrl.admin.ListUsers(r.Context(), logger.FromContext(r.Context()), channel, parsePagination(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseChannelQuery(name string) (domain.Channel, error) {
+	if name == "" {
+		return 0, nil
+	}
+	return domain.ParseChannel(name)
+}
+
+func parsePagination(r *http.Request) domain.Pagination {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	return domain.NewPagination(page, size)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// writeError 仅向客户端展示已知的业务错误，其他错误已记录日志，只返回状态码
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, application.ErrChatEnded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+// CLI 终端交互通道，使用完整的应用层，便于在本地调试prompt及人设
+type CLI struct {
+	app     *application.Application
+	router  *CommandRouter
+	handler *application.CLIEventHandler
+	from    domain.From
+	lang    string
+	in      io.Reader
+	out     io.Writer
+	log     logger.Logger
+	seq     int
+}
+
+// cliReplyTimeout 略大于应用层调用LLM的超时时间
+const cliReplyTimeout = 4 * time.Minute
+
+func NewCLI(log logger.Logger, app *application.Application, router *CommandRouter, handler *application.CLIEventHandler, user, lang string, in io.Reader, out io.Writer) *CLI {
+	return &CLI{
+		app:     app,
+		router:  router,
+		handler: handler,
+		from:    domain.From{Channel: domain.ChannelCLI, ChannelUserID: domain.ChannelUserID(user)},
+		lang:    lang,
+		in:      in,
+		out:     out,
+		log:     log,
+	}
+}
+
+// Run 逐行读取输入直到EOF、/quit或ctx结束，每条prompt等待回复后再读取下一行
+func (c *CLI) Run(ctx context.Context) error {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(c.in)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		errs <- scanner.Err()
+	}()
+
+	_, _ = fmt.Fprintln(c.out, c.router.Localize(ctx, &Envelope{From: c.from, Log: c.log, Language: c.lang}, "cli.welcome"))
+	for {
+		_, _ = fmt.Fprint(c.out, "you> ")
+		select {
+		case <-ctx.Done():
+			_, _ = fmt.Fprintln(c.out)
+			return nil
+		case err := <-errs:
+			_, _ = fmt.Fprintln(c.out)
+			return err
+		case line := <-lines:
+			if !c.handle(ctx, line) {
+				return nil
+			}
+		}
+	}
+}
+
+// handle 返回false时退出REPL
+func (c *CLI) handle(ctx context.Context, line string) bool {
+	c.seq++
+	log := logger.With(c.log, "cli_user", c.from.ChannelUserID, "cli_message_id", c.seq)
+	text := strings.TrimSpace(line)
+	switch {
+	case text == "":
+		return true
+	case text == "/quit" || text == "/exit":
+		return false
+	}
+
+	msgID := domain.ChannelMessageID(strconv.Itoa(c.seq))
+	env := &Envelope{From: c.from, Text: text, MessageID: msgID, Log: log, Language: c.lang}
+	if reply, ok := c.router.Reply(ctx, env); ok {
+		c.handler.Print(reply)
+		return true
+	}
+
+	// 丢弃上一条超时后才到达的回复信号
+	select {
+	case <-c.handler.Replied():
+	default:
+	}
+	if err := c.app.Prompt(ctx, log, c.from, text, msgID); err != nil {
+		c.handler.Print(c.router.ErrorText(ctx, env, err))
+		return true
+	}
+	select {
+	case <-ctx.Done():
+	case <-c.handler.Replied():
+	case <-time.After(cliReplyTimeout):
+		c.handler.Print("[ERR] " + c.router.Localize(ctx, env, "error.reply_timeout"))
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	// Envelope 各通道将入站消息转换为统一格式后交给CommandRouter处理
+	Envelope struct {
+		From      domain.From
+		Text      string
+		MessageID domain.ChannelMessageID
+		Thread    string // 会话所在的thread，参见domain.Chat.Thread
+		Log       logger.Logger
+		Language  string // 客户端上报的语言标签，例如Telegram的language_code，没有时为空
+
+		locale string
+	}
+
+	// CommandRequest Args为命令后的原始文本，Fields为按空白拆分后的参数，Locale为回复使用的语言
+	CommandRequest struct {
+		*Envelope
+		Args      string
+		Fields    []string
+		Locale    string
+		localizer *application.Localizer
+	}
+
+	// CommandFunc 返回需要立即回复的文本，返回error时由通道决定如何展示
+	CommandFunc func(ctx context.Context, req *CommandRequest) (string, error)
+
+	// Permission 命令的使用权限
+	Permission int
+
+	// Command 命令名称不含/，且需满足Telegram的命名规则，以便同步到各平台的命令菜单。
+	// Args及Description为消息key，展示时按用户的语言翻译
+	Command struct {
+		Name        string
+		Args        string // 参数说明的消息key，翻译后例如 on|off
+		Description string
+		Permission  Permission
+		MinArgs     int      // 参数个数不足时回复用法
+		Choices     []string // 首个参数的可选值，为空时不限制
+		Handle      CommandFunc
+	}
+
+	// Authorizer 校验管理员权限，*application.AdminApplication实现了该接口
+	Authorizer interface {
+		Authorize(ctx context.Context, log logger.Logger, f domain.From) error
+		// IsAdmin 仅用于决定是否展示管理员命令，不记录日志
+		IsAdmin(f domain.From) bool
+	}
+
+	// CommandRouter 与通道无关的命令路由，各通道共用同一份命令注册
+	CommandRouter struct {
+		auth      Authorizer
+		localizer *application.Localizer
+		commands  map[string]*Command
+		order     []*Command
+	}
+)
+
+const (
+	PermissionUser Permission = iota
+	PermissionAdmin
+)
+
+var commandName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// NewCommandRouter auth为nil时拒绝所有管理员命令
+func NewCommandRouter(auth Authorizer, localizer *application.Localizer) *CommandRouter {
+	return &CommandRouter{auth: auth, localizer: localizer, commands: make(map[string]*Command)}
+}
+
+func (cr *CommandRouter) Register(cmd Command) error {
+	if !commandName.MatchString(cmd.Name) {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Handle == nil {
+		return fmt.Errorf("command %s has no handler", cmd.Name)
+	}
+	if _, ok := cr.commands[cmd.Name]; ok {
+		return fmt.Errorf("duplicated command %s", cmd.Name)
+	}
+	cr.commands[cmd.Name] = &cmd
+	cr.order = append(cr.order, &cmd)
+	return nil
+}
+
+// Commands 按注册顺序返回已注册的命令，admin为false时不包含管理员命令
+func (cr *CommandRouter) Commands(admin bool) []Command {
+	commands := make([]Command, 0, len(cr.order))
+	for _, cmd := range cr.order {
+		if cmd.Permission == PermissionAdmin && !admin {
+			continue
+		}
+		commands = append(commands, *cmd)
+	}
+	return commands
+}
+
+// IsAdmin 未配置Authorizer时总是返回false
+func (cr *CommandRouter) IsAdmin(f domain.From) bool {
+	return cr.auth != nil && cr.auth.IsAdmin(f)
+}
+
+// Help 列出用户可用的命令及说明，管理员额外展示管理员命令
+func (cr *CommandRouter) Help(locale string, f domain.From) string {
+	var b strings.Builder
+	b.WriteString(cr.T(locale, "router.help"))
+	for _, cmd := range cr.Commands(cr.IsAdmin(f)) {
+		b.WriteString("\n")
+		b.WriteString(cr.Usage(locale, cmd))
+		if cmd.Description != "" {
+			b.WriteString(" - ")
+			b.WriteString(cr.T(locale, cmd.Description))
+		}
+	}
+	return b.String()
+}
+
+// Usage 命令的用法，例如"/kb on|off"
+func (cr *CommandRouter) Usage(locale string, cmd Command) string {
+	if cmd.Args == "" {
+		return "/" + cmd.Name
+	}
+	return "/" + cmd.Name + " " + cr.T(locale, cmd.Args)
+}
+
+func (cr *CommandRouter) T(locale, key string, args ...interface{}) string {
+	return cr.localizer.T(locale, key, args...)
+}
+
+// Hint 记录客户端上报的语言，供没有语言信息的事件回调使用
+func (cr *CommandRouter) Hint(env *Envelope) {
+	cr.localizer.Hint(env.From, env.Language)
+}
+
+// Locale 消息回复使用的语言，解析结果缓存在env中
+func (cr *CommandRouter) Locale(ctx context.Context, env *Envelope) string {
+	if env.locale == "" {
+		cr.Hint(env)
+		env.locale = cr.localizer.Locale(ctx, env.Log, env.From)
+	}
+	return env.locale
+}
+
+// Localize 以消息发送者的语言翻译消息
+func (cr *CommandRouter) Localize(ctx context.Context, env *Envelope, key string, args ...interface{}) string {
+	return cr.T(cr.Locale(ctx, env), key, args...)
+}
+
+// ErrorText 以用户的语言展示错误，限速提示不带[ERR]前缀
+func (cr *CommandRouter) ErrorText(ctx context.Context, env *Envelope, err error) string {
+	return cr.localizer.ErrorText(cr.Locale(ctx, env), err)
+}
+
+// RequiresArgs 需要参数的命令无法作为菜单按钮直接触发
+func (cmd Command) RequiresArgs() bool {
+	return cmd.MinArgs > 0 || len(cmd.Choices) > 0
+}
+
+// Dispatch 执行消息中的命令，ok为false表示不是已注册的命令，由通道作为prompt处理
+func (cr *CommandRouter) Dispatch(ctx context.Context, env *Envelope) (reply string, ok bool, err error) {
+	// 非命令消息同样需要记录客户端语言
+	cr.Hint(env)
+	name, args := parseCommand(env.Text)
+	cmd, ok := cr.commands[strings.TrimPrefix(name, "/")]
+	if name == "" || !ok {
+		return "", false, nil
+	}
+
+	if cmd.Permission == PermissionAdmin {
+		if cr.auth == nil {
+			return "", true, domain.ErrPermissionDenied
+		}
+		if err = cr.auth.Authorize(ctx, env.Log, env.From); err != nil {
+			return "", true, err
+		}
+	}
+
+	locale := cr.Locale(ctx, env)
+	req := &CommandRequest{Envelope: env, Args: args, Fields: strings.Fields(args), Locale: locale, localizer: cr.localizer}
+	if len(req.Fields) < cmd.MinArgs || (len(cmd.Choices) > 0 && !containsChoice(cmd.Choices, req.Fields)) {
+		return cr.T(locale, "router.usage", cr.Usage(locale, *cmd)), true, nil
+	}
+	reply, err = cmd.Handle(ctx, req)
+	return reply, true, err
+}
+
+// Reply 与Dispatch相同，error经ErrorText转换后作为回复文本
+func (cr *CommandRouter) Reply(ctx context.Context, env *Envelope) (string, bool) {
+	reply, ok, err := cr.Dispatch(ctx, env)
+	if err != nil {
+		return cr.ErrorText(ctx, env, err), ok
+	}
+	return reply, ok
+}
+
+// T 以请求的语言翻译消息
+func (req *CommandRequest) T(key string, args ...interface{}) string {
+	return req.localizer.T(req.Locale, key, args...)
+}
+
+func containsChoice(choices []string, fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	for _, c := range choices {
+		if fields[0] == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAuthorizer struct {
+	admin domain.From
+}
+
+func (fa fakeAuthorizer) Authorize(_ context.Context, _ logger.Logger, f domain.From) error {
+	if !fa.IsAdmin(f) {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+func (fa fakeAuthorizer) IsAdmin(f domain.From) bool {
+	return f == fa.admin
+}
+
+type fakeLocaleRepository map[domain.From]string
+
+func (repo fakeLocaleRepository) Get(_ context.Context, f domain.From) (string, error) {
+	return repo[f], nil
+}
+
+func (repo fakeLocaleRepository) Save(_ context.Context, f domain.From, locale string) error {
+	repo[f] = locale
+	return nil
+}
+
+func newTestLocalizer(t *testing.T, repo domain.LocaleRepository) *application.Localizer {
+	catalog, err := i18n.Load(fstest.MapFS{
+		"zh.yml": {Data: []byte("router.usage: \"用法: %s\"\nrouter.help: \"可用命令:\"\nkb: 启用或关闭知识库检索\ninvite.args: <邀请码>\nerror.permission_denied: 无权执行该命令\n")},
+		"en.yml": {Data: []byte("router.usage: \"Usage: %s\"\nrouter.help: \"Available commands:\"\nkb: Turn knowledge base on or off\ninvite.args: <code>\nerror.permission_denied: Permission denied\n")},
+	}, "zh")
+	assert.NoError(t, err)
+	return application.NewLocalizer(catalog, repo)
+}
+
+func TestCommandRouter(t *testing.T) {
+	admin := domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "1"}
+	user := domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "2"}
+	router := NewCommandRouter(fakeAuthorizer{admin: admin}, newTestLocalizer(t, fakeLocaleRepository{}))
+
+	echo := func(_ context.Context, req *CommandRequest) (string, error) {
+		return strings.Join(req.Fields, ","), nil
+	}
+	assert.NoError(t, router.Register(Command{Name: "kb", Args: "on|off", Description: "kb", Choices: []string{"on", "off"}, Handle: echo}))
+	assert.NoError(t, router.Register(Command{Name: "invite", Args: "invite.args", MinArgs: 1, Handle: echo}))
+	assert.NoError(t, router.Register(Command{Name: "admin", Permission: PermissionAdmin, Handle: func(_ context.Context, req *CommandRequest) (string, error) {
+		return req.Args, nil
+	}}))
+	assert.EqualError(t, router.Register(Command{Name: "kb", Handle: echo}), "duplicated command kb")
+	assert.Error(t, router.Register(Command{Name: "Bad-Name", Handle: echo}))
+	assert.Error(t, router.Register(Command{Name: "nohandler"}))
+
+	ctx := context.Background()
+	dispatch := func(from domain.From, text string) (string, bool, error) {
+		return router.Dispatch(ctx, &Envelope{From: from, Text: text, Log: logger.Default()})
+	}
+
+	reply, ok, err := dispatch(user, " /kb  on ")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "on", reply)
+
+	reply, ok, _ = dispatch(user, "/kb maybe")
+	assert.True(t, ok)
+	assert.Equal(t, "用法: /kb on|off", reply)
+	reply, _, _ = dispatch(user, "/invite")
+	assert.Equal(t, "用法: /invite <邀请码>", reply)
+
+	// 非命令及未注册的命令由通道作为prompt处理
+	_, ok, _ = dispatch(user, "hello")
+	assert.False(t, ok)
+	_, ok, _ = dispatch(user, "/etc/hosts 是什么")
+	assert.False(t, ok)
+
+	_, ok, err = dispatch(user, "/admin broadcast hi")
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	reply, ok = router.Reply(ctx, &Envelope{From: user, Text: "/admin stats", Log: logger.Default()})
+	assert.True(t, ok)
+	assert.Equal(t, "[ERR] 无权执行该命令", reply)
+	reply, _, err = dispatch(admin, "/admin broadcast hi  there")
+	assert.NoError(t, err)
+	assert.Equal(t, "broadcast hi  there", reply)
+
+	names := func(commands []Command) []string {
+		var ns []string
+		for _, cmd := range commands {
+			ns = append(ns, router.Usage("zh", cmd))
+		}
+		return ns
+	}
+	assert.Equal(t, []string{"/kb on|off", "/invite <邀请码>"}, names(router.Commands(false)))
+	assert.Equal(t, []string{"/kb on|off", "/invite <邀请码>", "/admin"}, names(router.Commands(true)))
+	assert.Equal(t, "可用命令:\n/kb on|off - 启用或关闭知识库检索\n/invite <邀请码>", router.Help("zh", user))
+	assert.Equal(t, "可用命令:\n/kb on|off - 启用或关闭知识库检索\n/invite <邀请码>\n/admin", router.Help("zh", admin))
+
+	// 未配置Authorizer时拒绝所有管理员命令
+	noAuth := NewCommandRouter(nil, newTestLocalizer(t, fakeLocaleRepository{}))
+	assert.NoError(t, noAuth.Register(Command{Name: "admin", Permission: PermissionAdmin, Handle: echo}))
+	_, ok, err = noAuth.Dispatch(ctx, &Envelope{From: admin, Text: "/admin"})
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+}
+
+func TestCommandRouterLocale(t *testing.T) {
+	user := domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "1"}
+	repo := fakeLocaleRepository{}
+	router := NewCommandRouter(nil, newTestLocalizer(t, repo))
+	assert.NoError(t, router.Register(Command{Name: "invite", Args: "invite.args", MinArgs: 1, Handle: func(_ context.Context, req *CommandRequest) (string, error) {
+		return req.Locale, nil
+	}}))
+
+	ctx := context.Background()
+	reply := func(language string) string {
+		text, ok := router.Reply(ctx, &Envelope{From: user, Text: "/invite", Language: language, Log: logger.Default()})
+		assert.True(t, ok)
+		return text
+	}
+	assert.Equal(t, "用法: /invite <邀请码>", reply(""))
+	assert.Equal(t, "Usage: /invite <code>", reply("en-US"))
+	// 客户端语言会被记住，供没有语言信息的消息使用
+	assert.Equal(t, "Usage: /invite <code>", reply(""))
+	assert.Equal(t, "Usage: /invite <code>", reply("fr"))
+
+	// 用户保存的语言优先于客户端语言
+	repo[user] = "zh"
+	assert.Equal(t, "用法: /invite <邀请码>", reply("en"))
+	assert.Equal(t, "[ERR] 无权执行该命令", router.ErrorText(ctx, &Envelope{From: user, Log: logger.Default()}, domain.ErrPermissionDenied))
+	assert.Equal(t, "[ERR] boom", router.ErrorText(ctx, &Envelope{From: user, Log: logger.Default()}, errors.New("boom")))
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+)
+
+// NewDefaultCommandRouter 注册各通道共用的内置命令，admin为nil时不注册/admin
+func NewDefaultCommandRouter(app *application.Application, admin *application.AdminApplication, localizer *application.Localizer) *CommandRouter {
+	// 避免将nil指针包装为非nil的接口
+	var auth Authorizer
+	if admin != nil {
+		auth = admin
+	}
+	router := NewCommandRouter(auth, localizer)
+
+	commands := []Command{
+		{
+			Name:        "start",
+			Description: "command.start",
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				if err := app.NewThreadChat(ctx, req.Log, req.From, req.Thread); err != nil {
+					return "", err
+				}
+				return req.T("reply.start"), nil
+			},
+		},
+		{
+			Name:        "end",
+			Description: "command.end",
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				app.EndThread(ctx, req.Log, req.From, req.Thread)
+				return req.T("reply.end"), nil
+			},
+		},
+		{
+			Name:        "current",
+			Description: "command.current",
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				details, err := app.GetThread(ctx, req.Log, req.From, req.Thread)
+				if err != nil {
+					return "", err
+				}
+				data, _ := json.Marshal(details)
+				return string(data), nil
+			},
+		},
+		{
+			Name:        "kb",
+			Args:        "command.kb.args",
+			Description: "command.kb",
+			Choices:     []string{"on", "off"},
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				on := req.Fields[0] == "on"
+				if err := app.ToggleThreadKnowledge(ctx, req.Log, req.From, req.Thread, on); err != nil {
+					return "", err
+				}
+				if on {
+					return req.T("reply.kb_on"), nil
+				}
+				return req.T("reply.kb_off"), nil
+			},
+		},
+		{
+			Name:        "usage",
+			Description: "command.usage",
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				usage, err := app.Usage(ctx, req.Log, req.From)
+				if err != nil {
+					return "", err
+				}
+				return formatUsage(req, usage), nil
+			},
+		},
+		{
+			Name:        "invite",
+			Args:        "command.invite.args",
+			Description: "command.invite",
+			MinArgs:     1,
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				if err := app.Redeem(ctx, req.Log, req.From, req.Fields[0]); err != nil {
+					return "", err
+				}
+				return req.T("reply.invite"), nil
+			},
+		},
+		{
+			Name:        "lang",
+			Args:        "command.lang.args",
+			Description: "command.lang",
+			Handle: func(ctx context.Context, req *CommandRequest) (string, error) {
+				if len(req.Fields) > 0 {
+					locale, err := localizer.SetLocale(ctx, req.Log, req.From, req.Fields[0])
+					if err == nil {
+						return localizer.T(locale, "reply.lang"), nil
+					}
+					if !errors.Is(err, application.ErrUnsupportedLocale) {
+						return "", err
+					}
+				}
+				locales := localizer.Catalog().Locales()
+				return req.T("reply.lang_current", req.Locale, strings.Join(locales, ", ")), nil
+			},
+		},
+	}
+	commands = append(commands, Command{
+		Name:        "help",
+		Description: "command.help",
+		Handle: func(_ context.Context, req *CommandRequest) (string, error) {
+			return router.Help(req.Locale, req.From), nil
+		},
+	})
+	if admin != nil {
+		commands = append(commands, Command{
+			Name:        "admin",
+			Args:        "command.admin.args",
+			Description: "command.admin",
+			Permission:  PermissionAdmin,
+			Handle:      adminCommand(admin),
+		})
+	}
+
+	for _, cmd := range commands {
+		if err := router.Register(cmd); err != nil {
+			panic(err)
+		}
+	}
+	return router
+}
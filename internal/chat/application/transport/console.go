@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+)
+
+//go:embed console
+var consoleFS embed.FS
+
+// consoleController 管理后台页面，数据均来自/api/v1/admin及/api/v1/reports接口
+type consoleController struct {
+	keys   []string
+	static http.Handler
+}
+
+var _ httpsrv.Controller = (*consoleController)(nil)
+
+func NewConsoleController(keys []string) httpsrv.Controller {
+	sub, err := fs.Sub(consoleFS, "console")
+	if err != nil {
+		panic(err)
+	}
+	return &consoleController{
+		keys:   keys,
+		static: http.StripPrefix("/admin", http.FileServer(http.FS(sub))),
+	}
+}
+
+func (ctrl *consoleController) Slug() string {
+	return "/admin"
+}
+
+func (ctrl *consoleController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodGet,
+			Pattern: "/*",
+			Func:    ctrl.Static,
+		},
+	}
+}
+
+func (ctrl *consoleController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: httpsrv.APIKeyAuth(ctrl.keys), Scope: httpsrv.ScopeController},
+	}
+}
+
+func (ctrl *consoleController) Static(w http.ResponseWriter, r *http.Request) {
+	// 页面使用相对路径引用静态资源
+	if r.URL.Path == ctrl.Slug() {
+		http.Redirect(w, r, ctrl.Slug()+"/", http.StatusMovedPermanently)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'")
+	ctrl.static.ServeHTTP(w, r)
+}
@@ -0,0 +1,257 @@
+(function () {
+  'use strict';
+
+  var state = { chatQuery: '', chatPage: 1, userQuery: '', userPage: 1 };
+
+  function $(id) { return document.getElementById(id); }
+
+  function showError(err) {
+    var el = $('error');
+    if (!err) {
+      el.classList.add('hidden');
+      return;
+    }
+    el.textContent = String(err.message || err);
+    el.classList.remove('hidden');
+  }
+
+  // 与页面相同的Basic认证凭据由浏览器随同源请求发送，修改状态的请求须带有X-Requested-With
+  function api(method, path) {
+    var headers = { Accept: 'application/json', 'X-Requested-With': 'XMLHttpRequest' };
+    return fetch(path, { method: method, credentials: 'same-origin', headers: headers })
+      .then(function (resp) {
+        if (!resp.ok) {
+          return resp.text().then(function (text) { throw new Error(resp.status + ' ' + text); });
+        }
+        showError(null);
+        return resp.status === 204 ? null : resp.json();
+      })
+      .catch(function (err) {
+        showError(err);
+        throw err;
+      });
+  }
+
+  function query(form) {
+    var params = new URLSearchParams();
+    new FormData(form).forEach(function (value, key) {
+      if (value !== '') {
+        params.append(key, value);
+      }
+    });
+    return params;
+  }
+
+  function cell(row, text, className) {
+    var td = document.createElement('td');
+    td.textContent = text === undefined || text === null ? '' : String(text);
+    if (className) {
+      td.className = className;
+    }
+    row.appendChild(td);
+    return td;
+  }
+
+  function badge(text, className) {
+    var span = document.createElement('span');
+    span.className = 'badge ' + (className || '');
+    span.textContent = text;
+    return span;
+  }
+
+  function button(text, onClick, className) {
+    var btn = document.createElement('button');
+    btn.type = 'button';
+    btn.textContent = text;
+    if (className) {
+      btn.className = className;
+    }
+    btn.addEventListener('click', onClick);
+    return btn;
+  }
+
+  function time(value) {
+    return value ? new Date(value).toLocaleString() : '';
+  }
+
+  function pager(el, page, pageSize, total, go) {
+    el.textContent = '';
+    var pages = Math.max(1, Math.ceil(total / pageSize));
+    var prev = button('上一页', function () { go(page - 1); });
+    prev.disabled = page <= 1;
+    var next = button('下一页', function () { go(page + 1); });
+    next.disabled = page >= pages;
+    var info = document.createElement('span');
+    info.textContent = '第 ' + page + ' / ' + pages + ' 页，共 ' + total + ' 条';
+    el.appendChild(prev);
+    el.appendChild(info);
+    el.appendChild(next);
+  }
+
+  function switchTab(name) {
+    document.querySelectorAll('nav button').forEach(function (btn) {
+      btn.classList.toggle('active', btn.dataset.tab === name);
+    });
+    document.querySelectorAll('.tab').forEach(function (tab) {
+      tab.classList.toggle('active', tab.id === name);
+    });
+  }
+
+  function loadChats(page) {
+    state.chatPage = page || 1;
+    var params = new URLSearchParams(state.chatQuery);
+    params.set('page', state.chatPage);
+    return api('GET', '/api/v1/admin/chats?' + params.toString()).then(function (data) {
+      var tbody = $('chat-rows');
+      tbody.textContent = '';
+      data.chats.forEach(function (chat) {
+        var row = document.createElement('tr');
+        cell(row, chat.id, 'id');
+        cell(row, chat.channel);
+        cell(row, chat.channel_user_id);
+        var status = cell(row, '');
+        status.appendChild(chat.ended ? badge('已结束') : badge('进行中', 'active'));
+        if (chat.pending) {
+          status.appendChild(badge('等待回复', 'pending'));
+        }
+        cell(row, chat.counts);
+        cell(row, time(chat.created_at));
+        cell(row, time(chat.updated_at));
+        cell(row, '').appendChild(button('查看', function () { showChat(chat.id); }));
+        tbody.appendChild(row);
+      });
+      pager($('chat-pager'), data.page, data.page_size, data.total, loadChats);
+    });
+  }
+
+  function appendMessage(el, className, label, text) {
+    var div = document.createElement('div');
+    div.className = 'message ' + className;
+    div.textContent = label + text;
+    el.appendChild(div);
+  }
+
+  function showChat(id) {
+    return api('GET', '/api/v1/admin/chats/' + encodeURIComponent(id)).then(function (chat) {
+      var el = $('chat-detail');
+      el.textContent = '';
+      var title = document.createElement('h2');
+      title.textContent = '会话 ' + chat.id + (chat.ended ? '（已结束）' : '');
+      el.appendChild(title);
+
+      (chat.previous || []).forEach(function (conv) {
+        appendMessage(el, 'prompt', '用户: ', conv.prompt);
+        (conv.tool_calls || []).forEach(function (call) {
+          appendMessage(el, 'completion', '工具 ' + call.name + ': ', call.error || call.result || '');
+        });
+        appendMessage(el, 'completion', '回复: ', conv.completion);
+      });
+      if (chat.current) {
+        appendMessage(el, 'current', '等待回复: ', chat.current.prompt);
+      }
+
+      var actions = document.createElement('p');
+      if (!chat.ended) {
+        actions.appendChild(button('结束会话', function () {
+          if (confirm('确认结束该会话？')) {
+            api('POST', '/api/v1/admin/chats/' + encodeURIComponent(chat.id) + '/end').then(function () {
+              showChat(chat.id);
+              loadChats(state.chatPage);
+            });
+          }
+        }));
+      }
+      actions.appendChild(button('删除会话', function () {
+        if (confirm('删除后无法恢复，确认删除？')) {
+          api('DELETE', '/api/v1/admin/chats/' + encodeURIComponent(chat.id)).then(function () {
+            el.classList.add('hidden');
+            loadChats(state.chatPage);
+          });
+        }
+      }, 'danger'));
+      el.appendChild(actions);
+      el.classList.remove('hidden');
+    });
+  }
+
+  function loadUsers(page) {
+    state.userPage = page || 1;
+    var params = new URLSearchParams(state.userQuery);
+    params.set('page', state.userPage);
+    return api('GET', '/api/v1/admin/users?' + params.toString()).then(function (data) {
+      var tbody = $('user-rows');
+      tbody.textContent = '';
+      data.users.forEach(function (user) {
+        var row = document.createElement('tr');
+        cell(row, user.channel);
+        cell(row, user.channel_user_id, 'id');
+        cell(row, user.chats);
+        cell(row, user.conversations);
+        cell(row, user.prompt_tokens);
+        cell(row, user.completion_tokens);
+        cell(row, time(user.last_active_at));
+        cell(row, '').appendChild(button('会话', function () {
+          var form = $('chat-filter');
+          form.reset();
+          form.elements.channel.value = user.channel;
+          form.elements.user.value = user.channel_user_id;
+          state.chatQuery = query(form).toString();
+          switchTab('chats');
+          loadChats(1);
+        }));
+        tbody.appendChild(row);
+      });
+      pager($('user-pager'), data.page, data.page_size, data.total, loadUsers);
+    });
+  }
+
+  function loadUsage() {
+    var params = query($('usage-filter'));
+    var csv = new URLSearchParams(params);
+    csv.set('format', 'csv');
+    $('usage-csv').href = '/api/v1/reports/usage?' + csv.toString();
+    return api('GET', '/api/v1/reports/usage?' + params.toString()).then(function (report) {
+      var tbody = $('usage-rows');
+      tbody.textContent = '';
+      (report.rows || []).concat([report.total]).forEach(function (item) {
+        var row = document.createElement('tr');
+        cell(row, item.key);
+        cell(row, item.conversations);
+        cell(row, item.prompt_tokens);
+        cell(row, item.completion_tokens);
+        cell(row, item.cost.toFixed(4));
+        tbody.appendChild(row);
+      });
+    });
+  }
+
+  document.querySelectorAll('nav button').forEach(function (btn) {
+    btn.addEventListener('click', function () {
+      switchTab(btn.dataset.tab);
+      if (btn.dataset.tab === 'users') {
+        loadUsers(state.userPage);
+      } else if (btn.dataset.tab === 'usage') {
+        loadUsage();
+      }
+    });
+  });
+
+  $('chat-filter').addEventListener('submit', function (e) {
+    e.preventDefault();
+    state.chatQuery = query(e.target).toString();
+    loadChats(1);
+  });
+
+  $('user-filter').addEventListener('submit', function (e) {
+    e.preventDefault();
+    state.userQuery = query(e.target).toString();
+    loadUsers(1);
+  });
+
+  $('usage-filter').addEventListener('submit', function (e) {
+    e.preventDefault();
+    loadUsage();
+  });
+
+  loadChats(1);
+})();
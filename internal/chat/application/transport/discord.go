@@ -0,0 +1,249 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/discord"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
+)
+
+type (
+	// discordController 处理Discord Interactions回调，/ask以延迟响应方式回复
+	discordController struct {
+		app          *application.Application
+		router       *CommandRouter
+		client       *discord.Client
+		interactions *application.DiscordInteractions
+	}
+
+	discordInteraction struct {
+		ID    string `json:"id"`
+		Type  int    `json:"type"`
+		Token string `json:"token"`
+		Data  struct {
+			Name    string `json:"name"`
+			Options []struct {
+				Name  string          `json:"name"`
+				Value json.RawMessage `json:"value"`
+			} `json:"options"`
+		} `json:"data"`
+		Member *struct {
+			User discordUser `json:"user"`
+		} `json:"member"`
+		User   *discordUser `json:"user"`
+		Locale string       `json:"locale"`
+	}
+
+	discordUser struct {
+		ID string `json:"id"`
+	}
+
+	discordResponse struct {
+		Type int                  `json:"type"`
+		Data *discordResponseData `json:"data,omitempty"`
+	}
+
+	discordResponseData struct {
+		Content string `json:"content"`
+		Flags   int    `json:"flags,omitempty"`
+	}
+)
+
+const (
+	discordInteractionPing    = 1
+	discordInteractionCommand = 2
+
+	discordResponsePong     = 1
+	discordResponseMessage  = 4
+	discordResponseDeferred = 5
+
+	// 仅发起命令的用户可见
+	discordFlagEphemeral = 64
+)
+
+// discordLocales 消息文件的语言对应的Discord语言代码
+var discordLocales = map[string][]string{
+	"en": {"en-US", "en-GB"},
+	"zh": {"zh-CN", "zh-TW"},
+}
+
+// NewDiscordCommands 启动时注册的斜杠命令，说明以缺省语言展示，并附带其他语言的翻译
+func NewDiscordCommands(catalog *i18n.Catalog) []discord.Command {
+	describe := func(key string) (string, map[string]string) {
+		localizations := make(map[string]string)
+		for _, locale := range catalog.Locales() {
+			for _, code := range discordLocales[locale] {
+				localizations[code] = catalog.T(locale, key)
+			}
+		}
+		return catalog.T(catalog.Fallback(), key), localizations
+	}
+
+	ask, askL10n := describe("discord.ask")
+	prompt, promptL10n := describe("discord.ask.prompt")
+	reset, resetL10n := describe("command.end")
+	help, helpL10n := describe("command.help")
+	return []discord.Command{
+		{
+			Name:                     "ask",
+			Description:              ask,
+			DescriptionLocalizations: askL10n,
+			Options: []discord.CommandOption{
+				{Type: discord.OptionTypeString, Name: "prompt", Description: prompt, DescriptionLocalizations: promptL10n, Required: true},
+			},
+		},
+		{Name: "reset", Description: reset, DescriptionLocalizations: resetL10n},
+		{Name: "help", Description: help, DescriptionLocalizations: helpL10n},
+	}
+}
+
+var _ httpsrv.Controller = (*discordController)(nil)
+
+func NewDiscordController(app *application.Application, router *CommandRouter, client *discord.Client, interactions *application.DiscordInteractions) httpsrv.Controller {
+	return &discordController{app: app, router: router, client: client, interactions: interactions}
+}
+
+func (ctrl *discordController) Slug() string {
+	return "/api/v1/discord"
+}
+
+func (ctrl *discordController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/interactions",
+			Func:    ctrl.Interactions,
+		},
+	}
+}
+
+func (ctrl *discordController) Middlewares() []httpsrv.Middleware {
+	return nil
+}
+
+func (ctrl *discordController) Interactions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Discord会定期发送签名错误的请求，校验失败必须返回401
+	if err = ctrl.client.Verify(r.Header, body, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	interaction := new(discordInteraction)
+	if err = json.Unmarshal(body, interaction); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case discordInteractionPing:
+		writeJSON(w, http.StatusOK, &discordResponse{Type: discordResponsePong})
+		return
+	case discordInteractionCommand:
+	default:
+		http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+		return
+	}
+
+	var uid string
+	if interaction.Member != nil {
+		uid = interaction.Member.User.ID
+	} else if interaction.User != nil {
+		uid = interaction.User.ID
+	}
+	if uid == "" {
+		http.Error(w, "missing user", http.StatusBadRequest)
+		return
+	}
+	from := domain.From{Channel: domain.ChannelDiscord, ChannelUserID: domain.ChannelUserID(uid)}
+	log := logger.With(logger.FromContext(r.Context()), "discord_user_id", uid)
+	env := &Envelope{From: from, MessageID: domain.ChannelMessageID(interaction.ID), Log: log, Language: interaction.Locale}
+
+	switch interaction.Data.Name {
+	case "ask":
+		prompt := strings.TrimSpace(interaction.option("prompt"))
+		if prompt == "" {
+			writeJSON(w, http.StatusOK, &discordResponse{
+				Type: discordResponseMessage,
+				Data: &discordResponseData{Content: ctrl.router.Localize(r.Context(), env, "discord.prompt_required"), Flags: discordFlagEphemeral},
+			})
+			return
+		}
+		ctrl.ask(w, r, env, interaction, prompt)
+
+	default:
+		// 其他斜杠命令按文本命令处理，reset为/end的别名
+		name := interaction.Data.Name
+		if name == "reset" {
+			name = "end"
+		}
+		env.Text = "/" + name + " " + interaction.args()
+		reply, ok := ctrl.router.Reply(r.Context(), env)
+		if !ok {
+			writeJSON(w, http.StatusOK, &discordResponse{
+				Type: discordResponseMessage,
+				Data: &discordResponseData{Content: ctrl.router.Localize(r.Context(), env, "discord.unknown_command"), Flags: discordFlagEphemeral},
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, &discordResponse{Type: discordResponseMessage, Data: &discordResponseData{Content: reply}})
+	}
+}
+
+// ask Discord要求3秒内响应，先返回延迟响应再提交prompt，回复由DiscordEventHandler完成
+func (ctrl *discordController) ask(w http.ResponseWriter, r *http.Request, env *Envelope, interaction *discordInteraction, prompt string) {
+	log, from, msgID := env.Log, env.From, env.MessageID
+	ctrl.router.Hint(env)
+	ctrl.interactions.Put(msgID, interaction.Token)
+	writeJSON(w, http.StatusOK, &discordResponse{Type: discordResponseDeferred})
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	if err := promptOrStart(r.Context(), ctrl.app, log, from, "", prompt, msgID); err != nil {
+		token, ok := ctrl.interactions.Take(msgID)
+		if !ok {
+			return
+		}
+		if err = ctrl.client.EditOriginal(r.Context(), token, ctrl.router.ErrorText(r.Context(), env, err)); err != nil {
+			logger.NewHelper(log).Error("failed to send message to discord", "error", err.Error())
+		}
+	}
+}
+
+// args 按顺序拼接字符串类型的命令参数
+func (i *discordInteraction) args() string {
+	values := make([]string, 0, len(i.Data.Options))
+	for _, opt := range i.Data.Options {
+		var value string
+		if err := json.Unmarshal(opt.Value, &value); err == nil {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// option 返回字符串类型的命令参数
+func (i *discordInteraction) option(name string) string {
+	for _, opt := range i.Data.Options {
+		if opt.Name != name {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(opt.Value, &value); err == nil {
+			return value
+		}
+	}
+	return ""
+}
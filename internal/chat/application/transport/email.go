@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/email"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+// EmailWorker 定期拉取未读邮件，同一发件人在同一线程中的邮件对应同一个会话
+type EmailWorker struct {
+	app     *application.Application
+	router  *CommandRouter
+	client  *email.Client
+	replies *application.EmailReplies
+	senders []string
+	log     logger.Logger
+}
+
+// NewEmailWorker senders为允许的发件人，可以是完整地址或@domain，为空时拒绝所有发件人
+func NewEmailWorker(log logger.Logger, app *application.Application, router *CommandRouter, client *email.Client, replies *application.EmailReplies, senders []string) *EmailWorker {
+	allowed := make([]string, len(senders))
+	for i, s := range senders {
+		allowed[i] = strings.ToLower(s)
+	}
+	return &EmailWorker{app: app, router: router, client: client, replies: replies, senders: allowed, log: log}
+}
+
+// Run 按poll_interval拉取邮件直到ctx结束
+func (ew *EmailWorker) Run(ctx context.Context) {
+	helper := logger.NewHelper(ew.log)
+	ticker := time.NewTicker(ew.client.PollInterval)
+	defer ticker.Stop()
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, ew.client.PollInterval+time.Minute)
+		if err := ew.client.Poll(pollCtx, ew.handle); err != nil && ctx.Err() == nil {
+			helper.Error("failed to poll email", "error", err.Error())
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			helper.Info("email worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle 返回error时邮件保持未读，下次拉取时重试
+func (ew *EmailWorker) handle(msg *email.Message) error {
+	if msg.Automated || strings.EqualFold(msg.From, ew.client.Address()) || !ew.allowed(msg.From) {
+		return nil
+	}
+	text := domain.StripQuotedReply(msg.Text)
+	if text == "" {
+		return nil
+	}
+
+	from := domain.NewEmailFrom(msg.From)
+	thread := domain.NewEmailThread(msg.MessageID, msg.InReplyTo, msg.References)
+	msgID := domain.NewEmailMessageID(msg.MessageID)
+	log := logger.With(ew.log, "email_from", msg.From, "email_thread", thread, "email_message_id", msg.MessageID)
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+
+	env := &Envelope{From: from, Text: text, MessageID: msgID, Thread: thread, Log: log}
+	reply, ok := ew.router.Reply(ctx, env)
+	if !ok {
+		ew.replies.Put(msgID, msg.ReplyTarget())
+		if err := promptOrStart(ctx, ew.app, log, from, thread, text, msgID); err != nil {
+			if _, ok := ew.replies.Take(msgID); ok {
+				reply = ew.router.ErrorText(ctx, env, err)
+			}
+		}
+	}
+	if reply == "" {
+		return nil
+	}
+	return ew.client.Reply(ctx, msg.ReplyTarget(), reply)
+}
+
+// allowed 未配置allowed_senders时拒绝所有发件人
+func (ew *EmailWorker) allowed(address string) bool {
+	address = strings.ToLower(address)
+	for _, s := range ew.senders {
+		if address == s || (strings.HasPrefix(s, "@") && strings.HasSuffix(address, s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/feishu"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	// feishuController 处理飞书事件订阅回调，单聊消息及群聊中@机器人的消息触发prompt
+	feishuController struct {
+		app    *application.Application
+		router *CommandRouter
+		client *feishu.Client
+		seen   *eventDedup
+	}
+
+	feishuCallback struct {
+		// 1.0协议的url_verification
+		Challenge string `json:"challenge"`
+		Token     string `json:"token"`
+		Type      string `json:"type"`
+
+		Schema string `json:"schema"`
+		Header struct {
+			EventID   string `json:"event_id"`
+			EventType string `json:"event_type"`
+			Token     string `json:"token"`
+		} `json:"header"`
+		Event struct {
+			Sender struct {
+				SenderID struct {
+					OpenID string `json:"open_id"`
+				} `json:"sender_id"`
+				SenderType string `json:"sender_type"`
+			} `json:"sender"`
+			Message feishuMessage `json:"message"`
+		} `json:"event"`
+	}
+
+	feishuMessage struct {
+		MessageID   string `json:"message_id"`
+		ChatID      string `json:"chat_id"`
+		ChatType    string `json:"chat_type"`
+		MessageType string `json:"message_type"`
+		Content     string `json:"content"`
+		Mentions    []struct {
+			Key string `json:"key"`
+			ID  struct {
+				OpenID string `json:"open_id"`
+			} `json:"id"`
+		} `json:"mentions"`
+	}
+)
+
+const feishuEventMessageReceive = "im.message.receive_v1"
+
+var _ httpsrv.Controller = (*feishuController)(nil)
+
+func NewFeishuController(app *application.Application, router *CommandRouter, client *feishu.Client) httpsrv.Controller {
+	return &feishuController{app: app, router: router, client: client, seen: newEventDedup(time.Hour)}
+}
+
+func (ctrl *feishuController) Slug() string {
+	return "/api/v1/feishu"
+}
+
+func (ctrl *feishuController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/events",
+			Func:    ctrl.Events,
+		},
+	}
+}
+
+func (ctrl *feishuController) Middlewares() []httpsrv.Middleware {
+	return nil
+}
+
+// Events 飞书要求3秒内响应，超时或失败时会重推，重复的event_id会被忽略
+func (ctrl *feishuController) Events(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 配置encrypt_key后事件必须携带签名，仅url_verification请求不带签名，缺少签名时解密后再判断类型
+	sigErr := ctrl.client.VerifySignature(r.Header, body, time.Now())
+	if sigErr != nil && r.Header.Get(feishu.SignatureHeader) != "" {
+		http.Error(w, sigErr.Error(), http.StatusUnauthorized)
+		return
+	}
+	if ctrl.client.Encrypted() {
+		var encrypted struct {
+			Encrypt string `json:"encrypt"`
+		}
+		if err = json.Unmarshal(body, &encrypted); err != nil {
+			http.Error(w, feishu.ErrInvalidEvent.Error(), http.StatusBadRequest)
+			return
+		}
+		if body, err = ctrl.client.Decrypt(encrypted.Encrypt); err != nil {
+			http.Error(w, feishu.ErrInvalidEvent.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	callback := new(feishuCallback)
+	if err = json.Unmarshal(body, callback); err != nil {
+		http.Error(w, feishu.ErrInvalidEvent.Error(), http.StatusBadRequest)
+		return
+	}
+	if sigErr != nil && callback.Type != "url_verification" {
+		http.Error(w, sigErr.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token := callback.Header.Token
+	if callback.Type == "url_verification" {
+		token = callback.Token
+	}
+	if !ctrl.client.VerifyToken(token) {
+		http.Error(w, "invalid verification token", http.StatusUnauthorized)
+		return
+	}
+	if callback.Type == "url_verification" {
+		writeJSON(w, http.StatusOK, map[string]string{"challenge": callback.Challenge})
+		return
+	}
+
+	if callback.Header.EventType == feishuEventMessageReceive && callback.Event.Sender.SenderType == "user" &&
+		!ctrl.seen.Seen(callback.Header.EventID) {
+		from := domain.From{Channel: domain.ChannelFeishu, ChannelUserID: domain.ChannelUserID(callback.Event.Sender.SenderID.OpenID)}
+		ctrl.handle(r, from, &callback.Event.Message)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+func (ctrl *feishuController) handle(r *http.Request, from domain.From, msg *feishuMessage) {
+	log := logger.With(logger.FromContext(r.Context()), "feishu_user_id", from.ChannelUserID, "feishu_chat_id", msg.ChatID)
+
+	// 群聊中仅响应@机器人的消息
+	mentioned := msg.ChatType == "p2p"
+	text := msg.textContent()
+	for _, m := range msg.Mentions {
+		if m.ID.OpenID == ctrl.client.BotOpenID {
+			mentioned = true
+		}
+		text = strings.ReplaceAll(text, m.Key, "")
+	}
+	if !mentioned {
+		return
+	}
+
+	var reply string
+	text = strings.TrimSpace(text)
+	msgID := domain.ChannelMessageID(msg.MessageID)
+	switch {
+	case msg.MessageType != "text":
+		reply = ctrl.router.Localize(r.Context(), &Envelope{From: from, MessageID: msgID, Log: log}, "feishu.unsupported")
+
+	case text == "":
+		return
+
+	default:
+		var ok bool
+		env := &Envelope{From: from, Text: text, MessageID: msgID, Log: log}
+		if reply, ok = ctrl.router.Reply(r.Context(), env); ok {
+			break
+		}
+		if err := promptOrStart(r.Context(), ctrl.app, log, from, "", text, msgID); err != nil {
+			reply = ctrl.router.ErrorText(r.Context(), env, err)
+		}
+	}
+	if reply == "" {
+		return
+	}
+	if err := ctrl.client.ReplyText(r.Context(), msg.MessageID, reply); err != nil {
+		logger.NewHelper(log).Error("failed to send message to feishu", "error", err.Error())
+	}
+}
+
+// textContent 文本消息的content为{"text":"..."}形式的JSON字符串
+func (msg *feishuMessage) textContent() string {
+	var content struct {
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal([]byte(msg.Content), &content)
+	return content.Text
+}
@@ -0,0 +1,325 @@
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// gatewayController 兼容OpenAI chat completions协议的接口，供内部工具复用机器人的额度、审核及用量统计
+	gatewayController struct {
+		app     *application.Application
+		clients map[string]string
+	}
+
+	gatewayClientKey struct{}
+
+	completionRequest struct {
+		Model    string              `json:"model"`
+		Messages []completionMessage `json:"messages"`
+		Stream   bool                `json:"stream"`
+	}
+
+	completionMessage struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+
+	contentPart struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+
+	completionChoice struct {
+		Index        int                `json:"index"`
+		Message      *completionContent `json:"message,omitempty"`
+		Delta        *completionContent `json:"delta,omitempty"`
+		FinishReason *string            `json:"finish_reason"`
+	}
+
+	completionContent struct {
+		Role    string `json:"role,omitempty"`
+		Content string `json:"content,omitempty"`
+	}
+
+	completionUsage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	}
+
+	completionResponse struct {
+		ID      string             `json:"id"`
+		Object  string             `json:"object"`
+		Created int64              `json:"created"`
+		Model   string             `json:"model"`
+		Choices []completionChoice `json:"choices"`
+		Usage   *completionUsage   `json:"usage,omitempty"`
+	}
+
+	gatewayError struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code,omitempty"`
+	}
+)
+
+var _ httpsrv.Controller = (*gatewayController)(nil)
+
+var errInvalidMessages = errors.New("messages must end with a user message")
+
+// gatewayKeepalive 等待回复期间发送SSE注释的间隔，避免代理因连接空闲而断开
+const gatewayKeepalive = 15 * time.Second
+
+// NewGatewayController clients为API key到客户端名称的映射，客户端名称即API通道的用户ID
+func NewGatewayController(app *application.Application, clients map[string]string) httpsrv.Controller {
+	return &gatewayController{app: app, clients: clients}
+}
+
+func (ctrl *gatewayController) Slug() string {
+	return "/v1"
+}
+
+func (ctrl *gatewayController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/chat/completions",
+			Func:    ctrl.Complete,
+		},
+	}
+}
+
+func (ctrl *gatewayController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: ctrl.authenticate, Scope: httpsrv.ScopeController},
+	}
+}
+
+func (ctrl *gatewayController) authenticate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		client, ok := clientByKey(ctrl.clients, r)
+		if !ok {
+			writeGatewayError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided.")
+			return
+		}
+		from := domain.From{Channel: domain.ChannelAPI, ChannelUserID: domain.ChannelUserID(client)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gatewayClientKey{}, from)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (ctrl *gatewayController) Complete(w http.ResponseWriter, r *http.Request) {
+	from := r.Context().Value(gatewayClientKey{}).(domain.From)
+	log := logger.With(logger.FromContext(r.Context()), "api_client", from.ChannelUserID)
+
+	req := new(completionRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(req); err != nil {
+		writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", "We could not parse the JSON body of your request.")
+		return
+	}
+	history, prompt, err := parseCompletionMessages(req.Messages)
+	if err != nil {
+		writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	id := "chatcmpl-" + ulid.Make().String()
+	created := time.Now().Unix()
+	if req.Stream {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeGatewayError(w, http.StatusBadRequest, "invalid_request_error", "", "streaming is not supported")
+			return
+		}
+		ctrl.stream(w, flusher, r, log, from, history, prompt, id, created, req.Model)
+		return
+	}
+
+	completion, err := ctrl.app.Complete(r.Context(), log, from, history, prompt, domain.ChannelMessageID(id))
+	if err != nil {
+		status, typ, code := gatewayErrorStatus(err)
+		writeGatewayError(w, status, typ, code, err.Error())
+		return
+	}
+	model := completion.Model
+	if model == "" {
+		model = req.Model
+	}
+	stop := "stop"
+	writeJSON(w, http.StatusOK, &completionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   model,
+		Choices: []completionChoice{{Message: &completionContent{Role: "assistant", Content: completion.Text}, FinishReason: &stop}},
+		Usage: &completionUsage{
+			PromptTokens:     completion.PromptTokens,
+			CompletionTokens: completion.CompletionTokens,
+			TotalTokens:      completion.PromptTokens + completion.CompletionTokens,
+		},
+	})
+}
+
+// stream 立即返回响应头及role chunk，等待期间定时发送SSE注释保持连接。
+// 上游仍为非流式调用：流式响应不含token用量，且回复须整体通过内容审核后才能返回，完整的回复以单个chunk返回
+func (ctrl *gatewayController) stream(w http.ResponseWriter, flusher http.Flusher, r *http.Request, log logger.Logger,
+	from domain.From, history []*domain.Conversation, prompt, id string, created int64, model string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	writeChunk := func(model string, choice completionChoice) {
+		data, _ := json.Marshal(&completionResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []completionChoice{choice},
+		})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	writeChunk(model, completionChoice{Delta: &completionContent{Role: "assistant"}})
+	flusher.Flush()
+
+	type result struct {
+		completion *application.Completion
+		err        error
+	}
+	done := make(chan result, 1)
+	go func() {
+		completion, err := ctrl.app.Complete(r.Context(), log, from, history, prompt, domain.ChannelMessageID(id))
+		done <- result{completion: completion, err: err}
+	}()
+
+	keepalive := time.NewTicker(gatewayKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-keepalive.C:
+			_, _ = fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case res := <-done:
+			if res.err != nil {
+				// 响应头已发送，错误以OpenAI流式接口的error事件返回
+				_, typ, code := gatewayErrorStatus(res.err)
+				data, _ := json.Marshal(map[string]*gatewayError{"error": {Message: res.err.Error(), Type: typ, Code: code}})
+				_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+				flusher.Flush()
+				return
+			}
+			if res.completion.Model != "" {
+				model = res.completion.Model
+			}
+			stop := "stop"
+			writeChunk(model, completionChoice{Delta: &completionContent{Content: res.completion.Text}})
+			writeChunk(model, completionChoice{Delta: &completionContent{}, FinishReason: &stop})
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// parseCompletionMessages 按user/assistant配对还原历史对话，最后一条须为非空的user消息。
+// 会话不支持独立的系统提示词，system消息并入其后的user消息，保证其内容能到达模型
+func parseCompletionMessages(messages []completionMessage) ([]*domain.Conversation, string, error) {
+	history := make([]*domain.Conversation, 0, len(messages)/2)
+	var pending []string
+	var last string
+	for _, msg := range messages {
+		text, err := messageText(msg.Content)
+		if err != nil {
+			return nil, "", err
+		}
+		switch msg.Role {
+		case "user":
+			pending = append(pending, text)
+			last = text
+		case "assistant":
+			history = append(history, &domain.Conversation{Prompt: strings.Join(pending, "\n\n"), Completion: text})
+			pending = nil
+		case "system", "developer":
+			if text = strings.TrimSpace(text); text != "" {
+				pending = append(pending, text)
+			}
+		default:
+			return nil, "", fmt.Errorf("unsupported role: %s", msg.Role)
+		}
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" || strings.TrimSpace(last) == "" {
+		return nil, "", errInvalidMessages
+	}
+	return history, strings.TrimSpace(strings.Join(pending, "\n\n")), nil
+}
+
+// messageText content可以是字符串，也可以是仅包含text类型的数组
+func messageText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("invalid message content")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("unsupported content type: %s", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+func gatewayErrorStatus(err error) (int, string, string) {
+	var limited *domain.RateLimitedError
+	switch {
+	case errors.Is(err, domain.ErrAccessDenied):
+		return http.StatusForbidden, "permission_error", ""
+	case errors.As(err, &limited):
+		return http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded"
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		return http.StatusTooManyRequests, "insufficient_quota", "insufficient_quota"
+	case errors.Is(err, domain.ErrContentBlocked):
+		return http.StatusBadRequest, "invalid_request_error", "content_filter"
+	default:
+		return http.StatusBadGateway, "api_error", ""
+	}
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, typ, code, message string) {
+	writeJSON(w, status, map[string]*gatewayError{"error": {Message: message, Type: typ, Code: code}})
+}
+
+// clientByKey 根据Authorization: Bearer <key>查找客户端名称，clients为API key到客户端名称的映射
+func clientByKey(clients map[string]string, r *http.Request) (string, bool) {
+	key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", false
+	}
+	var client string
+	for k, name := range clients {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			client = name
+		}
+	}
+	return client, client != ""
+}
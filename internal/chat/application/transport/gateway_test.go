@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageText(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+		err  bool
+	}{
+		{raw: ``, want: ""},
+		{raw: `null`, want: ""},
+		{raw: `"hello"`, want: "hello"},
+		{raw: `[{"type":"text","text":"a"},{"type":"text","text":"b"}]`, want: "a\nb"},
+		{raw: `[]`, want: ""},
+		{raw: `[{"type":"image_url","image_url":{"url":"https://example.org/a.png"}}]`, err: true},
+		{raw: `123`, err: true},
+		{raw: `{"type":"text"}`, err: true},
+	}
+	for _, c := range cases {
+		text, err := messageText(json.RawMessage(c.raw))
+		if c.err {
+			assert.Error(t, err, c.raw)
+			continue
+		}
+		assert.NoError(t, err, c.raw)
+		assert.Equal(t, c.want, text, c.raw)
+	}
+}
+
+func TestParseCompletionMessages(t *testing.T) {
+	msg := func(role, content string) completionMessage {
+		return completionMessage{Role: role, Content: json.RawMessage(content)}
+	}
+	cases := []struct {
+		name     string
+		messages []completionMessage
+		history  []*domain.Conversation
+		prompt   string
+		err      bool
+	}{
+		{
+			name:     "single user message",
+			messages: []completionMessage{msg("user", `"hi"`)},
+			history:  []*domain.Conversation{},
+			prompt:   "hi",
+		},
+		{
+			name: "history pairs",
+			messages: []completionMessage{
+				msg("user", `"q1"`), msg("assistant", `"a1"`),
+				msg("user", `"q2"`), msg("user", `[{"type":"text","text":"q3"}]`),
+			},
+			history: []*domain.Conversation{{Prompt: "q1", Completion: "a1"}},
+			prompt:  "q2\n\nq3",
+		},
+		{
+			name:     "system message is merged into the next user message",
+			messages: []completionMessage{msg("system", `"be brief"`), msg("user", `"hi"`)},
+			history:  []*domain.Conversation{},
+			prompt:   "be brief\n\nhi",
+		},
+		{
+			name: "developer message before history",
+			messages: []completionMessage{
+				msg("developer", `"be brief"`), msg("user", `"q1"`), msg("assistant", `"a1"`), msg("user", `"q2"`),
+			},
+			history: []*domain.Conversation{{Prompt: "be brief\n\nq1", Completion: "a1"}},
+			prompt:  "q2",
+		},
+		{name: "empty", messages: nil, err: true},
+		{name: "trailing assistant", messages: []completionMessage{msg("user", `"q"`), msg("assistant", `"a"`)}, err: true},
+		{name: "trailing system", messages: []completionMessage{msg("user", `"q"`), msg("system", `"s"`)}, err: true},
+		{name: "empty prompt", messages: []completionMessage{msg("system", `"s"`), msg("user", `"  "`)}, err: true},
+		{name: "unknown role", messages: []completionMessage{msg("tool", `"r"`), msg("user", `"q"`)}, err: true},
+		{name: "invalid content", messages: []completionMessage{msg("user", `[{"type":"image_url"}]`)}, err: true},
+	}
+	for _, c := range cases {
+		history, prompt, err := parseCompletionMessages(c.messages)
+		if c.err {
+			assert.Error(t, err, c.name)
+			continue
+		}
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.history, history, c.name)
+		assert.Equal(t, c.prompt, prompt, c.name)
+	}
+}
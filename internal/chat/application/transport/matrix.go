@@ -0,0 +1,260 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/matrix"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type (
+	// MatrixWorker 以/sync长轮询接收消息，私聊消息及房间中提及机器人的消息触发prompt。
+	// 同步位置在每批事件处理后保存，重启时从该位置继续
+	MatrixWorker struct {
+		app     *application.Application
+		router  *CommandRouter
+		client  *matrix.Client
+		replies *application.MatrixReplies
+		tokens  domain.SyncTokenRepository
+		log     logger.Logger
+
+		// 以下状态仅由Run所在的goroutine访问
+		direct    map[string]bool
+		members   map[string]int
+		encrypted map[string]bool
+	}
+
+	matrixMember struct {
+		Membership string `json:"membership"`
+		IsDirect   bool   `json:"is_direct"`
+	}
+)
+
+const matrixMaxBackoff = time.Minute
+
+func NewMatrixWorker(log logger.Logger, app *application.Application, router *CommandRouter, client *matrix.Client, replies *application.MatrixReplies, tokens domain.SyncTokenRepository) *MatrixWorker {
+	return &MatrixWorker{
+		app:       app,
+		router:    router,
+		client:    client,
+		replies:   replies,
+		tokens:    tokens,
+		log:       logger.With(log, "matrix_bot", client.UserID),
+		direct:    make(map[string]bool),
+		members:   make(map[string]int),
+		encrypted: make(map[string]bool),
+	}
+}
+
+// Run 持续同步直到ctx结束，同步失败时以指数退避重试
+func (mw *MatrixWorker) Run(ctx context.Context) {
+	helper := logger.NewHelper(mw.log)
+	key := "matrix:" + mw.client.UserID
+	since, err := mw.tokens.Get(ctx, key)
+	if err != nil {
+		helper.Error("failed to load matrix sync token", "error", err.Error())
+	}
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		// 首次同步不等待新事件，且不处理其中的历史消息
+		timeout := matrix.SyncTimeout
+		if since == "" {
+			timeout = 0
+		}
+		resp, err := mw.client.Sync(ctx, since, timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if matrix.IsUnknownToken(err) {
+				helper.Error("matrix access token is invalid, stop syncing", "error", err.Error())
+				return
+			}
+			helper.Warn("failed to sync from matrix", "error", err.Error(), "retry_after", backoff.String())
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > matrixMaxBackoff {
+				backoff = matrixMaxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+
+		mw.process(resp, since == "")
+		since = resp.NextBatch
+		// 停止时ctx已取消，保存同步位置使用独立的context
+		saveCtx, cancel := pkgCtx.GenDefaultContext()
+		if err = mw.tokens.Save(saveCtx, key, since); err != nil {
+			helper.Error("failed to save matrix sync token", "error", err.Error())
+		}
+		cancel()
+	}
+	helper.Info("matrix sync worker stopped")
+}
+
+func (mw *MatrixWorker) process(resp *matrix.SyncResponse, initial bool) {
+	for _, ev := range resp.AccountData.Events {
+		if ev.Type == matrix.EventDirect {
+			mw.updateDirect(ev.Content)
+		}
+	}
+
+	for roomID, room := range resp.Rooms.Invite {
+		mw.invited(roomID, room)
+	}
+
+	for roomID, room := range resp.Rooms.Join {
+		if room.Summary.JoinedMemberCount != nil {
+			mw.members[roomID] = *room.Summary.JoinedMemberCount
+		}
+		if initial {
+			continue
+		}
+		for _, ev := range room.Timeline.Events {
+			if ev.Sender == mw.client.UserID {
+				continue
+			}
+			switch ev.Type {
+			case matrix.EventMessage:
+				mw.message(roomID, ev)
+			case matrix.EventEncrypted:
+				if !mw.encrypted[roomID] {
+					mw.encrypted[roomID] = true
+					logger.NewHelper(mw.log).Warn("end-to-end encrypted room is not supported", "room_id", roomID)
+				}
+			}
+		}
+	}
+}
+
+// updateDirect m.direct的内容为用户ID到私聊房间ID列表的映射
+func (mw *MatrixWorker) updateDirect(content json.RawMessage) {
+	var rooms map[string][]string
+	if err := json.Unmarshal(content, &rooms); err != nil {
+		return
+	}
+	for _, ids := range rooms {
+		for _, id := range ids {
+			mw.direct[id] = true
+		}
+	}
+}
+
+func (mw *MatrixWorker) invited(roomID string, room matrix.InvitedRoom) {
+	if !mw.client.AutoJoin() {
+		return
+	}
+	for _, ev := range room.InviteState.Events {
+		if ev.Type != matrix.EventMember || ev.StateKey == nil || *ev.StateKey != mw.client.UserID {
+			continue
+		}
+		var member matrixMember
+		if err := json.Unmarshal(ev.Content, &member); err == nil && member.IsDirect {
+			mw.direct[roomID] = true
+		}
+	}
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	if err := mw.client.JoinRoom(ctx, roomID); err != nil {
+		logger.NewHelper(mw.log).Error("failed to join matrix room", "room_id", roomID, "error", err.Error())
+	}
+}
+
+func (mw *MatrixWorker) message(roomID string, ev matrix.Event) {
+	var content matrix.MessageContent
+	if err := json.Unmarshal(ev.Content, &content); err != nil || content.MsgType != matrix.MsgTypeText {
+		return
+	}
+	var threadRoot string
+	if rel := content.RelatesTo; rel != nil {
+		if rel.RelType == matrix.RelReplace {
+			return
+		}
+		if rel.RelType == matrix.RelThread {
+			threadRoot = rel.EventID
+		}
+	}
+
+	text := stripReplyFallback(content.Body)
+	if !mw.direct[roomID] && mw.members[roomID] != 2 {
+		if !mw.mentioned(&content) {
+			return
+		}
+		// 房间中的回复放在thread中，避免打断其他人的对话
+		if threadRoot == "" {
+			threadRoot = ev.EventID
+		}
+	}
+	text = mw.stripMention(text)
+	if text == "" {
+		return
+	}
+
+	from := domain.From{Channel: domain.ChannelMatrix, ChannelUserID: domain.ChannelUserID(ev.Sender)}
+	log := logger.With(mw.log, "matrix_user_id", ev.Sender, "matrix_room_id", roomID, "matrix_event_id", ev.EventID)
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+
+	msgID := domain.ChannelMessageID(ev.EventID)
+	env := &Envelope{From: from, Text: text, MessageID: msgID, Log: log}
+	reply, ok := mw.router.Reply(ctx, env)
+	if !ok {
+		mw.replies.Put(msgID, roomID, threadRoot)
+		if err := promptOrStart(ctx, mw.app, log, from, "", text, msgID); err != nil {
+			if _, _, ok := mw.replies.Take(msgID); ok {
+				reply = mw.router.ErrorText(ctx, env, err)
+			}
+		}
+	}
+	if reply == "" {
+		return
+	}
+	if _, err := mw.client.SendMessage(ctx, roomID, matrix.TextReply(reply, ev.EventID, threadRoot)); err != nil {
+		logger.NewHelper(log).Error("failed to send message to matrix", "error", err.Error())
+	}
+}
+
+// mentioned 优先使用m.mentions，旧版客户端仅在正文中包含用户ID或显示名称
+func (mw *MatrixWorker) mentioned(content *matrix.MessageContent) bool {
+	if content.Mentions != nil {
+		for _, uid := range content.Mentions.UserIDs {
+			if uid == mw.client.UserID {
+				return true
+			}
+		}
+	}
+	return strings.Contains(content.Body, mw.client.UserID) ||
+		(mw.client.DisplayName != "" && strings.Contains(content.Body, mw.client.DisplayName))
+}
+
+func (mw *MatrixWorker) stripMention(text string) string {
+	text = strings.ReplaceAll(text, mw.client.UserID, "")
+	if mw.client.DisplayName != "" {
+		text = strings.ReplaceAll(text, mw.client.DisplayName, "")
+	}
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), ":,，"))
+}
+
+// stripReplyFallback 去掉回复消息正文开头以"> "引用的原消息
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ">") {
+			return strings.Join(lines[i:], "\n")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type reportController struct {
+	app  *application.ReportApplication
+	keys []string
+}
+
+var _ httpsrv.Controller = (*reportController)(nil)
+
+// NewReportController 报表包含全部用户的用量，与管理接口使用相同的API key
+func NewReportController(app *application.ReportApplication, keys []string) httpsrv.Controller {
+	return &reportController{app: app, keys: keys}
+}
+
+func (ctrl *reportController) Slug() string {
+	return "/api/v1/reports"
+}
+
+func (ctrl *reportController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodGet,
+			Pattern: "/usage",
+			Func:    ctrl.Usage,
+		},
+	}
+}
+
+func (ctrl *reportController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: httpsrv.APIKeyAuth(ctrl.keys), Scope: httpsrv.ScopeController},
+	}
+}
+
+// Usage 按user|channel|model汇总用量及费用，format=csv或Accept: text/csv时返回CSV
+func (ctrl *reportController) Usage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	period, err := domain.ParseReportPeriod(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dimension, err := domain.ParseReportDimension(query.Get("group_by"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := ctrl.app.UsageReport(r.Context(), logger.FromContext(r.Context()), period, dimension)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if query.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s.csv", dimension))
+		w.WriteHeader(http.StatusOK)
+		_ = report.WriteCSV(w)
+		return
+	}
+
+	data, _ := json.Marshal(report)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
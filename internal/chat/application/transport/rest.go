@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// restController 通用的REST通道，回复通过回调地址异步推送
+	restController struct {
+		app      *application.Application
+		router   *CommandRouter
+		callback *application.CallbackApplication
+		clients  map[string]string
+	}
+
+	restClientKey struct{}
+
+	restMessage struct {
+		UserID      string `json:"user_id"`
+		Text        string `json:"text"`
+		CallbackURL string `json:"callback_url"`
+	}
+
+	restReply struct {
+		UserID    string `json:"user_id"`
+		MessageID string `json:"message_id,omitempty"`
+		Reply     string `json:"reply,omitempty"`
+	}
+)
+
+var _ httpsrv.Controller = (*restController)(nil)
+
+// NewRESTController clients为API key到客户端名称的映射
+func NewRESTController(app *application.Application, router *CommandRouter, callback *application.CallbackApplication, clients map[string]string) httpsrv.Controller {
+	return &restController{app: app, router: router, callback: callback, clients: clients}
+}
+
+func (ctrl *restController) Slug() string {
+	return "/api/v1/rest"
+}
+
+func (ctrl *restController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/messages",
+			Func:    ctrl.Message,
+		},
+		{
+			Method:  http.MethodGet,
+			Pattern: "/deliveries",
+			Func:    ctrl.Deliveries,
+		},
+	}
+}
+
+func (ctrl *restController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: ctrl.authenticate, Scope: httpsrv.ScopeController},
+	}
+}
+
+func (ctrl *restController) authenticate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		client, ok := clientByKey(ctrl.clients, r)
+		if !ok {
+			writeRESTError(w, http.StatusUnauthorized, errors.New("invalid api key"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), restClientKey{}, client)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Message 命令的结果同步返回；prompt没有进行中的会话时自动开始新会话，回复通过回调地址推送
+func (ctrl *restController) Message(w http.ResponseWriter, r *http.Request) {
+	client := r.Context().Value(restClientKey{}).(string)
+
+	req := new(restMessage)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(req); err != nil || strings.TrimSpace(req.Text) == "" {
+		writeRESTError(w, http.StatusBadRequest, errors.New("invalid message"))
+		return
+	}
+	from, err := domain.NewRESTFrom(client, req.UserID)
+	if err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+	log := logger.With(logger.FromContext(r.Context()), "rest_user_id", from.ChannelUserID)
+	if err = ctrl.callback.Register(r.Context(), log, &domain.CallbackEndpoint{From: from, Client: client, URL: req.CallbackURL}); err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reply := restReply{UserID: req.UserID, MessageID: ulid.Make().String()}
+	msgID := domain.ChannelMessageID(reply.MessageID)
+	text, ok, err := ctrl.router.Dispatch(r.Context(), &Envelope{From: from, Text: req.Text, MessageID: msgID, Log: log, Language: r.Header.Get("Accept-Language")})
+	if ok {
+		if err != nil {
+			writeRESTError(w, restErrorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, restReply{UserID: req.UserID, Reply: text})
+		return
+	}
+	if err = promptOrStart(r.Context(), ctrl.app, log, from, "", req.Text, msgID); err != nil {
+		writeRESTError(w, restErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, reply)
+}
+
+// Deliveries 当前客户端的回调投递记录，支持page、page_size分页
+func (ctrl *restController) Deliveries(w http.ResponseWriter, r *http.Request) {
+	client := r.Context().Value(restClientKey{}).(string)
+	page, err := ctrl.callback.Deliveries(r.Context(), logger.FromContext(r.Context()), client, parsePagination(r))
+	if err != nil {
+		writeRESTError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func restErrorStatus(err error) int {
+	var limited *domain.RateLimitedError
+	switch {
+	case errors.Is(err, domain.ErrAccessDenied), errors.Is(err, domain.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.As(err, &limited), errors.Is(err, domain.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeRESTError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/slack"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	// slackController 处理Slack Events API的回调，app_mention及私聊消息触发prompt
+	slackController struct {
+		app    *application.Application
+		router *CommandRouter
+		client *slack.Client
+		seen   *eventDedup
+	}
+
+	slackEnvelope struct {
+		Type      string     `json:"type"`
+		Challenge string     `json:"challenge"`
+		TeamID    string     `json:"team_id"`
+		EventID   string     `json:"event_id"`
+		Event     slackEvent `json:"event"`
+	}
+
+	slackEvent struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		User        string `json:"user"`
+		BotID       string `json:"bot_id"`
+		Text        string `json:"text"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+	}
+)
+
+var _ httpsrv.Controller = (*slackController)(nil)
+
+func NewSlackController(app *application.Application, router *CommandRouter, client *slack.Client) httpsrv.Controller {
+	return &slackController{app: app, router: router, client: client, seen: newEventDedup(10 * time.Minute)}
+}
+
+func (ctrl *slackController) Slug() string {
+	return "/api/v1/slack"
+}
+
+func (ctrl *slackController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/events",
+			Func:    ctrl.Events,
+		},
+	}
+}
+
+func (ctrl *slackController) Middlewares() []httpsrv.Middleware {
+	return nil
+}
+
+// Events Slack要求3秒内响应，未响应时会重试，重复的event_id会被忽略
+func (ctrl *slackController) Events(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = ctrl.client.Verify(r.Header, body, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	envelope := new(slackEnvelope)
+	if err = json.Unmarshal(body, envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		writeJSON(w, http.StatusOK, map[string]string{"challenge": envelope.Challenge})
+		return
+	case "event_callback":
+		if ctrl.seen.Seen(envelope.EventID) {
+			break
+		}
+		ctrl.handle(r, envelope.TeamID, &envelope.Event)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ctrl *slackController) handle(r *http.Request, team string, ev *slackEvent) {
+	// 忽略机器人(包括自己)发送的消息及编辑、删除等子类型
+	if ev.BotID != "" || ev.Subtype != "" || ev.User == "" || ev.User == ctrl.client.BotUserID {
+		return
+	}
+	var msgID domain.ChannelMessageID
+	switch {
+	case ev.Type == "app_mention":
+		thread := ev.ThreadTS
+		if thread == "" {
+			thread = ev.TS
+		}
+		msgID = domain.NewSlackMessageID(ev.Channel, thread)
+	case ev.Type == "message" && ev.ChannelType == "im":
+		msgID = domain.NewSlackMessageID(ev.Channel, ev.ThreadTS)
+	default:
+		return
+	}
+	text := strings.TrimSpace(strings.ReplaceAll(ev.Text, "<@"+ctrl.client.BotUserID+">", ""))
+	if text == "" {
+		return
+	}
+	from := domain.NewSlackFrom(team, ev.User)
+	thread := domain.SlackThread(msgID)
+	log := logger.With(logger.FromContext(r.Context()), "slack_user_id", from.ChannelUserID, "message_id", msgID)
+	env := &Envelope{From: from, Text: text, MessageID: msgID, Thread: thread, Log: log}
+	reply, ok := ctrl.router.Reply(r.Context(), env)
+	if !ok {
+		if err := promptOrStart(r.Context(), ctrl.app, log, from, thread, text, msgID); err != nil {
+			reply = ctrl.router.ErrorText(r.Context(), env, err)
+		}
+	}
+	if reply == "" {
+		return
+	}
+	channel, thread := domain.ParseSlackMessageID(msgID)
+	if _, err := ctrl.client.PostMessage(r.Context(), channel, thread, reply); err != nil {
+		logger.NewHelper(log).Error("failed to send message to slack", "error", err.Error())
+	}
+}
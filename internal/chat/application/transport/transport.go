@@ -1,13 +1,16 @@
 package transport
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/go-jimu/components/logger"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
@@ -21,12 +24,13 @@ type controller struct {
 	tgBot  *tgbotapi.BotAPI
 	wechat *officialaccount.OfficialAccount
 	app    *application.Application
+	router *CommandRouter
 }
 
 var _ httpsrv.Controller = (*controller)(nil)
 
-func NewController(app *application.Application, bot *tgbotapi.BotAPI, wc *officialaccount.OfficialAccount) httpsrv.Controller {
-	return &controller{tgBot: bot, app: app, wechat: wc}
+func NewController(app *application.Application, router *CommandRouter, bot *tgbotapi.BotAPI, wc *officialaccount.OfficialAccount) httpsrv.Controller {
+	return &controller{tgBot: bot, app: app, router: router, wechat: wc}
 }
 
 func (ctrl *controller) Slug() string {
@@ -40,10 +44,16 @@ func (ctrl *controller) APIs() []httpsrv.API {
 			Pattern: "/telegram/callback",
 			Func:    ctrl.TelegramWebhook,
 		},
+		// 公众号配置服务器地址时以GET请求校验签名并回显echostr，消息及菜单点击事件以POST推送
 		{
 			Method:  http.MethodGet,
-			Pattern: "/chats/{chatID}",
-			Func:    ctrl.Query,
+			Pattern: "/wechat/callback",
+			Func:    ctrl.WechatWebhook,
+		},
+		{
+			Method:  http.MethodPost,
+			Pattern: "/wechat/callback",
+			Func:    ctrl.WechatWebhook,
 		},
 	}
 }
@@ -52,24 +62,6 @@ func (ctrl *controller) Middlewares() []httpsrv.Middleware {
 	return []httpsrv.Middleware{}
 }
 
-func (ctrl *controller) Query(w http.ResponseWriter, r *http.Request) {
-	chatID := chi.URLParam(r, "chatID")
-	dto, err := ctrl.app.GetByChatID(r.Context(), logger.FromContext(r.Context()), chatID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	data, _ := json.Marshal(dto)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(data))
-}
-
 func (ctrl *controller) TelegramWebhook(w http.ResponseWriter, r *http.Request) {
 	helper := logger.FromContextAsHelper(r.Context()).WithContext(r.Context())
 	update, err := ctrl.tgBot.HandleUpdate(r)
@@ -95,35 +87,12 @@ func (ctrl *controller) TelegramWebhook(w http.ResponseWriter, r *http.Request)
 		)
 
 		var chattable tgbotapi.Chattable
-
-		switch update.Message.Text {
-		case "/start":
-			if err = ctrl.app.NewChat(r.Context(), log, from); err != nil {
-				chattable = tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("[ERR] %s", err.Error()))
-			} else {
-				chattable = tgbotapi.NewMessage(update.Message.Chat.ID, "开始新的会话")
-			}
-
-		case "/end":
-			ctrl.app.End(r.Context(), log, from)
-			chattable = tgbotapi.NewMessage(update.Message.Chat.ID, "已结束当前会话")
-
-		case "/current":
-			details, err := ctrl.app.Get(r.Context(), log, from)
-			var text string
-			if err != nil {
-				text = err.Error()
-			} else {
-				data, _ := json.Marshal(details)
-				text = string(data)
-			}
-			chattable = tgbotapi.NewMessage(update.Message.Chat.ID, text)
-
-		default:
-			msgID := fmt.Sprintf("%d@%d", update.Message.MessageID, update.Message.Chat.ID)
-			if err = ctrl.app.Prompt(r.Context(), log, from, update.Message.Text, domain.ChannelMessageID(msgID)); err != nil {
-				chattable = tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("[ERR] %s", err.Error()))
-			}
+		msgID := domain.ChannelMessageID(fmt.Sprintf("%d@%d", update.Message.MessageID, update.Message.Chat.ID))
+		env := &Envelope{From: from, Text: update.Message.Text, MessageID: msgID, Log: log, Language: update.Message.From.LanguageCode}
+		if reply, ok := ctrl.router.Reply(r.Context(), env); ok {
+			chattable = tgbotapi.NewMessage(update.Message.Chat.ID, reply)
+		} else if err = ctrl.app.Prompt(r.Context(), log, from, update.Message.Text, msgID); err != nil {
+			chattable = tgbotapi.NewMessage(update.Message.Chat.ID, ctrl.router.ErrorText(r.Context(), env, err))
 		}
 
 		go func(msg tgbotapi.Chattable, log logger.Logger) {
@@ -148,36 +117,22 @@ func (ctrl *controller) WechatWebhook(w http.ResponseWriter, r *http.Request) {
 			ChannelUserID: domain.ChannelUserID(mm.FromUserName),
 		}
 
-		var text *message.Text
-
-		switch mm.Content {
-		case "/start":
-			if err := ctrl.app.NewChat(r.Context(), log, from); err != nil {
-				text = message.NewText("[ERR] " + err.Error())
-			} else {
-				text = message.NewText("开始新的会话")
-			}
-
-		case "/end":
-			ctrl.app.End(r.Context(), log, from)
-			text = message.NewText("已结束当前对话")
-
-		case "/current":
-			details, err := ctrl.app.Get(r.Context(), log, from)
-			if err != nil {
-				text = message.NewText("[ERR] " + err.Error())
-			} else {
-				data, _ := json.Marshal(details)
-				text = message.NewText(string(data))
-			}
-
-		case "":
+		// 自定义菜单的点击事件以EventKey作为文本命令
+		content := mm.Content
+		if mm.MsgType == message.MsgTypeEvent && mm.Event == message.EventClick {
+			content = mm.EventKey
+		}
+		if content == "" {
 			return nil
+		}
 
-		default:
-			if err := ctrl.app.Prompt(r.Context(), log, from, mm.Content, domain.ChannelMessageID(mm.FromUserName)); err != nil {
-				text = message.NewText("[ERR] " + err.Error())
-			}
+		var text *message.Text
+		msgID := domain.ChannelMessageID(mm.FromUserName)
+		env := &Envelope{From: from, Text: content, MessageID: msgID, Log: log}
+		if reply, ok := ctrl.router.Reply(r.Context(), env); ok {
+			text = message.NewText(reply)
+		} else if err := ctrl.app.Prompt(r.Context(), log, from, content, msgID); err != nil {
+			text = message.NewText(ctrl.router.ErrorText(r.Context(), env, err))
 		}
 		if text == nil {
 			return nil
@@ -193,3 +148,67 @@ func (ctrl *controller) WechatWebhook(w http.ResponseWriter, r *http.Request) {
 		helper.WithContext(r.Context()).Error("failed to reply message", "error", err.Error())
 	}
 }
+
+// parseCommand 拆分命令与参数，非命令文本的command为空
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", text
+	}
+	command, args, _ := strings.Cut(text, " ")
+	return command, strings.TrimSpace(args)
+}
+
+func formatUsage(req *CommandRequest, usage *application.QuotaUsage) string {
+	limit := func(v int64) string {
+		if v <= 0 {
+			return req.T("reply.unlimited")
+		}
+		return fmt.Sprintf("%d", v)
+	}
+	return req.T("reply.usage",
+		usage.DailyUsed, limit(usage.DailyLimit), usage.MonthlyUsed, limit(usage.MonthlyLimit))
+}
+
+// promptOrStart 用户没有进行中的会话时自动开始新会话，适用于没有/start入口的通道，thread参见domain.Chat.Thread
+func promptOrStart(ctx context.Context, app *application.Application, log logger.Logger, from domain.From, thread, text string, msgID domain.ChannelMessageID) error {
+	err := app.PromptThread(ctx, log, from, thread, text, msgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err = app.NewThreadChat(ctx, log, from, thread); err == nil {
+			err = app.PromptThread(ctx, log, from, thread, text, msgID)
+		}
+	}
+	return err
+}
+
+// eventDedup 记录近期处理过的事件ID，用于忽略开放平台的重试推送
+type eventDedup struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time
+}
+
+func newEventDedup(ttl time.Duration) *eventDedup {
+	return &eventDedup{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// Seen 返回事件是否已经处理过，并记录本次事件，空ID总是返回false
+func (d *eventDedup) Seen(id string) bool {
+	if id == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range d.seen {
+		if now.Sub(t) > d.ttl {
+			delete(d.seen, k)
+		}
+	}
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+	d.seen[id] = now
+	return false
+}
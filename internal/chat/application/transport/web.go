@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	// webController 浏览器聊天通道，用户身份保存在签名的cookie中
+	webController struct {
+		app     *application.Application
+		router  *CommandRouter
+		hub     *application.WebHub
+		session WebSession
+		static  http.Handler
+	}
+
+	// WebSession TrustedHeader非空时使用反向代理(如SSO网关)注入的该请求头作为用户身份，缺少时拒绝请求；
+	// 否则签发匿名会话，同一IP签发新会话受NewSessions限速，避免通过不断换新会话绕过用户级的额度及限速
+	WebSession struct {
+		Secret        []byte
+		TTL           time.Duration
+		TrustedHeader string
+		Limiter       domain.RateLimiter
+		NewSessions   domain.RateLimit
+	}
+
+	webSessionKey struct{}
+
+	webPrompt struct {
+		Text string `json:"text"`
+	}
+
+	webReply struct {
+		MessageID string `json:"message_id,omitempty"`
+		Reply     string `json:"reply,omitempty"`
+	}
+)
+
+const (
+	webSessionCookie = "chatgpt_bot_session"
+	// 单个SSE连接的最长时间，到期后浏览器会自动重连
+	webStreamDuration = 10 * time.Minute
+	webKeepalive      = 25 * time.Second
+)
+
+//go:embed web
+var webFS embed.FS
+
+var _ httpsrv.Controller = (*webController)(nil)
+
+func NewWebController(app *application.Application, router *CommandRouter, hub *application.WebHub, session WebSession) httpsrv.Controller {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err)
+	}
+	return &webController{
+		app:     app,
+		router:  router,
+		hub:     hub,
+		session: session,
+		static:  http.StripPrefix("/web", http.FileServer(http.FS(sub))),
+	}
+}
+
+func (ctrl *webController) Slug() string {
+	return "/web"
+}
+
+func (ctrl *webController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodPost,
+			Pattern: "/api/messages",
+			Func:    ctrl.Prompt,
+		},
+		{
+			Method:    http.MethodGet,
+			Pattern:   "/api/events",
+			Func:      ctrl.Events,
+			LongLived: true,
+		},
+		{
+			Method:  http.MethodGet,
+			Pattern: "/api/labels",
+			Func:    ctrl.Labels,
+		},
+		{
+			Method:  http.MethodGet,
+			Pattern: "/*",
+			Func:    ctrl.Static,
+		},
+	}
+}
+
+func (ctrl *webController) Middlewares() []httpsrv.Middleware {
+	return []httpsrv.Middleware{
+		{Middleware: ctrl.authenticate, Scope: httpsrv.ScopeController},
+	}
+}
+
+// authenticate 使用可信请求头中的用户身份，或校验会话cookie，无效时签发新的匿名会话
+func (ctrl *webController) authenticate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if ctrl.session.TrustedHeader != "" {
+			uid := strings.TrimSpace(r.Header.Get(ctrl.session.TrustedHeader))
+			if uid == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			from := domain.From{Channel: domain.ChannelWeb, ChannelUserID: domain.ChannelUserID(uid)}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webSessionKey{}, from)))
+			return
+		}
+
+		uid, ok := "", false
+		if cookie, err := r.Cookie(webSessionCookie); err == nil {
+			uid, ok = verifySession(ctrl.session.Secret, cookie.Value, ctrl.session.TTL, time.Now())
+		}
+		if !ok {
+			if wait := ctrl.takeNewSession(r); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			uid = ulid.Make().String()
+			http.SetCookie(w, &http.Cookie{
+				Name:     webSessionCookie,
+				Value:    signSession(ctrl.session.Secret, uid, time.Now()),
+				Path:     ctrl.Slug(),
+				MaxAge:   int(ctrl.session.TTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+		from := domain.From{Channel: domain.ChannelWeb, ChannelUserID: domain.ChannelUserID(uid)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webSessionKey{}, from)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// takeNewSession 按客户端IP对签发新会话限速，限速器异常时放行
+func (ctrl *webController) takeNewSession(r *http.Request) time.Duration {
+	if ctrl.session.Limiter == nil || !ctrl.session.NewSessions.Enabled() {
+		return 0
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	key := domain.From{Channel: domain.ChannelWeb, ChannelUserID: domain.ChannelUserID("ip:" + ip)}
+	wait, err := ctrl.session.Limiter.Take(r.Context(), key, ctrl.session.NewSessions)
+	if err != nil {
+		logger.NewHelper(logger.FromContext(r.Context())).Error("failed to take token for new web session", "client_ip", ip, "error", err.Error())
+		return 0
+	}
+	return wait
+}
+
+func (ctrl *webController) Static(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ctrl.Slug() {
+		http.Redirect(w, r, ctrl.Slug()+"/", http.StatusMovedPermanently)
+		return
+	}
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'")
+	ctrl.static.ServeHTTP(w, r)
+}
+
+// webLabels 页面中需要翻译的文案，页面加载后按用户的语言替换
+var webLabels = map[string]string{
+	"start":       "web.start",
+	"end":         "web.end",
+	"placeholder": "web.placeholder",
+	"send":        "web.send",
+	"welcome":     "web.welcome",
+}
+
+func (ctrl *webController) Labels(w http.ResponseWriter, r *http.Request) {
+	from := r.Context().Value(webSessionKey{}).(domain.From)
+	log := logger.With(logger.FromContext(r.Context()), "web_user_id", from.ChannelUserID)
+	env := &Envelope{From: from, Log: log, Language: r.Header.Get("Accept-Language")}
+	locale := ctrl.router.Locale(r.Context(), env)
+	labels := make(map[string]string, len(webLabels))
+	for name, key := range webLabels {
+		labels[name] = ctrl.router.T(locale, key)
+	}
+	writeJSON(w, http.StatusOK, labels)
+}
+
+// Prompt 命令的结果同步返回，prompt的回复通过SSE推送
+func (ctrl *webController) Prompt(w http.ResponseWriter, r *http.Request) {
+	from := r.Context().Value(webSessionKey{}).(domain.From)
+	log := logger.With(logger.FromContext(r.Context()), "web_user_id", from.ChannelUserID)
+
+	req := new(webPrompt)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(req); err != nil || strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+
+	var reply webReply
+	msgID := ulid.Make().String()
+	env := &Envelope{From: from, Text: req.Text, MessageID: domain.ChannelMessageID(msgID), Log: log, Language: r.Header.Get("Accept-Language")}
+	text, ok := ctrl.router.Reply(r.Context(), env)
+	if ok {
+		reply.Reply = text
+	} else {
+		reply.MessageID = msgID
+		if err := ctrl.app.Prompt(r.Context(), log, from, req.Text, domain.ChannelMessageID(msgID)); err != nil {
+			reply.Reply = ctrl.router.ErrorText(r.Context(), env, err)
+		}
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// Events 以SSE推送当前会话用户的消息，支持Last-Event-ID补发
+func (ctrl *webController) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	from := r.Context().Value(webSessionKey{}).(domain.From)
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	ch, missed, cancel := ctrl.hub.Subscribe(from, lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 1000\n\n")
+	for _, msg := range missed {
+		writeEvent(w, msg)
+	}
+	flusher.Flush()
+
+	ctx := r.Context()
+	timeout := time.NewTimer(webStreamDuration)
+	defer timeout.Stop()
+	keepalive := time.NewTicker(webKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pkgCtx.RootContext().Done():
+			return
+		case <-timeout.C:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case msg := <-ch:
+			writeEvent(w, msg)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, msg *application.WebMessage) {
+	data, _ := json.Marshal(msg)
+	fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", msg.ID, data)
+}
+
+// signSession 签名中包含签发时间，cookie的MaxAge可被客户端篡改，过期由服务端校验
+func signSession(secret []byte, uid string, issuedAt time.Time) string {
+	payload := uid + "." + strconv.FormatInt(issuedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySession 签名无效或签发超过ttl的会话视为无效
+func verifySession(secret []byte, value string, ttl time.Duration, now time.Time) (string, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", false
+	}
+	issued, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	issuedAt := time.Unix(issued, 0)
+	if !hmac.Equal([]byte(signSession(secret, parts[0], issuedAt)), []byte(value)) {
+		return "", false
+	}
+	if now.Sub(issuedAt) > ttl || issuedAt.After(now) {
+		return "", false
+	}
+	return parts[0], true
+}
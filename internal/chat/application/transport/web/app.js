@@ -0,0 +1,102 @@
+(function () {
+  'use strict';
+
+  var messages = document.getElementById('messages');
+  var input = document.getElementById('input');
+  // 等待回复的消息，key为message_id
+  var pending = {};
+
+  function append(className, text) {
+    var div = document.createElement('div');
+    div.className = 'message ' + className;
+    div.textContent = text;
+    messages.appendChild(div);
+    messages.scrollTop = messages.scrollHeight;
+    return div;
+  }
+
+  function send(text) {
+    return fetch('api/messages', {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ text: text })
+    })
+      .then(function (resp) {
+        if (!resp.ok) {
+          return resp.text().then(function (body) { throw new Error(body || resp.statusText); });
+        }
+        return resp.json();
+      })
+      .then(function (data) {
+        if (data.reply) {
+          append(data.reply.indexOf('[ERR]') === 0 ? 'error' : 'system', data.reply);
+        } else if (data.message_id && !pending[data.message_id]) {
+          pending[data.message_id] = append('bot typing', '…');
+        }
+      })
+      .catch(function (err) {
+        append('error', String(err.message || err));
+      });
+  }
+
+  function receive(msg) {
+    var placeholder = pending[msg.message_id];
+    if (msg.kind === 'typing') {
+      if (!placeholder) {
+        pending[msg.message_id] = append('bot typing', '…');
+      }
+      return;
+    }
+    delete pending[msg.message_id];
+    var className = msg.kind === 'reply' ? 'bot' : 'error';
+    if (placeholder) {
+      placeholder.className = 'message ' + className;
+      placeholder.textContent = msg.text;
+    } else {
+      append(className, msg.text);
+    }
+  }
+
+  var events = new EventSource('api/events');
+  events.addEventListener('message', function (e) {
+    receive(JSON.parse(e.data));
+  });
+
+  document.getElementById('composer').addEventListener('submit', function (e) {
+    e.preventDefault();
+    var text = input.value.trim();
+    if (!text) {
+      return;
+    }
+    input.value = '';
+    append('user', text);
+    send(text);
+  });
+
+  input.addEventListener('keydown', function (e) {
+    if (e.key === 'Enter' && !e.shiftKey && !e.isComposing) {
+      e.preventDefault();
+      document.getElementById('composer').requestSubmit();
+    }
+  });
+
+  document.getElementById('start').addEventListener('click', function () { send('/start'); });
+  document.getElementById('end').addEventListener('click', function () { send('/end'); });
+
+  // 按用户的语言替换页面文案，失败时保留页面中的缺省文案
+  fetch('api/labels', { credentials: 'same-origin' })
+    .then(function (resp) { return resp.ok ? resp.json() : {}; })
+    .catch(function () { return {}; })
+    .then(function (labels) {
+      ['start', 'end', 'send'].forEach(function (id) {
+        if (labels[id]) {
+          document.getElementById(id).textContent = labels[id];
+        }
+      });
+      if (labels.placeholder) {
+        input.placeholder = labels.placeholder;
+      }
+      append('system', labels.welcome || '点击“新会话”开始聊天');
+    });
+})();
@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1686355200, 0)
+	value := signSession(secret, "01H2", now)
+
+	uid, ok := verifySession(secret, value, time.Hour, now.Add(30*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "01H2", uid)
+
+	// 超过有效期
+	_, ok = verifySession(secret, value, time.Hour, now.Add(2*time.Hour))
+	assert.False(t, ok)
+	// 篡改签发时间
+	forged := strings.Replace(value, strconv.FormatInt(now.Unix(), 10), strconv.FormatInt(now.Add(time.Hour).Unix(), 10), 1)
+	_, ok = verifySession(secret, forged, time.Hour, now.Add(2*time.Hour))
+	assert.False(t, ok)
+	// 旧格式的cookie没有签发时间
+	_, ok = verifySession(secret, "01H2.c2lnbmF0dXJl", time.Hour, now)
+	assert.False(t, ok)
+	_, ok = verifySession([]byte("other"), value, time.Hour, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wecom"
+	"github.com/jacexh/chatgpt-bot/internal/chat/application"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type (
+	// wecomController 处理企业微信自建应用的回调，消息均为加密模式
+	wecomController struct {
+		app    *application.Application
+		router *CommandRouter
+		client *wecom.Client
+		seen   *eventDedup
+	}
+
+	wecomEnvelope struct {
+		ToUserName string `xml:"ToUserName"`
+		AgentID    string `xml:"AgentID"`
+		Encrypt    string `xml:"Encrypt"`
+	}
+
+	wecomMessage struct {
+		FromUserName string `xml:"FromUserName"`
+		MsgType      string `xml:"MsgType"`
+		Content      string `xml:"Content"`
+		MediaID      string `xml:"MediaId"`
+		Format       string `xml:"Format"`
+		MsgID        string `xml:"MsgId"`
+	}
+)
+
+// 语音下载及转写耗时较长，在请求之外异步处理
+const wecomVoiceTimeout = time.Minute
+
+var _ httpsrv.Controller = (*wecomController)(nil)
+
+func NewWecomController(app *application.Application, router *CommandRouter, client *wecom.Client) httpsrv.Controller {
+	return &wecomController{app: app, router: router, client: client, seen: newEventDedup(time.Hour)}
+}
+
+func (ctrl *wecomController) Slug() string {
+	return "/api/v1/wecom"
+}
+
+func (ctrl *wecomController) APIs() []httpsrv.API {
+	return []httpsrv.API{
+		{
+			Method:  http.MethodGet,
+			Pattern: "/callback",
+			Func:    ctrl.VerifyURL,
+		},
+		{
+			Method:  http.MethodPost,
+			Pattern: "/callback",
+			Func:    ctrl.Callback,
+		},
+	}
+}
+
+func (ctrl *wecomController) Middlewares() []httpsrv.Middleware {
+	return nil
+}
+
+// VerifyURL 配置回调地址时企业微信发送GET请求，需返回解密后的echostr明文
+func (ctrl *wecomController) VerifyURL(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	echo := query.Get("echostr")
+	if err := ctrl.client.VerifySignature(query.Get("msg_signature"), query.Get("timestamp"), query.Get("nonce"), echo); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	plain, err := ctrl.client.Decrypt(echo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, _ = w.Write(plain)
+}
+
+// Callback 企业微信要求5秒内响应，否则会重试3次，重复的MsgId会被忽略；回复通过应用消息接口发送
+func (ctrl *wecomController) Callback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	envelope := new(wecomEnvelope)
+	if err = xml.Unmarshal(body, envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	if err = ctrl.client.VerifySignature(query.Get("msg_signature"), query.Get("timestamp"), query.Get("nonce"), envelope.Encrypt); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	plain, err := ctrl.client.Decrypt(envelope.Encrypt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := new(wecomMessage)
+	if err = xml.Unmarshal(plain, msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 进入应用等事件没有MsgId，不需要处理
+	if msg.MsgType != "event" && !ctrl.seen.Seen(msg.MsgID) {
+		ctrl.handle(r, msg)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ctrl *wecomController) handle(r *http.Request, msg *wecomMessage) {
+	from := domain.From{Channel: domain.ChannelWecom, ChannelUserID: domain.ChannelUserID(msg.FromUserName)}
+	log := logger.With(logger.FromContext(r.Context()), "wecom_user_id", msg.FromUserName, "wecom_message_id", msg.MsgID)
+
+	switch msg.MsgType {
+	case "text":
+		ctrl.reply(r.Context(), log, from, ctrl.prompt(r.Context(), log, from, msg.Content, domain.ChannelMessageID(msg.MsgID)))
+
+	case "voice":
+		go func() {
+			ctx, cancel := pkgCtx.GenContextWithTimeout(wecomVoiceTimeout)
+			defer cancel()
+			ctrl.reply(ctx, log, from, ctrl.voice(ctx, log, from, msg))
+		}()
+
+	default:
+		ctrl.reply(r.Context(), log, from, ctrl.router.Localize(r.Context(), &Envelope{From: from, Log: log}, "wecom.unsupported"))
+	}
+}
+
+// voice 下载语音并转写，转写结果回显给用户后作为prompt提交
+func (ctrl *wecomController) voice(ctx context.Context, log logger.Logger, from domain.From, msg *wecomMessage) string {
+	env := &Envelope{From: from, MessageID: domain.ChannelMessageID(msg.MsgID), Log: log}
+	audio, err := ctrl.client.Media(ctx, msg.MediaID)
+	if err != nil {
+		logger.NewHelper(log).Error("failed to download wecom voice", "media_id", msg.MediaID, "error", err.Error())
+		return ctrl.router.ErrorText(ctx, env, err)
+	}
+	text, err := ctrl.app.Transcribe(ctx, log, from, msg.Format, audio)
+	if err != nil {
+		return ctrl.router.ErrorText(ctx, env, err)
+	}
+	if text == "" {
+		return ctrl.router.Localize(ctx, env, "wecom.voice_unrecognized")
+	}
+	ctrl.reply(ctx, log, from, ctrl.router.Localize(ctx, env, "wecom.voice_text", text))
+	return ctrl.prompt(ctx, log, from, text, domain.ChannelMessageID(msg.MsgID))
+}
+
+// prompt 处理命令及prompt，返回需要立即回复的文本
+func (ctrl *wecomController) prompt(ctx context.Context, log logger.Logger, from domain.From, text string, msgID domain.ChannelMessageID) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	env := &Envelope{From: from, Text: text, MessageID: msgID, Log: log}
+	if reply, ok := ctrl.router.Reply(ctx, env); ok {
+		return reply
+	}
+	if err := promptOrStart(ctx, ctrl.app, log, from, "", text, msgID); err != nil {
+		return ctrl.router.ErrorText(ctx, env, err)
+	}
+	return ""
+}
+
+func (ctrl *wecomController) reply(ctx context.Context, log logger.Logger, from domain.From, text string) {
+	if text == "" {
+		return
+	}
+	if err := ctrl.client.SendText(ctx, string(from.ChannelUserID), text); err != nil {
+		logger.NewHelper(log).Error("failed to send message to wecom", "error", err.Error())
+	}
+}
@@ -0,0 +1,158 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	// WebMessage 推送给浏览器的消息，ID在进程内单调递增，用于断线重连后补发
+	WebMessage struct {
+		ID        int64  `json:"-"`
+		Kind      string `json:"kind"`
+		MessageID string `json:"message_id"`
+		Text      string `json:"text"`
+	}
+
+	// WebHub 维护浏览器的SSE订阅，仅投递给本实例上的连接
+	WebHub struct {
+		mu    sync.Mutex
+		seq   int64
+		users map[domain.From]*webInbox
+	}
+
+	webInbox struct {
+		subscribers map[chan *WebMessage]struct{}
+		backlog     []*WebMessage
+		updatedAt   time.Time
+	}
+
+	WebEventHandler struct {
+		hub       *WebHub
+		localizer *Localizer
+		log       logger.Logger
+	}
+)
+
+const (
+	WebKindTyping  = "typing"
+	WebKindReply   = "reply"
+	WebKindError   = "error"
+	WebKindBlocked = "blocked"
+
+	webBacklogSize = 32
+	// 无订阅且超过该时间没有新消息的用户会被清理
+	webInboxIdleTime = 10 * time.Minute
+)
+
+func NewWebHub() *WebHub {
+	return &WebHub{users: make(map[domain.From]*webInbox)}
+}
+
+func (h *WebHub) Publish(f domain.From, msg *WebMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.prune(now)
+	h.seq++
+	msg.ID = h.seq
+
+	inbox, ok := h.users[f]
+	if !ok {
+		inbox = &webInbox{subscribers: make(map[chan *WebMessage]struct{})}
+		h.users[f] = inbox
+	}
+	inbox.updatedAt = now
+	inbox.backlog = append(inbox.backlog, msg)
+	if len(inbox.backlog) > webBacklogSize {
+		inbox.backlog = inbox.backlog[len(inbox.backlog)-webBacklogSize:]
+	}
+	for ch := range inbox.subscribers {
+		select {
+		case ch <- msg:
+		default: // 消费过慢的连接由客户端重连后从backlog补发
+		}
+	}
+}
+
+// Subscribe 返回订阅通道、lastID之后的历史消息及取消订阅函数
+func (h *WebHub) Subscribe(f domain.From, lastID int64) (<-chan *WebMessage, []*WebMessage, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	inbox, ok := h.users[f]
+	if !ok {
+		inbox = &webInbox{subscribers: make(map[chan *WebMessage]struct{}), updatedAt: time.Now()}
+		h.users[f] = inbox
+	}
+	var missed []*WebMessage
+	for _, msg := range inbox.backlog {
+		if msg.ID > lastID {
+			missed = append(missed, msg)
+		}
+	}
+
+	ch := make(chan *WebMessage, webBacklogSize)
+	inbox.subscribers[ch] = struct{}{}
+	return ch, missed, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(inbox.subscribers, ch)
+		inbox.updatedAt = time.Now()
+	}
+}
+
+func (h *WebHub) prune(now time.Time) {
+	for f, inbox := range h.users {
+		if len(inbox.subscribers) == 0 && now.Sub(inbox.updatedAt) > webInboxIdleTime {
+			delete(h.users, f)
+		}
+	}
+}
+
+func NewWebEventHandler(log logger.Logger, localizer *Localizer, hub *WebHub) mediator.EventHandler {
+	return &WebEventHandler{log: log, localizer: localizer, hub: hub}
+}
+
+func (ev *WebEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindConversationCreated,
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+func (ev *WebEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelWeb {
+		return
+	}
+
+	msg := &WebMessage{MessageID: string(e.Conversation.MessageID)}
+	switch e.Kind() {
+	case domain.KindConversationCreated:
+		msg.Kind = WebKindTyping
+
+	case domain.KindConversationReplied:
+		msg.Kind = WebKindReply
+		msg.Text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		msg.Kind = WebKindError
+		msg.Text = ev.localizer.ErrorReply(ctx, ev.log, e.From, e.Error)
+		logger.NewHelper(ev.log).Error("current conversation was interrupted", "chat_id", e.ChatID, "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		msg.Kind = WebKindBlocked
+		msg.Text = ev.localizer.BlockedReply(ctx, ev.log, e.From, e.Category)
+		logger.NewHelper(ev.log).Warn("conversation was blocked by moderation", "chat_id", e.ChatID, "category", e.Category)
+	}
+	ev.hub.Publish(e.From, msg)
+}
@@ -0,0 +1,62 @@
+package application
+
+import (
+	"context"
+
+	"github.com/go-jimu/components/logger"
+	"github.com/go-jimu/components/mediator"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wecom"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+)
+
+type WecomEventHandler struct {
+	client    *wecom.Client
+	localizer *Localizer
+	log       logger.Logger
+}
+
+func NewWecomEventHandler(log logger.Logger, localizer *Localizer, client *wecom.Client) mediator.EventHandler {
+	return &WecomEventHandler{log: log, localizer: localizer, client: client}
+}
+
+func (ev *WecomEventHandler) Listening() []mediator.EventKind {
+	return []mediator.EventKind{
+		domain.KindCoversationInterrupted,
+		domain.KindConversationReplied,
+		domain.KindConversationBlocked,
+	}
+}
+
+// Handle 以应用消息发送给成员，超出长度的回复拆分为多条
+func (ev *WecomEventHandler) Handle(ctx context.Context, event mediator.Event) {
+	e := event.(domain.MetaEvent)
+	if e.Channel() != domain.ChannelWecom {
+		return
+	}
+	log := logger.With(ev.log, "chat_id", e.ChatID, "wecom_user_id", e.From.ChannelUserID, "message_id", e.Conversation.MessageID, "event_kind", event.Kind())
+	helper := logger.NewHelper(log)
+
+	var text string
+	switch e.Kind() {
+	case domain.KindConversationReplied:
+		text = e.Conversation.Completion
+
+	case domain.KindCoversationInterrupted:
+		text = ev.localizer.ErrorReply(ctx, log, e.From, e.Error)
+		helper.Error("current conversation was interrupted", "error", e.Error.Error())
+
+	case domain.KindConversationBlocked:
+		text = ev.localizer.BlockedReply(ctx, log, e.From, e.Category)
+		helper.Warn("conversation was blocked by moderation", "category", e.Category)
+	}
+
+	ctx, cancel := pkgCtx.GenDefaultContext()
+	defer cancel()
+	for _, part := range domain.SplitText(text, wecom.MessageLimit) {
+		if err := ev.client.SendText(ctx, string(e.From.ChannelUserID), part); err != nil {
+			helper.Error("failed to send message to wecom", "error", err.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+type (
+	// AccessRule 针对单个用户的访问规则
+	AccessRule int
+
+	// AccessPolicy 访问策略：拒绝优先于允许，均未命中时使用通道的默认规则
+	AccessPolicy struct {
+		defaults map[Channel]AccessRule
+		allowed  map[From]struct{}
+		denied   map[From]struct{}
+	}
+
+	// Invitation 一次性邀请码
+	Invitation struct {
+		Code       string
+		RedeemedBy *From
+		CreatedAt  time.Time
+		RedeemedAt time.Time
+	}
+)
+
+const (
+	AccessUndefined AccessRule = iota
+	AccessAllowed
+	AccessDenied
+)
+
+var (
+	ErrAccessDenied       = errors.New("您暂无使用权限，请发送 /invite <邀请码> 激活")
+	ErrInvitationRedeemed = errors.New("邀请码已被使用")
+	ErrAccessBlocked      = errors.New("您已被禁止使用，无法通过邀请码激活")
+
+	codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+func NewAccessPolicy() *AccessPolicy {
+	return &AccessPolicy{
+		defaults: make(map[Channel]AccessRule),
+		allowed:  make(map[From]struct{}),
+		denied:   make(map[From]struct{}),
+	}
+}
+
+func (ap *AccessPolicy) SetDefault(ch Channel, rule AccessRule) {
+	ap.defaults[ch] = rule
+}
+
+func (ap *AccessPolicy) Allow(f From) {
+	ap.allowed[f] = struct{}{}
+}
+
+func (ap *AccessPolicy) Deny(f From) {
+	ap.denied[f] = struct{}{}
+}
+
+// Blocked 用户在静态拒绝名单中或被管理员拒绝，此时邀请码也无法放行
+func (ap *AccessPolicy) Blocked(f From, rule AccessRule) bool {
+	_, ok := ap.denied[f]
+	return ok || rule == AccessDenied
+}
+
+// Permit 结合静态名单与用户规则判断是否放行，未配置默认规则的通道默认放行
+func (ap *AccessPolicy) Permit(f From, rule AccessRule) bool {
+	if ap.Blocked(f, rule) {
+		return false
+	}
+	if _, ok := ap.allowed[f]; ok || rule == AccessAllowed {
+		return true
+	}
+	return ap.defaults[f.Channel] != AccessDenied
+}
+
+func NewInvitation() *Invitation {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return &Invitation{Code: codeEncoding.EncodeToString(buf), CreatedAt: time.Now()}
+}
+
+func (inv *Invitation) IsRedeemed() bool {
+	return inv.RedeemedBy != nil
+}
+
+func (inv *Invitation) Redeem(f From) error {
+	if inv.IsRedeemed() {
+		return ErrInvitationRedeemed
+	}
+	inv.RedeemedBy = &f
+	inv.RedeemedAt = time.Now()
+	return nil
+}
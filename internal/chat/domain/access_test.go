@@ -0,0 +1,36 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	alice := domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "alice"}
+	bob := domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "bob"}
+	carol := domain.From{Channel: domain.ChannelWechat, ChannelUserID: "carol"}
+
+	policy := domain.NewAccessPolicy()
+	policy.SetDefault(domain.ChannelTelegram, domain.AccessDenied)
+	policy.Allow(alice)
+	policy.Deny(carol)
+
+	assert.True(t, policy.Permit(alice, domain.AccessUndefined))
+	assert.False(t, policy.Permit(alice, domain.AccessDenied))
+	assert.False(t, policy.Permit(bob, domain.AccessUndefined))
+	assert.True(t, policy.Permit(bob, domain.AccessAllowed))
+	assert.False(t, policy.Permit(carol, domain.AccessAllowed))
+
+	assert.True(t, policy.Blocked(alice, domain.AccessDenied))
+	assert.True(t, policy.Blocked(carol, domain.AccessUndefined))
+	assert.False(t, policy.Blocked(bob, domain.AccessUndefined))
+}
+
+func TestInvitation(t *testing.T) {
+	inv := domain.NewInvitation()
+	assert.Len(t, inv.Code, 16)
+	assert.NoError(t, inv.Redeem(domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "alice"}))
+	assert.ErrorIs(t, inv.Redeem(domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "bob"}), domain.ErrInvitationRedeemed)
+}
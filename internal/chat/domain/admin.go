@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type (
+	// AdminList 各通道的管理员
+	AdminList struct {
+		members map[From]struct{}
+	}
+
+	// Stats 运营统计，Conversations及Usage为统计起点之后的数据
+	Stats struct {
+		Users         int64
+		ActiveChats   int64
+		Conversations int64
+		Usage         Usage
+	}
+
+	Pagination struct {
+		Page     int
+		PageSize int
+	}
+
+	// ChatFilter 筛选会话，零值字段表示不限制，时间范围作用于会话创建时间
+	ChatFilter struct {
+		Channel       Channel
+		ChannelUserID ChannelUserID
+		Status        *Status
+		Pending       bool // 仅返回有prompt等待回复的会话
+		CreatedFrom   time.Time
+		CreatedTo     time.Time
+	}
+
+	// ChatSummary 会话概要，不包含对话内容
+	ChatSummary struct {
+		ID        string
+		From      From
+		Status    Status
+		Counts    int
+		Pending   bool
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	// UserUsage 用户的累计会话数及token用量
+	UserUsage struct {
+		From          From
+		Chats         int64
+		Conversations int64
+		Usage         Usage
+		LastActiveAt  time.Time
+	}
+
+	// UserFilter 筛选用户，Channel为0表示全部通道，ActiveSince为零值表示不限制最后活跃时间
+	UserFilter struct {
+		Channel     Channel
+		ActiveSince time.Time
+	}
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrPermissionDenied = errors.New("无权执行该命令")
+
+// NewPagination 页码从1开始，超出范围的参数使用默认值
+func NewPagination(page, size int) Pagination {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return Pagination{Page: page, PageSize: size}
+}
+
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+func NewAdminList() *AdminList {
+	return &AdminList{members: make(map[From]struct{})}
+}
+
+func (al *AdminList) Add(f From) {
+	al.members[f] = struct{}{}
+}
+
+func (al *AdminList) IsAdmin(f From) bool {
+	_, ok := al.members[f]
+	return ok
+}
+
+// ParseFrom 解析channel:user_id格式的用户，省略通道时使用默认通道
+func ParseFrom(s string, defaultChannel Channel) (From, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return From{}, errors.New("missing user id")
+	}
+	channel := defaultChannel
+	if name, uid, ok := strings.Cut(s, ":"); ok {
+		ch, err := ParseChannel(name)
+		if err != nil {
+			return From{}, err
+		}
+		channel, s = ch, uid
+	}
+	if s == "" {
+		return From{}, errors.New("missing user id")
+	}
+	return From{Channel: channel, ChannelUserID: ChannelUserID(s)}, nil
+}
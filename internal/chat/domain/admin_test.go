@@ -0,0 +1,42 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFrom(t *testing.T) {
+	f, err := domain.ParseFrom("12345", domain.ChannelTelegram)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "12345"}, f)
+
+	f, err = domain.ParseFrom("wechat:oABC", domain.ChannelTelegram)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.From{Channel: domain.ChannelWechat, ChannelUserID: "oABC"}, f)
+
+	f, err = domain.ParseFrom("slack:T1:U1", domain.ChannelTelegram)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewSlackFrom("T1", "U1"), f)
+
+	_, err = domain.ParseFrom("irc:U1", domain.ChannelTelegram)
+	assert.Error(t, err)
+	_, err = domain.ParseFrom("wechat:", domain.ChannelTelegram)
+	assert.Error(t, err)
+
+	admins := domain.NewAdminList()
+	admins.Add(domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "12345"})
+	assert.True(t, admins.IsAdmin(domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "12345"}))
+	assert.False(t, admins.IsAdmin(domain.From{Channel: domain.ChannelWechat, ChannelUserID: "12345"}))
+}
+
+func TestPagination(t *testing.T) {
+	p := domain.NewPagination(0, 0)
+	assert.Equal(t, domain.Pagination{Page: 1, PageSize: domain.DefaultPageSize}, p)
+	assert.Equal(t, 0, p.Offset())
+
+	p = domain.NewPagination(3, 1000)
+	assert.Equal(t, domain.MaxPageSize, p.PageSize)
+	assert.Equal(t, 200, p.Offset())
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type (
+	BroadcastStatus int
+
+	DeliveryStatus int
+
+	// Broadcast 管理员发起的群发任务，接收人在创建时确定，逐个投递并记录进度以便中断后继续
+	Broadcast struct {
+		ID        string
+		Text      string
+		Filter    UserFilter
+		Status    BroadcastStatus
+		Total     int
+		Sent      int
+		Failed    int
+		CreatedAt time.Time
+	}
+
+	Delivery struct {
+		ID          int64
+		BroadcastID string
+		To          From
+		Status      DeliveryStatus
+		Error       string
+		Owner       string // 认领该投递的实例
+	}
+)
+
+var ErrBroadcastUnsupported = errors.New("该通道不支持群发")
+
+const (
+	BroadcastRunning BroadcastStatus = iota + 1
+	BroadcastFinished
+)
+
+const (
+	DeliveryPending DeliveryStatus = iota
+	DeliverySent
+	DeliveryFailed
+	// DeliverySending 已被某个实例认领，租约到期前其他实例不会重复投递
+	DeliverySending
+)
+
+// WechatMessageWindow 微信客服消息只能发送给该时间内与公众号有过互动的用户
+const WechatMessageWindow = 48 * time.Hour
+
+// Channels 所有已知通道，按枚举值排序
+func Channels() []Channel {
+	channels := make([]Channel, 0, len(channelNames))
+	for channel := range channelNames {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+	return channels
+}
+
+// MessageWindow 通道允许主动推送的用户最后活跃时间窗口，0表示不限制
+func MessageWindow(ch Channel) time.Duration {
+	if ch == ChannelWechat {
+		return WechatMessageWindow
+	}
+	return 0
+}
+
+// Within 在筛选条件上叠加通道的推送窗口
+func (uf UserFilter) Within(ch Channel, now time.Time) UserFilter {
+	filter := UserFilter{Channel: ch, ActiveSince: uf.ActiveSince}
+	if window := MessageWindow(ch); window > 0 {
+		if since := now.Add(-window); since.After(filter.ActiveSince) {
+			filter.ActiveSince = since
+		}
+	}
+	return filter
+}
+
+func NewBroadcast(text string, filter UserFilter, recipients []From) (*Broadcast, []*Delivery, error) {
+	if text == "" {
+		return nil, nil, errors.New("disallow empty broadcast")
+	}
+	if len(recipients) == 0 {
+		return nil, nil, errors.New("no recipients matched")
+	}
+	b := &Broadcast{
+		ID:        ulid.Make().String(),
+		Text:      text,
+		Filter:    filter,
+		Status:    BroadcastRunning,
+		Total:     len(recipients),
+		CreatedAt: time.Now(),
+	}
+	deliveries := make([]*Delivery, len(recipients))
+	for index, to := range recipients {
+		deliveries[index] = &Delivery{BroadcastID: b.ID, To: to, Status: DeliveryPending}
+	}
+	return b, deliveries, nil
+}
+
+// Record 记录单个接收人的投递结果
+func (b *Broadcast) Record(d *Delivery, err error) {
+	if err != nil {
+		d.Status = DeliveryFailed
+		d.Error = err.Error()
+		b.Failed++
+		return
+	}
+	d.Status = DeliverySent
+	b.Sent++
+}
+
+func (b *Broadcast) Finish() {
+	b.Status = BroadcastFinished
+}
+
+func (b *Broadcast) IsFinished() bool {
+	return b.Status == BroadcastFinished
+}
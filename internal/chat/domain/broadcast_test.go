@@ -0,0 +1,45 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcast(t *testing.T) {
+	_, _, err := domain.NewBroadcast("", domain.UserFilter{}, []domain.From{{Channel: domain.ChannelTelegram, ChannelUserID: "1"}})
+	assert.Error(t, err)
+	_, _, err = domain.NewBroadcast("hello", domain.UserFilter{}, nil)
+	assert.Error(t, err)
+
+	b, deliveries, err := domain.NewBroadcast("hello", domain.UserFilter{}, []domain.From{
+		{Channel: domain.ChannelTelegram, ChannelUserID: "1"},
+		{Channel: domain.ChannelWechat, ChannelUserID: "o1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, b.Total)
+	assert.Len(t, deliveries, 2)
+
+	b.Record(deliveries[0], nil)
+	b.Record(deliveries[1], errors.New("out of window"))
+	assert.Equal(t, domain.DeliverySent, deliveries[0].Status)
+	assert.Equal(t, domain.DeliveryFailed, deliveries[1].Status)
+	assert.Equal(t, "out of window", deliveries[1].Error)
+	assert.Equal(t, 1, b.Sent)
+	assert.Equal(t, 1, b.Failed)
+}
+
+func TestUserFilterWithin(t *testing.T) {
+	now := time.Date(2023, 6, 10, 0, 0, 0, 0, time.UTC)
+	filter := domain.UserFilter{ActiveSince: now.Add(-7 * 24 * time.Hour)}
+
+	assert.Equal(t, filter.ActiveSince, filter.Within(domain.ChannelTelegram, now).ActiveSince)
+	assert.Equal(t, domain.ChannelTelegram, filter.Within(domain.ChannelTelegram, now).Channel)
+	assert.Equal(t, now.Add(-domain.WechatMessageWindow), filter.Within(domain.ChannelWechat, now).ActiveSince)
+
+	recent := domain.UserFilter{ActiveSince: now.Add(-time.Hour)}
+	assert.Equal(t, recent.ActiveSince, recent.Within(domain.ChannelWechat, now).ActiveSince)
+}
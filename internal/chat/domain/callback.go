@@ -0,0 +1,247 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// CallbackEndpoint REST通道用户最近一次提交的回调地址
+	CallbackEndpoint struct {
+		From   From
+		Client string
+		URL    string
+	}
+
+	// CallbackEvent 推送给回调地址的事件内容
+	CallbackEvent struct {
+		Event      string `json:"event"`
+		ChatID     string `json:"chat_id"`
+		UserID     string `json:"user_id"`
+		MessageID  string `json:"message_id"`
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion,omitempty"`
+		Error      string `json:"error,omitempty"`
+		Category   string `json:"category,omitempty"`
+		Timestamp  int64  `json:"timestamp"`
+	}
+
+	CallbackStatus int
+
+	// CallbackDelivery 一次回调的投递记录
+	CallbackDelivery struct {
+		ID             int64
+		From           From
+		ChatID         string
+		Event          string
+		URL            string
+		Status         CallbackStatus
+		Attempts       int
+		ResponseStatus int
+		Error          string
+		Payload        []byte // 回调的请求体，进程重启后据此继续投递
+		Owner          string // 认领该投递的实例，租约到期前其他实例不会重复投递
+		LeaseUntil     time.Time
+		CreatedAt      time.Time
+		UpdatedAt      time.Time
+	}
+
+	CallbackRepository interface {
+		SaveEndpoint(context.Context, *CallbackEndpoint) error
+		GetEndpoint(context.Context, From) (*CallbackEndpoint, error)
+		SaveDelivery(context.Context, *CallbackDelivery) error
+		ListDeliveries(ctx context.Context, client string, p Pagination) ([]*CallbackDelivery, int64, error)
+		// ListPending 未完成且未被认领或租约已过期的投递，按创建顺序排列
+		ListPending(context.Context) ([]*CallbackDelivery, error)
+		// ClaimDelivery 认领或续期未完成的投递，已被其他实例认领且租约未到期时返回false
+		ClaimDelivery(context.Context, *CallbackDelivery, string, time.Time) (bool, error)
+	}
+
+	// CallbackSender 发送回调请求，返回对端的HTTP状态码
+	CallbackSender interface {
+		Send(ctx context.Context, url string, header map[string]string, body []byte) (int, error)
+	}
+)
+
+const (
+	CallbackPending CallbackStatus = iota
+	CallbackSucceeded
+	CallbackFailed
+)
+
+const (
+	CallbackEventCreated     = "created"
+	CallbackEventReplied     = "replied"
+	CallbackEventInterrupted = "interrupted"
+	CallbackEventBlocked     = "blocked"
+
+	CallbackSignatureHeader = "X-Signature"
+	CallbackTimestampHeader = "X-Timestamp"
+
+	DefaultCallbackAttempts = 5
+	callbackBaseBackoff     = time.Second
+	callbackMaxBackoff      = time.Minute
+)
+
+var callbackStatusNames = map[CallbackStatus]string{
+	CallbackPending:   "pending",
+	CallbackSucceeded: "succeeded",
+	CallbackFailed:    "failed",
+}
+
+func (s CallbackStatus) String() string {
+	return callbackStatusNames[s]
+}
+
+// NewRESTFrom REST通道的用户ID带有客户端前缀，不同客户端的同名用户互不影响
+func NewRESTFrom(client, userID string) (From, error) {
+	if userID == "" {
+		return From{}, errors.New("user_id is required")
+	}
+	uid := client + "/" + userID
+	if len(uid) > MaxChannelUserIDLength {
+		return From{}, errors.New("user_id is too long")
+	}
+	return From{Channel: ChannelREST, ChannelUserID: ChannelUserID(uid)}, nil
+}
+
+var restClientPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ValidateRESTClient 客户端名称作为用户ID的前缀并用于按前缀查询，仅允许字母、数字、下划线及连字符
+func ValidateRESTClient(name string) error {
+	if !restClientPattern.MatchString(name) {
+		return fmt.Errorf("invalid rest client name %q", name)
+	}
+	return nil
+}
+
+// RESTClient 用户ID中的客户端前缀
+func RESTClient(f From) string {
+	client, _, _ := strings.Cut(string(f.ChannelUserID), "/")
+	return client
+}
+
+// RESTUserID 去掉客户端前缀，还原调用方提交的user_id
+func RESTUserID(f From) string {
+	_, uid, ok := strings.Cut(string(f.ChannelUserID), "/")
+	if !ok {
+		return string(f.ChannelUserID)
+	}
+	return uid
+}
+
+// ValidateCallbackURL 仅接受http(s)的绝对地址，内网地址由发送端在建连时拒绝
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid callback_url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback_url must be an absolute http(s) url")
+	}
+	if len(raw) > 512 {
+		return errors.New("callback_url is too long")
+	}
+	return nil
+}
+
+// NewCallbackEvent 将会话事件转换为回调内容，不支持的事件返回false
+func NewCallbackEvent(e MetaEvent, now time.Time) (*CallbackEvent, bool) {
+	ce := &CallbackEvent{
+		ChatID:    e.ChatID,
+		UserID:    RESTUserID(e.From),
+		MessageID: string(e.Conversation.MessageID),
+		Prompt:    e.Conversation.Prompt,
+		Timestamp: now.Unix(),
+	}
+	switch e.Kind() {
+	case KindConversationCreated:
+		ce.Event = CallbackEventCreated
+	case KindConversationReplied:
+		ce.Event = CallbackEventReplied
+		ce.Completion = e.Conversation.Completion
+	case KindCoversationInterrupted:
+		ce.Event = CallbackEventInterrupted
+		if e.Error != nil {
+			ce.Error = e.Error.Error()
+		}
+	case KindConversationBlocked:
+		ce.Event = CallbackEventBlocked
+		ce.Category = e.Category
+	default:
+		return nil, false
+	}
+	return ce, true
+}
+
+// SignCallback 签名内容为"时间戳.请求体"，结果为HMAC-SHA256的十六进制，带sha256=前缀
+func SignCallback(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback 供接收方参考的校验逻辑，tolerance限制时间戳的偏差以防重放
+func VerifyCallback(secret []byte, timestamp int64, body []byte, signature string, now time.Time, tolerance time.Duration) bool {
+	if d := now.Sub(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(SignCallback(secret, timestamp, body)), []byte(signature))
+}
+
+func NewCallbackDelivery(e *CallbackEvent, ep *CallbackEndpoint, payload []byte) *CallbackDelivery {
+	return &CallbackDelivery{
+		From:    ep.From,
+		ChatID:  e.ChatID,
+		Event:   e.Event,
+		URL:     ep.URL,
+		Status:  CallbackPending,
+		Payload: payload,
+	}
+}
+
+// Record 记录一次尝试的结果，2xx视为成功；达到最大次数，或接收方返回4xx(408、429除外)时标记为失败
+func (d *CallbackDelivery) Record(status int, err error, maxAttempts int) {
+	d.Attempts++
+	d.ResponseStatus = status
+	switch {
+	case err != nil:
+		d.Error = err.Error()
+	case status < 200 || status >= 300:
+		d.Error = fmt.Sprintf("unexpected status %d", status)
+	default:
+		d.Error = ""
+		d.Status = CallbackSucceeded
+		return
+	}
+	rejected := status >= 400 && status < 500 && status != 408 && status != 429
+	if rejected || d.Attempts >= maxAttempts {
+		d.Status = CallbackFailed
+	}
+}
+
+// CallbackBackoff 第attempt次失败后的等待时间，指数增长并有上限
+func CallbackBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := callbackBaseBackoff
+	for i := 1; i < attempt && d < callbackMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > callbackMaxBackoff {
+		d = callbackMaxBackoff
+	}
+	return d
+}
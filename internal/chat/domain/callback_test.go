@@ -0,0 +1,100 @@
+package domain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRESTFrom(t *testing.T) {
+	f, err := domain.NewRESTFrom("crm", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ChannelREST, f.Channel)
+	assert.Equal(t, domain.ChannelUserID("crm/u1"), f.ChannelUserID)
+	assert.Equal(t, "u1", domain.RESTUserID(f))
+
+	_, err = domain.NewRESTFrom("crm", "")
+	assert.Error(t, err)
+	_, err = domain.NewRESTFrom("crm", strings.Repeat("0", domain.MaxChannelUserIDLength-3))
+	assert.Error(t, err)
+	assert.Equal(t, "crm", domain.RESTClient(f))
+
+	assert.NoError(t, domain.ValidateRESTClient("crm_v2-beta"))
+	for _, name := range []string{"", "a/b", "a%", "a_b c", "012345678901234567890123456789012"} {
+		assert.Error(t, domain.ValidateRESTClient(name), name)
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	assert.NoError(t, domain.ValidateCallbackURL("https://example.com/hook"))
+	assert.Error(t, domain.ValidateCallbackURL(""))
+	assert.Error(t, domain.ValidateCallbackURL("/hook"))
+	assert.Error(t, domain.ValidateCallbackURL("ftp://example.com/hook"))
+}
+
+func TestSignCallback(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"event":"replied"}`)
+	now := time.Unix(1686355200, 0)
+
+	signature := domain.SignCallback(secret, now.Unix(), body)
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.True(t, domain.VerifyCallback(secret, now.Unix(), body, signature, now, time.Minute))
+	assert.False(t, domain.VerifyCallback([]byte("other"), now.Unix(), body, signature, now, time.Minute))
+	assert.False(t, domain.VerifyCallback(secret, now.Unix(), []byte(`{}`), signature, now, time.Minute))
+	assert.False(t, domain.VerifyCallback(secret, now.Unix(), body, signature, now.Add(2*time.Minute), time.Minute))
+}
+
+func TestNewCallbackEvent(t *testing.T) {
+	from := domain.From{Channel: domain.ChannelREST, ChannelUserID: "crm/u1"}
+	conv := domain.Conversation{MessageID: "m1", Prompt: "hi", Completion: "hello"}
+	now := time.Unix(1686355200, 0)
+
+	e, ok := domain.NewCallbackEvent(domain.NewEventPromptReplied("c1", from, conv).(domain.MetaEvent), now)
+	assert.True(t, ok)
+	assert.Equal(t, domain.CallbackEventReplied, e.Event)
+	assert.Equal(t, "u1", e.UserID)
+	assert.Equal(t, "hello", e.Completion)
+	assert.Equal(t, now.Unix(), e.Timestamp)
+
+	e, ok = domain.NewCallbackEvent(domain.NewConversationInterrupted("c1", from, conv, errors.New("timeout")).(domain.MetaEvent), now)
+	assert.True(t, ok)
+	assert.Equal(t, domain.CallbackEventInterrupted, e.Event)
+	assert.Equal(t, "timeout", e.Error)
+	assert.Empty(t, e.Completion)
+}
+
+func TestCallbackDeliveryRecord(t *testing.T) {
+	ep := &domain.CallbackEndpoint{URL: "https://example.com/hook"}
+	d := domain.NewCallbackDelivery(&domain.CallbackEvent{Event: domain.CallbackEventReplied}, ep, nil)
+
+	d.Record(0, errors.New("connection refused"), 3)
+	assert.Equal(t, domain.CallbackPending, d.Status)
+	d.Record(503, nil, 3)
+	assert.Equal(t, domain.CallbackPending, d.Status)
+	assert.Equal(t, "unexpected status 503", d.Error)
+	d.Record(200, nil, 3)
+	assert.Equal(t, domain.CallbackSucceeded, d.Status)
+	assert.Equal(t, 3, d.Attempts)
+	assert.Empty(t, d.Error)
+
+	d = domain.NewCallbackDelivery(&domain.CallbackEvent{}, ep, nil)
+	d.Record(404, nil, 3)
+	assert.Equal(t, domain.CallbackFailed, d.Status)
+
+	d = domain.NewCallbackDelivery(&domain.CallbackEvent{}, ep, nil)
+	d.Record(429, nil, 2)
+	assert.Equal(t, domain.CallbackPending, d.Status)
+	d.Record(429, nil, 2)
+	assert.Equal(t, domain.CallbackFailed, d.Status)
+}
+
+func TestCallbackBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, domain.CallbackBackoff(1))
+	assert.Equal(t, 4*time.Second, domain.CallbackBackoff(3))
+	assert.Equal(t, time.Minute, domain.CallbackBackoff(20))
+}
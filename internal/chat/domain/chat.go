@@ -14,6 +14,12 @@ type (
 		MessageID  ChannelMessageID `json:"message_id,omitempty"`
 		Prompt     string           `json:"prompt,omitempty"`
 		Completion string           `json:"completion,omitempty"`
+		ToolCalls  []*ToolCall      `json:"tool_calls,omitempty"`
+		Documents  []*Document      `json:"documents,omitempty"`
+		Model      string           `json:"model,omitempty"`
+		// token用量，包含工具调用过程中的多次请求
+		PromptTokens     int `json:"prompt_tokens,omitempty"`
+		CompletionTokens int `json:"completion_tokens,omitempty"`
 	}
 
 	From struct {
@@ -27,7 +33,7 @@ type (
 	// ChannelMessageID 通道内部消息ID
 	ChannelMessageID string
 
-	// ChannelUserID 通道的用户ID
+	// ChannelUserID 通道的用户ID，最长MaxChannelUserIDLength字节
 	ChannelUserID string
 
 	Status int
@@ -41,6 +47,9 @@ type (
 		Current       *Conversation
 		Version       int
 		Counts        int
+		Knowledge     bool   // 是否启用知识库检索
+		Thread        string // 同一用户可同时进行多个会话的通道以此区分，例如Slack的thread及邮件线程，其余通道为空
+		Locale        string // 回复附加内容使用的语言，不持久化，由应用层在请求ChatGPT前设置
 		CreatedAt     time.Time
 	}
 )
@@ -49,8 +58,34 @@ const (
 	ChannelTelegram Channel = iota + 1
 	ChannelWechat
 	ChannelDingtalk
+	ChannelWeb
+	ChannelAPI
+	ChannelREST
+	ChannelSlack
+	ChannelDiscord
+	ChannelFeishu
+	ChannelWecom
+	ChannelMatrix
+	ChannelEmail
+	ChannelCLI
 )
 
+var channelNames = map[Channel]string{
+	ChannelTelegram: "telegram",
+	ChannelWechat:   "wechat",
+	ChannelDingtalk: "dingtalk",
+	ChannelWeb:      "web",
+	ChannelAPI:      "api",
+	ChannelREST:     "rest",
+	ChannelSlack:    "slack",
+	ChannelDiscord:  "discord",
+	ChannelFeishu:   "feishu",
+	ChannelWecom:    "wecom",
+	ChannelMatrix:   "matrix",
+	ChannelEmail:    "email",
+	ChannelCLI:      "cli",
+}
+
 const (
 	StatusReady Status = iota
 	StatusEnded
@@ -59,12 +94,31 @@ const (
 const (
 	MaxConversationCounts = 20
 	ChatExpirationTime    = 12 * time.Hour
+	// MaxChannelUserIDLength 与数据库channel_user_id字段长度一致，Matrix用户ID最长255字节
+	MaxChannelUserIDLength = 255
 )
 
 var (
 	emptyConversation = Conversation{}
 )
 
+func (c Channel) String() string {
+	if name, ok := channelNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("channel(%d)", int(c))
+}
+
+// ParseChannel 将配置中的通道名称转换为枚举值
+func ParseChannel(name string) (Channel, error) {
+	for channel, n := range channelNames {
+		if n == name {
+			return channel, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown channel: %s", name)
+}
+
 func NewConversation(prompt string, msgID ChannelMessageID) *Conversation {
 	return &Conversation{Prompt: prompt, MessageID: msgID}
 }
@@ -73,6 +127,20 @@ func (c *Conversation) Reply(completion string) {
 	c.Completion = completion
 }
 
+func (c *Conversation) AddToolCall(call *ToolCall) {
+	c.ToolCalls = append(c.ToolCalls, call)
+}
+
+func (c *Conversation) Attach(doc *Document) {
+	c.Documents = append(c.Documents, doc)
+}
+
+func (c *Conversation) Consume(model string, promptTokens, completionTokens int) {
+	c.Model = model
+	c.PromptTokens += promptTokens
+	c.CompletionTokens += completionTokens
+}
+
 func (c *Conversation) IsReplied() bool {
 	return c.Completion != ""
 }
@@ -94,6 +162,21 @@ func NewChat(f From) *Chat {
 	return ct
 }
 
+// NewThreadChat 用户在thread中的会话，访问控制、额度及限速仍以f计算
+func NewThreadChat(f From, thread string) *Chat {
+	ct := NewChat(f)
+	ct.Thread = thread
+	return ct
+}
+
+// NewStatelessChat 历史对话由调用方提供的一次性会话，用于无状态的接口调用
+func NewStatelessChat(f From, history []*Conversation) *Chat {
+	ct := NewChat(f)
+	ct.Conversations = append(ct.Conversations, history...)
+	ct.Counts = len(history)
+	return ct
+}
+
 func (ct *Chat) PreviousConversations() []*Conversation {
 	return ct.Conversations[:]
 }
@@ -137,6 +220,23 @@ func (ct *Chat) Reply(a string) (*Conversation, error) {
 	return current, nil
 }
 
+// Reject 拒绝未通过审核的prompt，不会改变会话状态
+func (ct *Chat) Reject(q string, msgID ChannelMessageID, category string) {
+	ct.Event.Add(NewEventConversationBlocked(ct.ID, ct.From, *NewConversation(q, msgID), category))
+}
+
+// Block 拦截未通过审核的completion，当前对话作废
+func (ct *Chat) Block(category string) (*Conversation, error) {
+	if ct.Current == nil {
+		return nil, errors.New("there is no ongoing conversation")
+	}
+	current := ct.Current
+	ct.Current = nil
+	ct.Counts--
+	ct.Event.Add(NewEventConversationBlocked(ct.ID, ct.From, *current, category))
+	return current, ErrContentBlocked
+}
+
 func (ct *Chat) Interrupt(err error) (*Conversation, error) {
 	current := ct.Current
 	ct.Current = nil
@@ -145,6 +245,14 @@ func (ct *Chat) Interrupt(err error) (*Conversation, error) {
 	return current, err
 }
 
+func (ct *Chat) ToggleKnowledge(on bool) error {
+	if ct.Status == StatusEnded {
+		return errors.New("chat has already ended")
+	}
+	ct.Knowledge = on
+	return nil
+}
+
 func (ct *Chat) Shutdown() {
 	if ct.Status != StatusEnded {
 		ct.Status = StatusEnded
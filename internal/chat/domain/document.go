@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Document 用户在prompt中引用的网页内容
+type Document struct {
+	URL     string `json:"url"`
+	Title   string `json:"title,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()（）\[\]{}，。！？、；]+`)
+
+// ExtractURLs 按出现顺序提取prompt中的链接并去重，max<=0时不限制数量
+func ExtractURLs(text string, max int) []string {
+	urls := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		urls = append(urls, u)
+		if max > 0 && len(urls) >= max {
+			break
+		}
+	}
+	return urls
+}
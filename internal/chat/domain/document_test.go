@@ -0,0 +1,15 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractURLs(t *testing.T) {
+	text := "看看这个https://example.com/a?b=1，还有(http://foo.bar/x). 以及 https://example.com/a?b=1"
+	assert.Equal(t, []string{"https://example.com/a?b=1", "http://foo.bar/x"}, domain.ExtractURLs(text, 0))
+	assert.Len(t, domain.ExtractURLs(text, 1), 1)
+	assert.Empty(t, domain.ExtractURLs("no links", 3))
+}
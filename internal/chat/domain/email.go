@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 常见邮件客户端插入的引用标题，例如"On Mon, Jun 5, 2023 at 10:00 AM Alice <a@b.c> wrote:"
+	quoteHeaders = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^on\s.+\swrote:$`),
+		regexp.MustCompile(`^在.+写道[:：]$`),
+		regexp.MustCompile(`(?i)^-{2,}\s*original message\s*-{2,}$`),
+		regexp.MustCompile(`^-{2,}\s*原始邮件\s*-{2,}$`),
+		regexp.MustCompile(`^_{10,}$`),
+	}
+	// 移动端客户端默认的签名
+	mobileSignatures = []string{"Sent from my ", "Get Outlook for ", "发自我的", "从我的"}
+)
+
+// NewEmailFrom 以发件人地址作为访问控制、额度及限速的用户身份
+func NewEmailFrom(sender string) From {
+	return From{Channel: ChannelEmail, ChannelUserID: ChannelUserID(strings.ToLower(sender))}
+}
+
+// NewEmailThread 同一发件人的同一邮件线程对应一个会话：线程的首封邮件为References的第一项，其次为In-Reply-To，
+// 新邮件为自身。会话同时以发件人区分，他人引用同一线程时不能接入该会话
+func NewEmailThread(messageID, inReplyTo string, references []string) string {
+	root := messageID
+	switch {
+	case len(references) > 0:
+		root = references[0]
+	case inReplyTo != "":
+		root = inReplyTo
+	}
+	return emailDigest(root)
+}
+
+// NewEmailMessageID 以邮件Message-ID的摘要作为通道消息ID
+func NewEmailMessageID(messageID string) ChannelMessageID {
+	return ChannelMessageID(emailDigest(messageID))
+}
+
+func emailDigest(id string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(id)))
+	return hex.EncodeToString(sum[:])
+}
+
+// StripQuotedReply 去掉回复邮件中引用的原邮件及签名，仅保留本次新写的内容
+func StripQuotedReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var kept []string
+loop:
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// 签名分隔符为"-- "
+		if line == "-- " || line == "--" {
+			break
+		}
+		for _, prefix := range mobileSignatures {
+			if strings.HasPrefix(trimmed, prefix) {
+				break loop
+			}
+		}
+		for _, re := range quoteHeaders {
+			if re.MatchString(trimmed) {
+				break loop
+			}
+		}
+		// 部分客户端会将过长的引用标题折行
+		if i+1 < len(lines) && quoteHeaders[0].MatchString(trimmed+" "+strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailFrom(t *testing.T) {
+	f := domain.NewEmailFrom("Alice@Example.org")
+	assert.Equal(t, domain.ChannelEmail, f.Channel)
+	assert.Equal(t, f, domain.NewEmailFrom("alice@example.org"))
+	assert.NotEqual(t, f, domain.NewEmailFrom("mallory@example.org"))
+
+	root := domain.NewEmailThread("root@example.org", "", nil)
+	assert.Len(t, root, 40)
+	// 回复邮件与首封邮件属于同一线程
+	assert.Equal(t, root, domain.NewEmailThread("r2@example.org", "r1@example.org", []string{"root@example.org", "r1@example.org"}))
+	assert.Equal(t, root, domain.NewEmailThread("r1@example.org", "root@example.org", nil))
+	assert.NotEqual(t, root, domain.NewEmailThread("other@example.org", "", nil))
+
+	assert.Len(t, domain.NewEmailMessageID("root@example.org"), 40)
+}
+
+func TestStripQuotedReply(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello\nworld\n", "hello\nworld"},
+		{"gmail", "thanks!\n\nOn Mon, Jun 5, 2023 at 10:00 AM Bot <bot@example.org> wrote:\n> previous answer\n", "thanks!"},
+		{"folded header", "ok\n\nOn Mon, Jun 5, 2023 at 10:00 AM Bot\n<bot@example.org> wrote:\n> previous", "ok"},
+		{"chinese", "好的\n\n在 2023年6月5日 10:00，Bot <bot@example.org> 写道：\n> 上一次的回答", "好的"},
+		{"outlook", "next question\n\n________________________________\nFrom: Bot\nSent: Monday\n", "next question"},
+		{"original message", "see below\n-----Original Message-----\nFrom: Bot", "see below"},
+		{"signature", "what about go?\n\n-- \nAlice\nACME Inc.", "what about go?"},
+		{"mobile", "why?\n\nSent from my iPhone", "why?"},
+		{"inline quote", "> first\nanswer one\n> second\nanswer two", "answer one\nanswer two"},
+		{"crlf", "hi\r\n> quoted\r\n", "hi"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, domain.StripQuotedReply(c.text))
+		})
+	}
+}
@@ -8,6 +8,7 @@ type MetaEvent struct {
 	Conversation Conversation
 	kind         mediator.EventKind
 	Error        error
+	Category     string // 内容审核命中的类别
 }
 
 type Event interface {
@@ -19,6 +20,7 @@ const (
 	KindConversationCreated    mediator.EventKind = "event_conversation_created"
 	KindConversationReplied    mediator.EventKind = "event_conversation_replied"
 	KindCoversationInterrupted mediator.EventKind = "event_conversation_interruptted"
+	KindConversationBlocked    mediator.EventKind = "event_conversation_blocked"
 )
 
 func (me MetaEvent) Kind() mediator.EventKind {
@@ -55,3 +57,14 @@ func NewConversationInterrupted(cid string, f From, c Conversation, err error) E
 		Error:        err,
 	}
 }
+
+func NewEventConversationBlocked(cid string, f From, c Conversation, category string) Event {
+	return MetaEvent{
+		ChatID:       cid,
+		From:         f,
+		Conversation: c,
+		kind:         KindConversationBlocked,
+		Error:        ErrContentBlocked,
+		Category:     category,
+	}
+}
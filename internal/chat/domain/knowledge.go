@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type (
+	// Chunk 知识库中的文档片段
+	Chunk struct {
+		ID      int64
+		Source  string // 文档来源，通常为相对路径
+		Heading string // 片段所属的标题
+		Content string
+		Vector  []float32
+	}
+
+	// ScoredChunk 检索命中的片段及其相似度
+	ScoredChunk struct {
+		*Chunk
+		Score float64
+	}
+)
+
+const (
+	DefaultChunkSize = 800
+	DefaultTopK      = 3
+)
+
+// Citation 引用来源的展示文本
+func (c *Chunk) Citation() string {
+	if c.Heading == "" {
+		return c.Source
+	}
+	return fmt.Sprintf("%s#%s", c.Source, c.Heading)
+}
+
+// SplitMarkdown 按标题切分markdown文档，超过size(按字符计)的段落继续按空行切分
+func SplitMarkdown(source, text string, size int) []*Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	chunks := make([]*Chunk, 0)
+	var heading string
+	var buf strings.Builder
+
+	flush := func() {
+		content := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if content == "" {
+			return
+		}
+		for _, piece := range splitBySize(content, size) {
+			chunks = append(chunks, &Chunk{Source: source, Heading: heading, Content: piece})
+		}
+	}
+
+	inFence := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			title := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			if title != "" {
+				flush()
+				heading = title
+				continue
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+	return chunks
+}
+
+func splitBySize(content string, size int) []string {
+	if len([]rune(content)) <= size {
+		return []string{content}
+	}
+	pieces := make([]string, 0)
+	var current []rune
+	for _, para := range strings.Split(content, "\n\n") {
+		p := []rune(strings.TrimSpace(para))
+		if len(p) == 0 {
+			continue
+		}
+		if len(current) > 0 && len(current)+len(p)+2 > size {
+			pieces = append(pieces, string(current))
+			current = nil
+		}
+		for len(p) > size { // 单个段落过长，强制截断
+			pieces = append(pieces, string(p[:size]))
+			p = p[size:]
+		}
+		if len(current) > 0 {
+			current = append(current, '\n', '\n')
+		}
+		current = append(current, p...)
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, string(current))
+	}
+	return pieces
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，维度不一致时返回0
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,29 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMarkdown(t *testing.T) {
+	doc := "intro\n\n# Deploy\nrun make\n\n```sh\n# not a heading\n```\n## Rollback\nrevert it\n"
+	chunks := domain.SplitMarkdown("ops/deploy.md", doc, 0)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, "", chunks[0].Heading)
+	assert.Equal(t, "Deploy", chunks[1].Heading)
+	assert.Contains(t, chunks[1].Content, "# not a heading")
+	assert.Equal(t, "ops/deploy.md#Rollback", chunks[2].Citation())
+
+	long := strings.Repeat("a", 30) + "\n\n" + strings.Repeat("b", 30)
+	chunks = domain.SplitMarkdown("long.md", long, 40)
+	assert.Len(t, chunks, 2)
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1.0, domain.CosineSimilarity([]float32{1, 2}, []float32{2, 4}), 1e-6)
+	assert.InDelta(t, 0.0, domain.CosineSimilarity([]float32{1, 0}, []float32{0, 1}), 1e-6)
+	assert.Equal(t, 0.0, domain.CosineSimilarity([]float32{1}, []float32{1, 2}))
+}
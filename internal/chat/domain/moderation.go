@@ -0,0 +1,19 @@
+package domain
+
+import "errors"
+
+// ModerationResult 内容审核结果，Category为命中的类别
+type ModerationResult struct {
+	Flagged  bool
+	Category string
+}
+
+var ErrContentBlocked = errors.New("content blocked by moderation")
+
+func Passed() *ModerationResult {
+	return &ModerationResult{}
+}
+
+func Flagged(category string) *ModerationResult {
+	return &ModerationResult{Flagged: true, Category: category}
+}
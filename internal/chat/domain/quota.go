@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type (
+	Period int
+
+	// Usage token用量
+	Usage struct {
+		PromptTokens     int64
+		CompletionTokens int64
+	}
+
+	// QuotaLimit token额度上限，0表示不限制
+	QuotaLimit struct {
+		Daily   int64
+		Monthly int64
+	}
+
+	// UserQuota 用户的额度设置，Limit为空时使用通道默认额度
+	UserQuota struct {
+		From    From
+		Limit   *QuotaLimit
+		ResetAt time.Time
+	}
+
+	// QuotaPolicy 各通道单用户及通道整体的默认额度
+	QuotaPolicy struct {
+		users    map[Channel]QuotaLimit
+		channels map[Channel]QuotaLimit
+	}
+)
+
+const (
+	PeriodDaily Period = iota + 1
+	PeriodMonthly
+)
+
+var ErrQuotaExceeded = errors.New("额度已用完，请稍后再试或联系管理员")
+
+func (u Usage) Total() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (ql QuotaLimit) Of(p Period) int64 {
+	if p == PeriodDaily {
+		return ql.Daily
+	}
+	return ql.Monthly
+}
+
+// PeriodStart 统计周期的起始时间
+func PeriodStart(p Period, now time.Time) time.Time {
+	y, m, d := now.Date()
+	if p == PeriodDaily {
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+}
+
+func NewUserQuota(f From) *UserQuota {
+	return &UserQuota{From: f}
+}
+
+// Since 用量的统计起点，重置额度后从重置时间开始计算
+func (uq *UserQuota) Since(p Period, now time.Time) time.Time {
+	start := PeriodStart(p, now)
+	if uq.ResetAt.After(start) {
+		return uq.ResetAt
+	}
+	return start
+}
+
+func (uq *UserQuota) Reset() {
+	uq.ResetAt = time.Now()
+}
+
+func (uq *UserQuota) Raise(limit QuotaLimit) {
+	uq.Limit = &limit
+}
+
+func NewQuotaPolicy() *QuotaPolicy {
+	return &QuotaPolicy{users: make(map[Channel]QuotaLimit), channels: make(map[Channel]QuotaLimit)}
+}
+
+func (qp *QuotaPolicy) SetUserLimit(ch Channel, limit QuotaLimit) {
+	qp.users[ch] = limit
+}
+
+func (qp *QuotaPolicy) SetChannelLimit(ch Channel, limit QuotaLimit) {
+	qp.channels[ch] = limit
+}
+
+func (qp *QuotaPolicy) UserLimit(uq *UserQuota) QuotaLimit {
+	if uq.Limit != nil {
+		return *uq.Limit
+	}
+	return qp.users[uq.From.Channel]
+}
+
+func (qp *QuotaPolicy) ChannelLimit(ch Channel) QuotaLimit {
+	return qp.channels[ch]
+}
+
+// Exceeded 判断用量是否已达到上限
+func Exceeded(used Usage, limit int64) bool {
+	return limit > 0 && used.Total() >= limit
+}
@@ -0,0 +1,29 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserQuota(t *testing.T) {
+	now := time.Date(2023, 4, 15, 13, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2023, 4, 15, 0, 0, 0, 0, time.UTC), domain.PeriodStart(domain.PeriodDaily, now))
+	assert.Equal(t, time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), domain.PeriodStart(domain.PeriodMonthly, now))
+
+	uq := domain.NewUserQuota(domain.From{Channel: domain.ChannelTelegram, ChannelUserID: "alice"})
+	uq.ResetAt = time.Date(2023, 4, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, domain.PeriodStart(domain.PeriodDaily, now), uq.Since(domain.PeriodDaily, now))
+	assert.Equal(t, uq.ResetAt, uq.Since(domain.PeriodMonthly, now))
+
+	policy := domain.NewQuotaPolicy()
+	policy.SetUserLimit(domain.ChannelTelegram, domain.QuotaLimit{Daily: 100})
+	assert.Equal(t, int64(100), policy.UserLimit(uq).Daily)
+	uq.Raise(domain.QuotaLimit{Daily: 500})
+	assert.Equal(t, int64(500), policy.UserLimit(uq).Daily)
+
+	assert.True(t, domain.Exceeded(domain.Usage{PromptTokens: 60, CompletionTokens: 40}, 100))
+	assert.False(t, domain.Exceeded(domain.Usage{PromptTokens: 60, CompletionTokens: 40}, 0))
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type (
+	// RateLimit 令牌桶容量为Burst，每隔Refill恢复一个令牌，Burst为0表示不限速
+	RateLimit struct {
+		Burst  int
+		Refill time.Duration
+	}
+
+	TokenBucket struct {
+		Tokens    float64
+		UpdatedAt time.Time
+	}
+
+	// RateLimitPolicy 各通道的限速配置
+	RateLimitPolicy struct {
+		limits map[Channel]RateLimit
+	}
+
+	RateLimitedError struct {
+		RetryAfter time.Duration
+	}
+)
+
+func (rl RateLimit) Enabled() bool {
+	return rl.Burst > 0 && rl.Refill > 0
+}
+
+// Take 按经过的时间补充令牌后尝试取出一个，令牌不足时返回距离下一个令牌的时长
+func (tb *TokenBucket) Take(limit RateLimit, now time.Time) time.Duration {
+	if tb.UpdatedAt.IsZero() {
+		tb.Tokens = float64(limit.Burst)
+	} else if elapsed := now.Sub(tb.UpdatedAt); elapsed > 0 {
+		tb.Tokens = math.Min(float64(limit.Burst), tb.Tokens+float64(elapsed)/float64(limit.Refill))
+	}
+	tb.UpdatedAt = now
+
+	if tb.Tokens >= 1 {
+		tb.Tokens--
+		return 0
+	}
+	return time.Duration((1 - tb.Tokens) * float64(limit.Refill))
+}
+
+// FullAt 令牌补满的时间，此后的令牌桶与新建的等价
+func (tb *TokenBucket) FullAt(limit RateLimit) time.Time {
+	return tb.UpdatedAt.Add(time.Duration((float64(limit.Burst) - tb.Tokens) * float64(limit.Refill)))
+}
+
+func NewRateLimitPolicy() *RateLimitPolicy {
+	return &RateLimitPolicy{limits: make(map[Channel]RateLimit)}
+}
+
+func (rp *RateLimitPolicy) SetLimit(ch Channel, limit RateLimit) {
+	rp.limits[ch] = limit
+}
+
+func (rp *RateLimitPolicy) Limit(ch Channel) RateLimit {
+	return rp.limits[ch]
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("发送太快了，请在 %d 秒后重试", int(math.Ceil(e.RetryAfter.Seconds())))
+}
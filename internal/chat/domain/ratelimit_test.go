@@ -0,0 +1,35 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limit := domain.RateLimit{Burst: 2, Refill: 10 * time.Second}
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	bucket := new(domain.TokenBucket)
+
+	assert.Zero(t, bucket.Take(limit, now))
+	assert.Zero(t, bucket.Take(limit, now))
+	assert.Equal(t, 10*time.Second, bucket.Take(limit, now))
+
+	now = now.Add(4 * time.Second)
+	assert.Equal(t, 6*time.Second, bucket.Take(limit, now))
+
+	now = now.Add(6 * time.Second)
+	assert.Zero(t, bucket.Take(limit, now))
+
+	// 长时间空闲后令牌数不超过Burst
+	now = now.Add(time.Hour)
+	assert.Zero(t, bucket.Take(limit, now))
+	assert.Zero(t, bucket.Take(limit, now))
+	assert.NotZero(t, bucket.Take(limit, now))
+	assert.Equal(t, now.Add(20*time.Second), bucket.FullAt(limit))
+
+	err := &domain.RateLimitedError{RetryAfter: 2500 * time.Millisecond}
+	assert.Equal(t, "发送太快了，请在 3 秒后重试", err.Error())
+}
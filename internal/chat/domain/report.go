@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// Price 模型每1K tokens的价格
+	Price struct {
+		Prompt     float64
+		Completion float64
+	}
+
+	// PriceTable 按模型名称匹配价格，未精确命中时使用最长的前缀匹配，例如gpt-3.5-turbo-0613匹配gpt-3.5-turbo
+	PriceTable map[string]Price
+
+	ReportDimension string
+
+	// UsageRecord 按维度及模型聚合的用量
+	UsageRecord struct {
+		Channel       Channel
+		ChannelUserID ChannelUserID
+		Model         string
+		Conversations int64
+		Usage         Usage
+	}
+
+	ReportPeriod struct {
+		From time.Time
+		To   time.Time
+	}
+)
+
+const (
+	DimensionUser    ReportDimension = "user"
+	DimensionChannel ReportDimension = "channel"
+	DimensionModel   ReportDimension = "model"
+)
+
+func ParseReportDimension(s string) (ReportDimension, error) {
+	switch d := ReportDimension(s); d {
+	case DimensionUser, DimensionChannel, DimensionModel:
+		return d, nil
+	case "":
+		return DimensionUser, nil
+	default:
+		return "", fmt.Errorf("unsupported dimension: %s", s)
+	}
+}
+
+func (pt PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := pt[model]; ok {
+		return price, true
+	}
+	var matched string
+	for name := range pt {
+		if strings.HasPrefix(model, name) && len(name) > len(matched) {
+			matched = name
+		}
+	}
+	if matched == "" {
+		return Price{}, false
+	}
+	return pt[matched], true
+}
+
+// Cost 计算用量的费用，未配置价格的模型费用为0
+func (pt PriceTable) Cost(model string, u Usage) float64 {
+	price, ok := pt.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1000
+}
+
+// Key 记录在指定维度下的分组键
+func (ur *UsageRecord) Key(d ReportDimension) string {
+	switch d {
+	case DimensionChannel:
+		return ur.Channel.String()
+	case DimensionModel:
+		return ur.Model
+	default:
+		return fmt.Sprintf("%s:%s", ur.Channel.String(), ur.ChannelUserID)
+	}
+}
+
+func NewReportPeriod(from, to time.Time) (ReportPeriod, error) {
+	if !from.Before(to) {
+		return ReportPeriod{}, fmt.Errorf("invalid period: %s - %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return ReportPeriod{From: from, To: to}, nil
+}
+
+// ParseReportPeriod 解析YYYY-MM-DD或RFC3339格式的起止时间，缺省为本月初至今，仅有日期的to包含当天
+func ParseReportPeriod(from, to string, now time.Time) (ReportPeriod, error) {
+	start := PeriodStart(PeriodMonthly, now)
+	end := now
+	if from != "" {
+		t, _, err := ParseTime(from)
+		if err != nil {
+			return ReportPeriod{}, err
+		}
+		start = t
+	}
+	if to != "" {
+		t, dateOnly, err := ParseTime(to)
+		if err != nil {
+			return ReportPeriod{}, err
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		end = t
+	}
+	return NewReportPeriod(start, end)
+}
+
+// ParseTime 解析YYYY-MM-DD或RFC3339格式的时间，第二个返回值表示是否仅包含日期
+func ParseTime(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC3339", s)
+	}
+	return t, false, nil
+}
@@ -0,0 +1,50 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceTable(t *testing.T) {
+	prices := domain.PriceTable{
+		"gpt-3.5-turbo":     {Prompt: 0.0015, Completion: 0.002},
+		"gpt-3.5-turbo-16k": {Prompt: 0.003, Completion: 0.004},
+	}
+	price, ok := prices.Lookup("gpt-3.5-turbo-16k-0613")
+	assert.True(t, ok)
+	assert.Equal(t, 0.003, price.Prompt)
+	price, ok = prices.Lookup("gpt-3.5-turbo-0613")
+	assert.True(t, ok)
+	assert.Equal(t, 0.0015, price.Prompt)
+	_, ok = prices.Lookup("gpt-4")
+	assert.False(t, ok)
+
+	assert.InDelta(t, 0.0035, prices.Cost("gpt-3.5-turbo", domain.Usage{PromptTokens: 1000, CompletionTokens: 1000}), 1e-9)
+	assert.Equal(t, float64(0), prices.Cost("gpt-4", domain.Usage{PromptTokens: 1000}))
+}
+
+func TestParseReportPeriod(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+	period, err := domain.ParseReportPeriod("", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local), period.From)
+	assert.Equal(t, now, period.To)
+
+	period, err = domain.ParseReportPeriod("2023-05-01", "2023-05-31", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local), period.To)
+
+	_, err = domain.ParseReportPeriod("2023-05-31", "2023-05-01", now)
+	assert.Error(t, err)
+	_, err = domain.ParseReportPeriod("yesterday", "", now)
+	assert.Error(t, err)
+
+	d, err := domain.ParseReportDimension("")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.DimensionUser, d)
+	_, err = domain.ParseReportDimension("day")
+	assert.Error(t, err)
+}
@@ -1,15 +1,115 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type (
 	Repository interface {
 		Get(context.Context, From) (*Chat, error)
+		GetThread(context.Context, From, string) (*Chat, error)
 		Save(context.Context, *Chat) error
 		GetByChatID(context.Context, string) (*Chat, error)
+		SearchConversations(context.Context, From, string, int) ([]*Conversation, error)
 	}
 
 	ChatGTPService interface {
 		Chat(context.Context, *Chat) (*Conversation, error)
 	}
+
+	KnowledgeRepository interface {
+		// Replace 以chunks替换来源的全部片段，失败时保留原有片段
+		Replace(context.Context, string, ...*Chunk) error
+		Search(context.Context, []float32, int) ([]*ScoredChunk, error)
+	}
+
+	EmbeddingService interface {
+		Embed(context.Context, ...string) ([][]float32, error)
+	}
+
+	WebReader interface {
+		Read(context.Context, string) (*Document, error)
+	}
+
+	Moderator interface {
+		Moderate(context.Context, string) (*ModerationResult, error)
+	}
+
+	// SyncTokenRepository 保存长轮询通道的同步位置，重启后从该位置继续，key为通道及账号
+	SyncTokenRepository interface {
+		Get(ctx context.Context, key string) (string, error)
+		Save(ctx context.Context, key, token string) error
+	}
+
+	// LocaleRepository 保存用户通过/lang选择的语言，未设置时返回空字符串
+	LocaleRepository interface {
+		Get(context.Context, From) (string, error)
+		Save(context.Context, From, string) error
+	}
+
+	// Transcriber 语音转文字，format为音频格式，例如amr、mp3
+	Transcriber interface {
+		Transcribe(ctx context.Context, format string, audio []byte) (string, error)
+	}
+
+	AccessRepository interface {
+		GetRule(context.Context, From) (AccessRule, error)
+		SaveRule(context.Context, From, AccessRule) error
+		GetInvitation(context.Context, string) (*Invitation, error)
+		SaveInvitation(context.Context, *Invitation) error
+		// RedeemInvitation 记录邀请码的使用者并授予其访问权限，两者同时成功或失败
+		RedeemInvitation(context.Context, *Invitation) error
+	}
+
+	QuotaRepository interface {
+		GetUserQuota(context.Context, From) (*UserQuota, error)
+		SaveUserQuota(context.Context, *UserQuota) error
+		SumUserUsage(context.Context, From, time.Time) (Usage, error)
+		SumChannelUsage(context.Context, Channel, time.Time) (Usage, error)
+		// AddDiscardedUsage 记录被中断或拦截的对话已消耗的token，这类对话不会保存为conversation
+		AddDiscardedUsage(context.Context, string, From, Conversation) error
+	}
+
+	ReportRepository interface {
+		AggregateUsage(context.Context, ReportPeriod, ReportDimension) ([]*UsageRecord, error)
+	}
+
+	AdminRepository interface {
+		Stats(context.Context, time.Time) (*Stats, error)
+		ListUsers(context.Context, UserFilter) ([]From, error)
+		ListChats(context.Context, ChatFilter, Pagination) ([]*ChatSummary, int64, error)
+		DeleteChat(context.Context, string) error
+		ListUserUsage(context.Context, Channel, Pagination) ([]*UserUsage, int64, error)
+	}
+
+	BroadcastRepository interface {
+		Create(context.Context, *Broadcast, []*Delivery) error
+		Get(context.Context, string) (*Broadcast, error)
+		ListUnfinished(context.Context) ([]*Broadcast, error)
+		// ListPendingDeliveries 待投递及认领租约已过期的投递
+		ListPendingDeliveries(context.Context, string, int) ([]*Delivery, error)
+		// ClaimDelivery 在租约到期前独占投递，已被其他实例认领时返回false
+		ClaimDelivery(context.Context, *Delivery, string, time.Time) (bool, error)
+		// SaveDelivery 保存投递结果并同步更新任务进度
+		SaveDelivery(context.Context, *Broadcast, *Delivery) error
+		// Finish 没有未完成的投递时结束任务，仍有其他实例在投递时返回false
+		Finish(context.Context, *Broadcast) (bool, error)
+	}
+
+	// Notifier 主动向用户推送消息
+	Notifier interface {
+		Notify(context.Context, From, string) error
+	}
+
+	// ChannelNotifier 按用户所属通道分发，Channels为支持主动推送的通道
+	ChannelNotifier interface {
+		Notifier
+		Channels() []Channel
+	}
+
+	// RateLimiter 从用户的令牌桶中取出一个令牌，返回0表示放行，否则返回需要等待的时长
+	RateLimiter interface {
+		Take(context.Context, From, RateLimit) (time.Duration, error)
+	}
 )
@@ -0,0 +1,24 @@
+package domain
+
+import "strings"
+
+// NewSlackFrom Slack的用户ID仅在workspace内唯一，以team:user作为访问控制、额度及限速的用户身份
+func NewSlackFrom(team, user string) From {
+	return From{Channel: ChannelSlack, ChannelUserID: ChannelUserID(team + ":" + user)}
+}
+
+// NewSlackMessageID 回复位置编码在消息ID中：prompt所在的channel及thread，thread为空时直接回复到channel
+func NewSlackMessageID(channel, threadTS string) ChannelMessageID {
+	return ChannelMessageID(channel + ":" + threadTS)
+}
+
+// SlackThread 每个thread对应一个会话，私聊中未使用thread时整个对话窗口对应一个会话
+func SlackThread(id ChannelMessageID) string {
+	return string(id)
+}
+
+// ParseSlackMessageID 还原回复消息所需的channel及thread_ts
+func ParseSlackMessageID(id ChannelMessageID) (channel, threadTS string) {
+	channel, threadTS, _ = strings.Cut(string(id), ":")
+	return channel, threadTS
+}
@@ -0,0 +1,25 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlackFrom(t *testing.T) {
+	f := domain.NewSlackFrom("T061EG9R6", "U0G9QF9C6")
+	assert.Equal(t, domain.ChannelSlack, f.Channel)
+	assert.Equal(t, domain.ChannelUserID("T061EG9R6:U0G9QF9C6"), f.ChannelUserID)
+	assert.NotEqual(t, f, domain.NewSlackFrom("T0AAAAAAA", "U0G9QF9C6"))
+}
+
+func TestSlackMessageID(t *testing.T) {
+	channel, thread := domain.ParseSlackMessageID(domain.NewSlackMessageID("C024BE91L", "1355517523.000005"))
+	assert.Equal(t, "C024BE91L", channel)
+	assert.Equal(t, "1355517523.000005", thread)
+
+	channel, thread = domain.ParseSlackMessageID(domain.NewSlackMessageID("D024BE91L", ""))
+	assert.Equal(t, "D024BE91L", channel)
+	assert.Empty(t, thread)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "strings"
+
+// SplitText 按字符数拆分超过通道长度限制的消息，尽量在换行处断开
+func SplitText(text string, limit int) []string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return []string{text}
+	}
+	var parts []string
+	for len(runes) > limit {
+		cut := limit
+		if i := strings.LastIndex(string(runes[:limit]), "\n"); i > 0 {
+			cut = len([]rune(string(runes[:limit])[:i])) + 1
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
@@ -0,0 +1,19 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	assert.Equal(t, []string{"hello"}, domain.SplitText("hello", 10))
+	assert.Equal(t, []string{"你好世界", "你好"}, domain.SplitText("你好世界你好", 4))
+	assert.Equal(t, []string{"ab\n", "cdef"}, domain.SplitText("ab\ncdef", 5))
+
+	parts := domain.SplitText(strings.Repeat("a", 4500), 2000)
+	assert.Len(t, parts, 3)
+	assert.Equal(t, strings.Repeat("a", 4500), strings.Join(parts, ""))
+}
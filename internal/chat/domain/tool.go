@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type (
+	// Tool 可由模型调用的工具，Parameters为JSON Schema描述的入参
+	Tool interface {
+		Name() string
+		Description() string
+		Parameters() json.RawMessage
+		Call(context.Context, *Chat, string) (string, error)
+	}
+
+	// ToolCall 一次工具调用及其结果
+	ToolCall struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments,omitempty"`
+		Result    string `json:"result,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	ToolRegistry struct {
+		mu    sync.RWMutex
+		tools map[string]Tool
+		names []string
+	}
+)
+
+const DefaultMaxToolIterations = 5
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+func (tr *ToolRegistry) Register(tool Tool) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if _, ok := tr.tools[tool.Name()]; ok {
+		return fmt.Errorf("tool %s has already been registered", tool.Name())
+	}
+	tr.tools[tool.Name()] = tool
+	tr.names = append(tr.names, tool.Name())
+	return nil
+}
+
+func (tr *ToolRegistry) Get(name string) (Tool, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	tool, ok := tr.tools[name]
+	return tool, ok
+}
+
+// Tools 按注册顺序返回全部工具
+func (tr *ToolRegistry) Tools() []Tool {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	tools := make([]Tool, len(tr.names))
+	for index, name := range tr.names {
+		tools[index] = tr.tools[name]
+	}
+	return tools
+}
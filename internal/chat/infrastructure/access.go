@@ -0,0 +1,104 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	accessRepository struct {
+		db *sqlx.DB
+	}
+
+	Invitation struct {
+		Code            string         `db:"code"`
+		RedeemedChannel sql.NullInt32  `db:"redeemed_channel"`
+		RedeemedUserID  sql.NullString `db:"redeemed_user_id"`
+		RedeemedAt      sql.NullTime   `db:"redeemed_at"`
+		CTime           sql.NullTime   `db:"ctime"`
+	}
+)
+
+var _ domain.AccessRepository = (*accessRepository)(nil)
+
+func NewAccessRepository(db *sqlx.DB) domain.AccessRepository {
+	return &accessRepository{db: db}
+}
+
+func (repo *accessRepository) GetRule(ctx context.Context, f domain.From) (domain.AccessRule, error) {
+	var rule int
+	err := repo.db.GetContext(ctx, &rule, "SELECT rule FROM access_rule WHERE channel=? AND channel_user_id=?", f.Channel, f.ChannelUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.AccessUndefined, nil
+	}
+	if err != nil {
+		return domain.AccessUndefined, err
+	}
+	return domain.AccessRule(rule), nil
+}
+
+func (repo *accessRepository) SaveRule(ctx context.Context, f domain.From, rule domain.AccessRule) error {
+	return saveAccessRule(ctx, repo.db, f, rule)
+}
+
+func saveAccessRule(ctx context.Context, db sqlx.ExecerContext, f domain.From, rule domain.AccessRule) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO access_rule (channel, channel_user_id, rule) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE rule=VALUES(rule)",
+		f.Channel, f.ChannelUserID, rule)
+	return err
+}
+
+func (repo *accessRepository) GetInvitation(ctx context.Context, code string) (*domain.Invitation, error) {
+	do := new(Invitation)
+	err := repo.db.GetContext(ctx, do,
+		"SELECT code, redeemed_channel, redeemed_user_id, redeemed_at, ctime FROM invitation WHERE code=?", code)
+	if err != nil {
+		return nil, err
+	}
+	inv := &domain.Invitation{Code: do.Code, CreatedAt: do.CTime.Time}
+	if do.RedeemedAt.Valid {
+		inv.RedeemedBy = &domain.From{
+			Channel:       domain.Channel(do.RedeemedChannel.Int32),
+			ChannelUserID: domain.ChannelUserID(do.RedeemedUserID.String),
+		}
+		inv.RedeemedAt = do.RedeemedAt.Time
+	}
+	return inv, nil
+}
+
+// SaveInvitation 新建邀请码
+func (repo *accessRepository) SaveInvitation(ctx context.Context, inv *domain.Invitation) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO invitation (code) VALUES (?)", inv.Code)
+	return err
+}
+
+// RedeemInvitation 在邀请码未被使用的前提下记录使用者，并在同一事务中授予访问权限
+func (repo *accessRepository) RedeemInvitation(ctx context.Context, inv *domain.Invitation) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ret, err := tx.ExecContext(ctx,
+		"UPDATE invitation SET redeemed_channel=?, redeemed_user_id=?, redeemed_at=? WHERE code=? AND redeemed_at IS NULL",
+		inv.RedeemedBy.Channel, inv.RedeemedBy.ChannelUserID, inv.RedeemedAt, inv.Code)
+	if err != nil {
+		return err
+	}
+	rows, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrInvitationRedeemed
+	}
+	if err = saveAccessRule(ctx, tx, *inv.RedeemedBy, domain.AccessAllowed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
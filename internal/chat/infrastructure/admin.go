@@ -0,0 +1,223 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	adminRepository struct {
+		db *sqlx.DB
+	}
+
+	chatStats struct {
+		Users       int64 `db:"users"`
+		ActiveChats int64 `db:"active_chats"`
+	}
+
+	conversationStats struct {
+		Conversations    int64 `db:"conversations"`
+		PromptTokens     int64 `db:"prompt_tokens"`
+		CompletionTokens int64 `db:"completion_tokens"`
+	}
+
+	User struct {
+		Channel       int    `db:"channel"`
+		ChannelUserID string `db:"channel_user_id"`
+	}
+
+	ChatSummary struct {
+		ID            string    `db:"id"`
+		Channel       int       `db:"channel"`
+		ChannelUserID string    `db:"channel_user_id"`
+		Deleted       int       `db:"deleted"`
+		Counts        int       `db:"counts"`
+		Pending       bool      `db:"pending"`
+		CTime         time.Time `db:"ctime"`
+		MTime         time.Time `db:"mtime"`
+	}
+
+	UserUsage struct {
+		Channel          int       `db:"channel"`
+		ChannelUserID    string    `db:"channel_user_id"`
+		Chats            int64     `db:"chats"`
+		Conversations    int64     `db:"conversations"`
+		PromptTokens     int64     `db:"prompt_tokens"`
+		CompletionTokens int64     `db:"completion_tokens"`
+		LastActiveAt     time.Time `db:"last_active_at"`
+	}
+)
+
+// pendingCondition 当前对话不为空表示prompt正在等待回复
+const pendingCondition = "(current<>'' AND current<>'{}')"
+
+var _ domain.AdminRepository = (*adminRepository)(nil)
+
+func NewAdminRepository(db *sqlx.DB) domain.AdminRepository {
+	return &adminRepository{db: db}
+}
+
+func (repo *adminRepository) Stats(ctx context.Context, since time.Time) (*domain.Stats, error) {
+	cs := new(chatStats)
+	err := repo.db.GetContext(ctx, cs,
+		"SELECT COUNT(DISTINCT channel, channel_user_id) AS users, COALESCE(SUM(deleted=0), 0) AS active_chats FROM chat")
+	if err != nil {
+		return nil, err
+	}
+	vs := new(conversationStats)
+	err = repo.db.GetContext(ctx, vs,
+		"SELECT COALESCE(SUM(u.replied), 0) AS conversations, COALESCE(SUM(u.prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(u.completion_tokens), 0) AS completion_tokens FROM ("+
+			"SELECT 1 AS replied, prompt_tokens, completion_tokens FROM conversation WHERE ctime>=? UNION ALL "+
+			"SELECT 0 AS replied, prompt_tokens, completion_tokens FROM discarded_usage WHERE ctime>=?) AS u", since, since)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.Stats{
+		Users:         cs.Users,
+		ActiveChats:   cs.ActiveChats,
+		Conversations: vs.Conversations,
+		Usage:         domain.Usage{PromptTokens: vs.PromptTokens, CompletionTokens: vs.CompletionTokens},
+	}, nil
+}
+
+func (repo *adminRepository) ListUsers(ctx context.Context, filter domain.UserFilter) ([]domain.From, error) {
+	var data []*User
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT channel, channel_user_id FROM chat WHERE (?=0 OR channel=?) GROUP BY channel, channel_user_id "+
+			"HAVING MAX(mtime)>=? ORDER BY channel, channel_user_id",
+		filter.Channel, filter.Channel, filter.ActiveSince)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]domain.From, len(data))
+	for index, do := range data {
+		users[index] = domain.From{Channel: domain.Channel(do.Channel), ChannelUserID: domain.ChannelUserID(do.ChannelUserID)}
+	}
+	return users, nil
+}
+
+func (repo *adminRepository) ListChats(ctx context.Context, filter domain.ChatFilter, page domain.Pagination) ([]*domain.ChatSummary, int64, error) {
+	conds := []string{"1=1"}
+	var args []interface{}
+	if filter.Channel != 0 {
+		conds = append(conds, "channel=?")
+		args = append(args, filter.Channel)
+	}
+	if filter.ChannelUserID != "" {
+		conds = append(conds, "channel_user_id=?")
+		args = append(args, filter.ChannelUserID)
+	}
+	if filter.Status != nil {
+		conds = append(conds, "deleted=?")
+		args = append(args, int(*filter.Status))
+	}
+	if filter.Pending {
+		conds = append(conds, pendingCondition)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "ctime>=?")
+		args = append(args, filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "ctime<?")
+		args = append(args, filter.CreatedTo)
+	}
+	where := strings.Join(conds, " AND ")
+
+	var total int64
+	if err := repo.db.GetContext(ctx, &total, "SELECT COUNT(id) FROM chat WHERE "+where, args...); err != nil {
+		return nil, 0, err
+	}
+	var data []*ChatSummary
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT id, channel, channel_user_id, deleted, counts, "+pendingCondition+" AS pending, ctime, mtime FROM chat WHERE "+where+" ORDER BY ctime DESC LIMIT ? OFFSET ?",
+		append(args, page.PageSize, page.Offset())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	chats := make([]*domain.ChatSummary, len(data))
+	for index, do := range data {
+		chats[index] = &domain.ChatSummary{
+			ID:        do.ID,
+			From:      domain.From{Channel: domain.Channel(do.Channel), ChannelUserID: domain.ChannelUserID(do.ChannelUserID)},
+			Status:    domain.Status(do.Deleted),
+			Counts:    do.Counts,
+			Pending:   do.Pending,
+			CreatedAt: do.CTime,
+			UpdatedAt: do.MTime,
+		}
+	}
+	return chats, total, nil
+}
+
+// DeleteChat 物理删除会话及其全部对话，对话的token用量转入discarded_usage，额度及成本统计不受影响
+func (repo *adminRepository) DeleteChat(ctx context.Context, cid string) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO discarded_usage (chat_id, channel, channel_user_id, model, prompt_tokens, completion_tokens, ctime) "+
+			"SELECT c1.id, c1.channel, c1.channel_user_id, c2.model, c2.prompt_tokens, c2.completion_tokens, c2.ctime "+
+			"FROM conversation AS c2 JOIN chat AS c1 ON c1.id=c2.chat_id "+
+			"WHERE c2.chat_id=? AND c2.prompt_tokens+c2.completion_tokens>0", cid)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM conversation WHERE chat_id=?", cid); err != nil {
+		return err
+	}
+	ret, err := tx.ExecContext(ctx, "DELETE FROM chat WHERE id=?", cid)
+	if err != nil {
+		return err
+	}
+	rows, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func (repo *adminRepository) ListUserUsage(ctx context.Context, ch domain.Channel, page domain.Pagination) ([]*domain.UserUsage, int64, error) {
+	var total int64
+	err := repo.db.GetContext(ctx, &total,
+		"SELECT COUNT(DISTINCT channel, channel_user_id) FROM chat WHERE (?=0 OR channel=?)", ch, ch)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var data []*UserUsage
+	err = repo.db.SelectContext(ctx, &data,
+		"SELECT c1.channel, c1.channel_user_id, COUNT(DISTINCT c1.id) AS chats, COUNT(c2.id) AS conversations, "+
+			"COALESCE(SUM(c2.prompt_tokens), 0)+COALESCE(MAX(d.prompt_tokens), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(c2.completion_tokens), 0)+COALESCE(MAX(d.completion_tokens), 0) AS completion_tokens, "+
+			"MAX(c1.mtime) AS last_active_at FROM chat AS c1 LEFT JOIN conversation AS c2 ON c1.id=c2.chat_id "+
+			"LEFT JOIN (SELECT channel, channel_user_id, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens "+
+			"FROM discarded_usage GROUP BY channel, channel_user_id) AS d ON d.channel=c1.channel AND d.channel_user_id=c1.channel_user_id "+
+			"WHERE (?=0 OR c1.channel=?) GROUP BY c1.channel, c1.channel_user_id ORDER BY last_active_at DESC LIMIT ? OFFSET ?",
+		ch, ch, page.PageSize, page.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	users := make([]*domain.UserUsage, len(data))
+	for index, do := range data {
+		users[index] = &domain.UserUsage{
+			From:          domain.From{Channel: domain.Channel(do.Channel), ChannelUserID: domain.ChannelUserID(do.ChannelUserID)},
+			Chats:         do.Chats,
+			Conversations: do.Conversations,
+			Usage:         domain.Usage{PromptTokens: do.PromptTokens, CompletionTokens: do.CompletionTokens},
+			LastActiveAt:  do.LastActiveAt,
+		}
+	}
+	return users, total, nil
+}
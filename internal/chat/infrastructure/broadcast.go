@@ -0,0 +1,210 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	broadcastRepository struct {
+		db *sqlx.DB
+	}
+
+	Broadcast struct {
+		ID          string       `db:"id"`
+		Text        string       `db:"text"`
+		Channel     int          `db:"channel"`
+		ActiveSince sql.NullTime `db:"active_since"`
+		Status      int          `db:"status"`
+		Total       int          `db:"total"`
+		Sent        int          `db:"sent"`
+		Failed      int          `db:"failed"`
+		CTime       time.Time    `db:"ctime"`
+	}
+
+	Delivery struct {
+		ID            int64  `db:"id"`
+		BroadcastID   string `db:"broadcast_id"`
+		Channel       int    `db:"channel"`
+		ChannelUserID string `db:"channel_user_id"`
+		Status        int    `db:"status"`
+		Error         string `db:"error"`
+	}
+)
+
+// 投递错误信息的最大长度，与表结构保持一致
+const maxDeliveryErrorLength = 255
+
+var _ domain.BroadcastRepository = (*broadcastRepository)(nil)
+
+func NewBroadcastRepository(db *sqlx.DB) domain.BroadcastRepository {
+	return &broadcastRepository{db: db}
+}
+
+func (repo *broadcastRepository) Create(ctx context.Context, b *domain.Broadcast, deliveries []*domain.Delivery) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	do := &Broadcast{ID: b.ID, Text: b.Text, Channel: int(b.Filter.Channel), Status: int(b.Status), Total: b.Total}
+	if !b.Filter.ActiveSince.IsZero() {
+		do.ActiveSince = sql.NullTime{Time: b.Filter.ActiveSince, Valid: true}
+	}
+	_, err = tx.NamedExecContext(ctx,
+		"INSERT INTO broadcast (id, text, channel, active_since, status, total) VALUES (:id, :text, :channel, :active_since, :status, :total)", do)
+	if err != nil {
+		return err
+	}
+
+	// 分批写入接收人，避免单条语句过大
+	const batch = 500
+	for start := 0; start < len(deliveries); start += batch {
+		end := start + batch
+		if end > len(deliveries) {
+			end = len(deliveries)
+		}
+		dos := make([]*Delivery, 0, end-start)
+		for _, d := range deliveries[start:end] {
+			dos = append(dos, &Delivery{
+				BroadcastID:   d.BroadcastID,
+				Channel:       int(d.To.Channel),
+				ChannelUserID: string(d.To.ChannelUserID),
+				Status:        int(d.Status),
+			})
+		}
+		_, err = tx.NamedExecContext(ctx,
+			"INSERT INTO broadcast_delivery (broadcast_id, channel, channel_user_id, status) VALUES (:broadcast_id, :channel, :channel_user_id, :status)", dos)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (repo *broadcastRepository) Get(ctx context.Context, id string) (*domain.Broadcast, error) {
+	do := new(Broadcast)
+	err := repo.db.GetContext(ctx, do,
+		"SELECT id, text, channel, active_since, status, total, sent, failed, ctime FROM broadcast WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	return convertBroadcastDO(do), nil
+}
+
+func (repo *broadcastRepository) ListUnfinished(ctx context.Context) ([]*domain.Broadcast, error) {
+	var data []*Broadcast
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT id, text, channel, active_since, status, total, sent, failed, ctime FROM broadcast WHERE status=? ORDER BY ctime",
+		domain.BroadcastRunning)
+	if err != nil {
+		return nil, err
+	}
+	broadcasts := make([]*domain.Broadcast, len(data))
+	for index, do := range data {
+		broadcasts[index] = convertBroadcastDO(do)
+	}
+	return broadcasts, nil
+}
+
+func (repo *broadcastRepository) ListPendingDeliveries(ctx context.Context, id string, limit int) ([]*domain.Delivery, error) {
+	var data []*Delivery
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT id, broadcast_id, channel, channel_user_id, status, error FROM broadcast_delivery "+
+			"WHERE broadcast_id=? AND (status=? OR (status=? AND lease_until<?)) ORDER BY id LIMIT ?",
+		id, domain.DeliveryPending, domain.DeliverySending, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]*domain.Delivery, len(data))
+	for index, do := range data {
+		deliveries[index] = &domain.Delivery{
+			ID:          do.ID,
+			BroadcastID: do.BroadcastID,
+			To:          domain.From{Channel: domain.Channel(do.Channel), ChannelUserID: domain.ChannelUserID(do.ChannelUserID)},
+			Status:      domain.DeliveryStatus(do.Status),
+			Error:       do.Error,
+		}
+	}
+	return deliveries, nil
+}
+
+func (repo *broadcastRepository) ClaimDelivery(ctx context.Context, d *domain.Delivery, owner string, until time.Time) (bool, error) {
+	ret, err := repo.db.ExecContext(ctx,
+		"UPDATE broadcast_delivery SET status=?, owner=?, lease_until=? WHERE id=? AND (status=? OR (status=? AND lease_until<?))",
+		domain.DeliverySending, owner, until, d.ID, domain.DeliveryPending, domain.DeliverySending, time.Now())
+	if err != nil {
+		return false, err
+	}
+	rows, err := ret.RowsAffected()
+	if err != nil || rows == 0 {
+		return false, err
+	}
+	d.Status = domain.DeliverySending
+	d.Owner = owner
+	return true, nil
+}
+
+func (repo *broadcastRepository) SaveDelivery(ctx context.Context, b *domain.Broadcast, d *domain.Delivery) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ret, err := tx.ExecContext(ctx, "UPDATE broadcast_delivery SET status=?, error=? WHERE id=? AND status=? AND owner=?",
+		d.Status, truncateRunes(d.Error, maxDeliveryErrorLength), d.ID, domain.DeliverySending, d.Owner)
+	if err != nil {
+		return err
+	}
+	// 租约过期后已被其他实例重新认领的投递不重复计数
+	if rows, err := ret.RowsAffected(); err != nil || rows == 0 {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE broadcast SET sent=sent+?, failed=failed+? WHERE id=?",
+		boolToInt(d.Status == domain.DeliverySent), boolToInt(d.Status == domain.DeliveryFailed), b.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *broadcastRepository) Finish(ctx context.Context, b *domain.Broadcast) (bool, error) {
+	ret, err := repo.db.ExecContext(ctx,
+		"UPDATE broadcast SET status=? WHERE id=? AND NOT EXISTS "+
+			"(SELECT 1 FROM broadcast_delivery WHERE broadcast_id=? AND status IN (?, ?))",
+		b.Status, b.ID, b.ID, domain.DeliveryPending, domain.DeliverySending)
+	if err != nil {
+		return false, err
+	}
+	rows, err := ret.RowsAffected()
+	return rows > 0, err
+}
+
+func convertBroadcastDO(do *Broadcast) *domain.Broadcast {
+	b := &domain.Broadcast{
+		ID:        do.ID,
+		Text:      do.Text,
+		Filter:    domain.UserFilter{Channel: domain.Channel(do.Channel)},
+		Status:    domain.BroadcastStatus(do.Status),
+		Total:     do.Total,
+		Sent:      do.Sent,
+		Failed:    do.Failed,
+		CreatedAt: do.CTime,
+	}
+	if do.ActiveSince.Valid {
+		b.Filter.ActiveSince = do.ActiveSince.Time
+	}
+	return b
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	callbackRepository struct {
+		db *sqlx.DB
+	}
+
+	callbackSender struct {
+		client *http.Client
+	}
+
+	CallbackEndpoint struct {
+		Channel       int    `db:"channel"`
+		ChannelUserID string `db:"channel_user_id"`
+		Client        string `db:"client"`
+		URL           string `db:"url"`
+	}
+
+	CallbackDelivery struct {
+		ID             int64        `db:"id"`
+		Channel        int          `db:"channel"`
+		ChannelUserID  string       `db:"channel_user_id"`
+		ChatID         string       `db:"chat_id"`
+		Event          string       `db:"event"`
+		URL            string       `db:"url"`
+		Status         int          `db:"status"`
+		Attempts       int          `db:"attempts"`
+		ResponseStatus int          `db:"response_status"`
+		Error          string       `db:"error"`
+		Payload        []byte       `db:"payload"`
+		Owner          string       `db:"owner"`
+		LeaseUntil     sql.NullTime `db:"lease_until"`
+		CTime          time.Time    `db:"ctime"`
+		MTime          time.Time    `db:"mtime"`
+	}
+)
+
+var (
+	_ domain.CallbackRepository = (*callbackRepository)(nil)
+	_ domain.CallbackSender     = (*callbackSender)(nil)
+)
+
+func NewCallbackRepository(db *sqlx.DB) domain.CallbackRepository {
+	return &callbackRepository{db: db}
+}
+
+func (repo *callbackRepository) SaveEndpoint(ctx context.Context, ep *domain.CallbackEndpoint) error {
+	_, err := repo.db.NamedExecContext(ctx,
+		"INSERT INTO rest_callback_endpoint (channel, channel_user_id, client, url) VALUES (:channel, :channel_user_id, :client, :url) "+
+			"ON DUPLICATE KEY UPDATE client=VALUES(client), url=VALUES(url)",
+		&CallbackEndpoint{Channel: int(ep.From.Channel), ChannelUserID: string(ep.From.ChannelUserID), Client: ep.Client, URL: ep.URL},
+	)
+	return err
+}
+
+func (repo *callbackRepository) GetEndpoint(ctx context.Context, f domain.From) (*domain.CallbackEndpoint, error) {
+	do := new(CallbackEndpoint)
+	err := repo.db.GetContext(ctx, do,
+		"SELECT channel, channel_user_id, client, url FROM rest_callback_endpoint WHERE channel=? AND channel_user_id=?",
+		f.Channel, f.ChannelUserID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.CallbackEndpoint{From: f, Client: do.Client, URL: do.URL}, nil
+}
+
+// SaveDelivery 更新投递结果时仅限认领该投递的实例
+func (repo *callbackRepository) SaveDelivery(ctx context.Context, d *domain.CallbackDelivery) error {
+	do := &CallbackDelivery{
+		ID:             d.ID,
+		Channel:        int(d.From.Channel),
+		ChannelUserID:  string(d.From.ChannelUserID),
+		ChatID:         d.ChatID,
+		Event:          d.Event,
+		URL:            d.URL,
+		Status:         int(d.Status),
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		Error:          truncateRunes(d.Error, maxDeliveryErrorLength),
+		Payload:        d.Payload,
+		Owner:          d.Owner,
+		LeaseUntil:     sql.NullTime{Time: d.LeaseUntil, Valid: !d.LeaseUntil.IsZero()},
+	}
+	if d.ID != 0 {
+		_, err := repo.db.NamedExecContext(ctx,
+			"UPDATE rest_callback_delivery SET status=:status, attempts=:attempts, response_status=:response_status, error=:error "+
+				"WHERE id=:id AND owner=:owner", do)
+		return err
+	}
+	ret, err := repo.db.NamedExecContext(ctx,
+		"INSERT INTO rest_callback_delivery (channel, channel_user_id, chat_id, event, url, status, attempts, response_status, error, payload, owner, lease_until) "+
+			"VALUES (:channel, :channel_user_id, :chat_id, :event, :url, :status, :attempts, :response_status, :error, :payload, :owner, :lease_until)", do)
+	if err != nil {
+		return err
+	}
+	d.ID, err = ret.LastInsertId()
+	return err
+}
+
+// ListDeliveries 按客户端前缀筛选投递记录，最新的在前
+func (repo *callbackRepository) ListDeliveries(ctx context.Context, client string, p domain.Pagination) ([]*domain.CallbackDelivery, int64, error) {
+	prefix := escapeLike(client+"/") + "%"
+	var total int64
+	if err := repo.db.GetContext(ctx, &total,
+		"SELECT COUNT(id) FROM rest_callback_delivery WHERE channel=? AND channel_user_id LIKE ?", domain.ChannelREST, prefix); err != nil {
+		return nil, 0, err
+	}
+	var data []*CallbackDelivery
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT id, channel, channel_user_id, chat_id, event, url, status, attempts, response_status, error, ctime, mtime "+
+			"FROM rest_callback_delivery WHERE channel=? AND channel_user_id LIKE ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		domain.ChannelREST, prefix, p.PageSize, p.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	return convertCallbackDeliveries(data), total, nil
+}
+
+func (repo *callbackRepository) ListPending(ctx context.Context) ([]*domain.CallbackDelivery, error) {
+	var data []*CallbackDelivery
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT id, channel, channel_user_id, chat_id, event, url, status, attempts, response_status, error, payload, ctime, mtime "+
+			"FROM rest_callback_delivery WHERE status=? AND (lease_until IS NULL OR lease_until<?) ORDER BY id",
+		domain.CallbackPending, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return convertCallbackDeliveries(data), nil
+}
+
+func (repo *callbackRepository) ClaimDelivery(ctx context.Context, d *domain.CallbackDelivery, owner string, until time.Time) (bool, error) {
+	ret, err := repo.db.ExecContext(ctx,
+		"UPDATE rest_callback_delivery SET owner=?, lease_until=? WHERE id=? AND status=? AND (owner=? OR lease_until IS NULL OR lease_until<?)",
+		owner, until, d.ID, domain.CallbackPending, owner, time.Now())
+	if err != nil {
+		return false, err
+	}
+	rows, err := ret.RowsAffected()
+	if err != nil || rows == 0 {
+		return false, err
+	}
+	d.Owner = owner
+	d.LeaseUntil = until
+	return true, nil
+}
+
+func convertCallbackDeliveries(data []*CallbackDelivery) []*domain.CallbackDelivery {
+	deliveries := make([]*domain.CallbackDelivery, len(data))
+	for index, do := range data {
+		deliveries[index] = &domain.CallbackDelivery{
+			ID:             do.ID,
+			From:           domain.From{Channel: domain.Channel(do.Channel), ChannelUserID: domain.ChannelUserID(do.ChannelUserID)},
+			ChatID:         do.ChatID,
+			Event:          do.Event,
+			URL:            do.URL,
+			Status:         domain.CallbackStatus(do.Status),
+			Attempts:       do.Attempts,
+			ResponseStatus: do.ResponseStatus,
+			Error:          do.Error,
+			Payload:        do.Payload,
+			CreatedAt:      do.CTime,
+			UpdatedAt:      do.MTime,
+		}
+	}
+	return deliveries
+}
+
+// NewCallbackSender client应拒绝访问未经允许的内网地址，参见safehttp.NewClient
+func NewCallbackSender(client *http.Client) domain.CallbackSender {
+	return &callbackSender{client: client}
+}
+
+func (s *callbackSender) Send(ctx context.Context, url string, header map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
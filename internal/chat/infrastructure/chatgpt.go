@@ -2,19 +2,77 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
 	"github.com/sashabaranov/go-openai"
 )
 
-type chatgptService struct {
-	client *openai.Client
-}
+type (
+	chatgptService struct {
+		client        *openai.Client
+		knowledge     domain.KnowledgeRepository
+		embedding     domain.EmbeddingService
+		topK          int
+		tools         *domain.ToolRegistry
+		maxIterations int
+		moderator     domain.Moderator
+		catalog       *i18n.Catalog
+	}
+
+	ServiceOption func(*chatgptService)
+)
 
 var _ domain.ChatGTPService = (*chatgptService)(nil)
 
-func NewChatGTPServer(client *openai.Client) domain.ChatGTPService {
-	return &chatgptService{client: client}
+func NewChatGTPServer(client *openai.Client, opts ...ServiceOption) domain.ChatGTPService {
+	srv := &chatgptService{
+		client:        client,
+		topK:          domain.DefaultTopK,
+		maxIterations: domain.DefaultMaxToolIterations,
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
+}
+
+// WithKnowledge 启用知识库检索
+func WithKnowledge(kr domain.KnowledgeRepository, es domain.EmbeddingService, topK int) ServiceOption {
+	return func(srv *chatgptService) {
+		srv.knowledge = kr
+		srv.embedding = es
+		if topK > 0 {
+			srv.topK = topK
+		}
+	}
+}
+
+// WithTools 启用工具调用，maxIterations限制单次对话中模型与工具的往返次数
+func WithTools(registry *domain.ToolRegistry, maxIterations int) ServiceOption {
+	return func(srv *chatgptService) {
+		srv.tools = registry
+		if maxIterations > 0 {
+			srv.maxIterations = maxIterations
+		}
+	}
+}
+
+// WithCatalog 参考资料、已阅读网页等附加内容的标题按chat.Locale翻译
+func WithCatalog(catalog *i18n.Catalog) ServiceOption {
+	return func(srv *chatgptService) {
+		srv.catalog = catalog
+	}
+}
+
+// WithModerator 在回复用户前审核completion
+func WithModerator(moderator domain.Moderator) ServiceOption {
+	return func(srv *chatgptService) {
+		srv.moderator = moderator
+	}
 }
 
 func (gpt *chatgptService) Chat(ctx context.Context, chat *domain.Chat) (*domain.Conversation, error) {
@@ -24,27 +82,185 @@ func (gpt *chatgptService) Chat(ctx context.Context, chat *domain.Chat) (*domain
 		return nil, err
 	}
 
-	messages := make([]openai.ChatCompletionMessage, len(history)+1)
-	for index, conv := range history {
-		messages[index] = openai.ChatCompletionMessage{
+	var references []*domain.ScoredChunk
+	if chat.Knowledge {
+		if references, err = gpt.retrieve(ctx, current.Prompt); err != nil {
+			return chat.Interrupt(err)
+		}
+	}
+
+	messages := make([]openai.ChatCompletionMessage, 0, len(history)+3)
+	if len(current.Documents) > 0 {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Content: buildDocumentPrompt(current.Documents),
+			Role:    openai.ChatMessageRoleSystem,
+		})
+	}
+	if len(references) > 0 {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Content: buildKnowledgePrompt(references),
+			Role:    openai.ChatMessageRoleSystem,
+		})
+	}
+	for _, conv := range history {
+		messages = append(messages, openai.ChatCompletionMessage{
 			Content: conv.Prompt,
 			Role:    openai.ChatMessageRoleUser,
-		}
+		})
 	}
-	messages[len(history)] = openai.ChatCompletionMessage{
+	messages = append(messages, openai.ChatCompletionMessage{
 		Content: current.Prompt,
 		Role:    openai.ChatMessageRoleUser,
+	})
+
+	functions := gpt.functions()
+	for i := 0; i < gpt.maxIterations; i++ {
+		resp, err := gpt.client.CreateChatCompletion(
+			ctx,
+			openai.ChatCompletionRequest{
+				Model:     openai.GPT3Dot5Turbo,
+				Messages:  messages,
+				Functions: functions,
+			},
+		)
+		if err != nil {
+			return chat.Interrupt(err)
+		}
+		current.Consume(resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		if len(resp.Choices) == 0 {
+			return chat.Interrupt(errors.New("empty completion"))
+		}
+
+		msg := resp.Choices[0].Message
+		if msg.FunctionCall == nil {
+			if gpt.moderator != nil {
+				result, err := gpt.moderator.Moderate(ctx, msg.Content)
+				if err != nil {
+					return chat.Interrupt(err)
+				}
+				if result.Flagged {
+					return chat.Block(result.Category)
+				}
+			}
+			completion := appendCitations(msg.Content, gpt.t(chat.Locale, "reply.references"), references)
+			return chat.Reply(appendDocuments(completion, gpt.t(chat.Locale, "reply.documents"), current.Documents))
+		}
+
+		call := gpt.callTool(ctx, chat, msg.FunctionCall)
+		current.AddToolCall(call)
+		result := call.Result
+		if call.Error != "" {
+			result = "error: " + call.Error
+		}
+		messages = append(messages, msg, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleFunction,
+			Name:    call.Name,
+			Content: result,
+		})
 	}
+	return chat.Interrupt(fmt.Errorf("exceeded the maximum of %d tool iterations", gpt.maxIterations))
+}
+
+func (gpt *chatgptService) functions() []openai.FunctionDefinition {
+	if gpt.tools == nil {
+		return nil
+	}
+	tools := gpt.tools.Tools()
+	definitions := make([]openai.FunctionDefinition, len(tools))
+	for index, tool := range tools {
+		definitions[index] = openai.FunctionDefinition{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			Parameters:  tool.Parameters(),
+		}
+	}
+	return definitions
+}
+
+func (gpt *chatgptService) callTool(ctx context.Context, chat *domain.Chat, fc *openai.FunctionCall) *domain.ToolCall {
+	call := &domain.ToolCall{Name: fc.Name, Arguments: fc.Arguments}
+	if gpt.tools == nil {
+		call.Error = "tools are disabled"
+		return call
+	}
+	tool, ok := gpt.tools.Get(fc.Name)
+	if !ok {
+		call.Error = fmt.Sprintf("unknown tool %s", fc.Name)
+		return call
+	}
+	result, err := tool.Call(ctx, chat, fc.Arguments)
+	if err != nil {
+		call.Error = err.Error()
+		return call
+	}
+	call.Result = result
+	return call
+}
 
-	resp, err := gpt.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT3Dot5Turbo,
-			Messages: messages,
-		},
-	)
+func (gpt *chatgptService) retrieve(ctx context.Context, prompt string) ([]*domain.ScoredChunk, error) {
+	if gpt.knowledge == nil || gpt.embedding == nil {
+		return nil, nil
+	}
+	vectors, err := gpt.embedding.Embed(ctx, prompt)
 	if err != nil {
-		return chat.Interrupt(err)
+		return nil, err
+	}
+	return gpt.knowledge.Search(ctx, vectors[0], gpt.topK)
+}
+
+func buildKnowledgePrompt(references []*domain.ScoredChunk) string {
+	var builder strings.Builder
+	builder.WriteString("Answer the question based on the following documents when they are relevant. " +
+		"Cite the documents you used with their numbers, such as [1].\n")
+	for index, ref := range references {
+		builder.WriteString(fmt.Sprintf("\n[%d] %s\n%s\n", index+1, ref.Citation(), ref.Content))
+	}
+	return builder.String()
+}
+
+// t 未配置catalog时返回消息key
+func (gpt *chatgptService) t(locale, key string) string {
+	if gpt.catalog == nil {
+		return key
+	}
+	return gpt.catalog.T(locale, key)
+}
+
+func appendCitations(completion, title string, references []*domain.ScoredChunk) string {
+	if len(references) == 0 {
+		return completion
+	}
+	var builder strings.Builder
+	builder.WriteString(completion)
+	builder.WriteString("\n\n" + title)
+	for index, ref := range references {
+		builder.WriteString(fmt.Sprintf("\n[%d] %s", index+1, ref.Citation()))
+	}
+	return builder.String()
+}
+
+func buildDocumentPrompt(docs []*domain.Document) string {
+	var builder strings.Builder
+	builder.WriteString("The user referenced the following web pages. Use their content to answer the question.\n")
+	for _, doc := range docs {
+		builder.WriteString(fmt.Sprintf("\nURL: %s\nTitle: %s\n%s\n", doc.URL, doc.Title, doc.Content))
+	}
+	return builder.String()
+}
+
+func appendDocuments(completion, title string, docs []*domain.Document) string {
+	if len(docs) == 0 {
+		return completion
+	}
+	var builder strings.Builder
+	builder.WriteString(completion)
+	builder.WriteString("\n\n" + title)
+	for _, doc := range docs {
+		if doc.Title != "" {
+			builder.WriteString(fmt.Sprintf("\n- %s %s", doc.Title, doc.URL))
+		} else {
+			builder.WriteString(fmt.Sprintf("\n- %s", doc.URL))
+		}
 	}
-	return chat.Reply(resp.Choices[0].Message.Content)
+	return builder.String()
 }
@@ -16,6 +16,10 @@ type (
 		Prompt           sql.NullString `db:"prompt"`
 		Completion       sql.NullString `db:"completion"`
 		ChannelMessageID sql.NullString `db:"channel_message_id"`
+		ToolCalls        sql.NullString `db:"tool_calls"`
+		Model            sql.NullString `db:"model"`
+		PromptTokens     sql.NullInt64  `db:"prompt_tokens"`
+		CompletionTokens sql.NullInt64  `db:"completion_tokens"`
 		CTime            sql.NullTime   `db:"ctime"`
 		MTime            sql.NullTime   `db:"mtime"`
 	}
@@ -27,6 +31,8 @@ type (
 		Channel       int       `db:"channel"`
 		ChannelUserID string    `db:"channel_user_id"`
 		Version       int       `db:"version"`
+		Knowledge     bool      `db:"knowledge"`
+		Thread        string    `db:"thread"`
 		CTime         time.Time `db:"ctime"`
 		MTime         time.Time `db:"mtime"`
 		Deleted       int       `db:"deleted"`
@@ -39,8 +45,10 @@ func ConverDO(ch *Chat, cs ...*Conversation) (*domain.Chat, error) {
 		From:          domain.From{Channel: domain.Channel(ch.Channel), ChannelUserID: domain.ChannelUserID(ch.ChannelUserID)},
 		Version:       ch.Version,
 		Counts:        ch.Counts,
+		Knowledge:     ch.Knowledge,
+		Thread:        ch.Thread,
 		Conversations: make([]*domain.Conversation, len(cs)),
-		Status:        domain.StatusReady,
+		Status:        domain.Status(ch.Deleted),
 		CreatedAt:     ch.CTime,
 		Event:         mediator.NewEventCollection(),
 	}
@@ -53,15 +61,43 @@ func ConverDO(ch *Chat, cs ...*Conversation) (*domain.Chat, error) {
 	}
 
 	for index, con := range cs {
-		c.Conversations[index] = &domain.Conversation{
-			MessageID:  domain.ChannelMessageID(con.ChannelMessageID.String),
-			Prompt:     con.Prompt.String,
-			Completion: con.Completion.String,
+		conv, err := ConvertConversationDO(con)
+		if err != nil {
+			return nil, err
 		}
+		c.Conversations[index] = conv
 	}
 	return c, nil
 }
 
+func ConvertConversationDO(con *Conversation) (*domain.Conversation, error) {
+	conv := &domain.Conversation{
+		MessageID:        domain.ChannelMessageID(con.ChannelMessageID.String),
+		Prompt:           con.Prompt.String,
+		Completion:       con.Completion.String,
+		Model:            con.Model.String,
+		PromptTokens:     int(con.PromptTokens.Int64),
+		CompletionTokens: int(con.CompletionTokens.Int64),
+	}
+	if con.ToolCalls.Valid && con.ToolCalls.String != "" {
+		if err := json.Unmarshal([]byte(con.ToolCalls.String), &conv.ToolCalls); err != nil {
+			return nil, err
+		}
+	}
+	return conv, nil
+}
+
+func convertToolCalls(calls []*domain.ToolCall) (sql.NullString, error) {
+	if len(calls) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(calls)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
 func ConvertEntityChat(entity *domain.Chat) (*Chat, error) {
 	c := &Chat{
 		ID:            entity.ID,
@@ -69,6 +105,8 @@ func ConvertEntityChat(entity *domain.Chat) (*Chat, error) {
 		Channel:       int(entity.From.Channel),
 		ChannelUserID: string(entity.From.ChannelUserID),
 		Version:       entity.Version,
+		Knowledge:     entity.Knowledge,
+		Thread:        entity.Thread,
 		Deleted:       int(entity.Status),
 	}
 	if entity.Current != nil {
@@ -0,0 +1,151 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/sashabaranov/go-openai"
+)
+
+type (
+	knowledgeRepository struct {
+		db *sqlx.DB
+	}
+
+	embeddingService struct {
+		client *openai.Client
+	}
+
+	KnowledgeChunk struct {
+		ID      int64  `db:"id"`
+		Source  string `db:"source"`
+		Heading string `db:"heading"`
+		Content string `db:"content"`
+		Vector  []byte `db:"vector"`
+	}
+)
+
+const maxHeadingLength = 255
+
+var (
+	_ domain.KnowledgeRepository = (*knowledgeRepository)(nil)
+	_ domain.EmbeddingService    = (*embeddingService)(nil)
+)
+
+func NewKnowledgeRepository(db *sqlx.DB) domain.KnowledgeRepository {
+	return &knowledgeRepository{db: db}
+}
+
+// Replace 在同一事务中删除来源的旧片段并写入新片段
+func (repo *knowledgeRepository) Replace(ctx context.Context, source string, chunks ...*domain.Chunk) error {
+	tx, err := repo.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM knowledge_chunk WHERE source=?", source); err != nil {
+		return err
+	}
+	if len(chunks) > 0 {
+		dos := make([]*KnowledgeChunk, len(chunks))
+		for index, chunk := range chunks {
+			dos[index] = &KnowledgeChunk{
+				Source: chunk.Source,
+				// 标题超出字段长度时截断
+				Heading: truncateRunes(chunk.Heading, maxHeadingLength),
+				Content: chunk.Content,
+				Vector:  encodeVector(chunk.Vector),
+			}
+		}
+		_, err = tx.NamedExecContext(ctx,
+			"INSERT INTO knowledge_chunk (source, heading, content, vector) VALUES (:source, :heading, :content, :vector)", dos)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Search 暴力检索：全量加载向量并计算余弦相似度
+func (repo *knowledgeRepository) Search(ctx context.Context, vector []float32, k int) ([]*domain.ScoredChunk, error) {
+	if k <= 0 {
+		k = domain.DefaultTopK
+	}
+	rows, err := repo.db.QueryxContext(ctx, "SELECT id, source, heading, content, vector FROM knowledge_chunk")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scored := make([]*domain.ScoredChunk, 0, k+1)
+	for rows.Next() {
+		do := new(KnowledgeChunk)
+		if err := rows.StructScan(do); err != nil {
+			return nil, err
+		}
+		chunk := &domain.Chunk{
+			ID:      do.ID,
+			Source:  do.Source,
+			Heading: do.Heading,
+			Content: do.Content,
+			Vector:  decodeVector(do.Vector),
+		}
+		scored = append(scored, &domain.ScoredChunk{Chunk: chunk, Score: domain.CosineSimilarity(vector, chunk.Vector)})
+		sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+		if len(scored) > k {
+			scored = scored[:k]
+		}
+	}
+	if err := rows.Err(); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return scored, nil
+}
+
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for index, v := range vector {
+		binary.LittleEndian.PutUint32(data[index*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for index := range vector {
+		vector[index] = math.Float32frombits(binary.LittleEndian.Uint32(data[index*4:]))
+	}
+	return vector
+}
+
+func NewEmbeddingService(client *openai.Client) domain.EmbeddingService {
+	return &embeddingService{client: client}
+}
+
+func (es *embeddingService) Embed(ctx context.Context, inputs ...string) ([][]float32, error) {
+	resp, err := es.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: inputs,
+		Model: openai.AdaEmbeddingV2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, errors.New("mismatched embedding results")
+	}
+	vectors := make([][]float32, len(inputs))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(vectors) {
+			return nil, errors.New("unexpected embedding index")
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	return vectors, nil
+}
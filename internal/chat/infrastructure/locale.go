@@ -0,0 +1,65 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	localeRepository struct {
+		db *sqlx.DB
+	}
+
+	// memoryLocaleRepository 用于不依赖MySQL的CLI通道，重启后丢失
+	memoryLocaleRepository struct {
+		mu      sync.RWMutex
+		locales map[domain.From]string
+	}
+)
+
+var (
+	_ domain.LocaleRepository = (*localeRepository)(nil)
+	_ domain.LocaleRepository = (*memoryLocaleRepository)(nil)
+)
+
+func NewLocaleRepository(db *sqlx.DB) domain.LocaleRepository {
+	return &localeRepository{db: db}
+}
+
+func (repo *localeRepository) Get(ctx context.Context, f domain.From) (string, error) {
+	var locale string
+	err := repo.db.GetContext(ctx, &locale, "SELECT locale FROM user_locale WHERE channel=? AND channel_user_id=?", f.Channel, f.ChannelUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return locale, err
+}
+
+func (repo *localeRepository) Save(ctx context.Context, f domain.From, locale string) error {
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO user_locale (channel, channel_user_id, locale) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE locale=VALUES(locale)",
+		f.Channel, f.ChannelUserID, locale)
+	return err
+}
+
+func NewMemoryLocaleRepository() domain.LocaleRepository {
+	return &memoryLocaleRepository{locales: make(map[domain.From]string)}
+}
+
+func (repo *memoryLocaleRepository) Get(_ context.Context, f domain.From) (string, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.locales[f], nil
+}
+
+func (repo *memoryLocaleRepository) Save(_ context.Context, f domain.From, locale string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.locales[f] = locale
+	return nil
+}
@@ -0,0 +1,181 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+)
+
+type (
+	// memoryRepository 进程内的会话仓储，用于本地调试，重启后数据丢失。
+	// 与MySQL实现一样以DO保存，读取时重新构造实体，避免调用方修改已保存的数据
+	memoryRepository struct {
+		mu     sync.Mutex
+		chats  map[string]*memoryChat
+		lastID int32
+	}
+
+	memoryChat struct {
+		chat  Chat
+		convs []Conversation
+	}
+)
+
+var _ domain.Repository = (*memoryRepository)(nil)
+
+func NewMemoryRepository() domain.Repository {
+	return &memoryRepository{chats: make(map[string]*memoryChat)}
+}
+
+func (repo *memoryRepository) Get(ctx context.Context, from domain.From) (*domain.Chat, error) {
+	return repo.GetThread(ctx, from, "")
+}
+
+func (repo *memoryRepository) GetThread(_ context.Context, from domain.From, thread string) (*domain.Chat, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, mc := range repo.chats {
+		if mc.chat.Channel == int(from.Channel) && mc.chat.ChannelUserID == string(from.ChannelUserID) && mc.chat.Thread == thread && mc.chat.Deleted == 0 {
+			return mc.entity()
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (repo *memoryRepository) GetByChatID(_ context.Context, cid string) (*domain.Chat, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	mc, ok := repo.chats[cid]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return mc.entity()
+}
+
+func (repo *memoryRepository) Save(_ context.Context, chat *domain.Chat) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if chat.Version == 0 { // 新增
+		do, err := ConvertEntityChat(chat)
+		if err != nil {
+			return err
+		}
+		if do.Deleted == 0 {
+			for id, mc := range repo.chats {
+				if id != do.ID && mc.chat.Channel == do.Channel && mc.chat.ChannelUserID == do.ChannelUserID && mc.chat.Thread == do.Thread && mc.chat.Deleted == 0 {
+					return errors.New("duplicated chat")
+				}
+			}
+		}
+		do.Version = 1
+		do.CTime = time.Now()
+		do.MTime = do.CTime
+		mc := &memoryChat{chat: *do}
+		if err = repo.appendLastConversation(mc, chat); err != nil {
+			return err
+		}
+		repo.chats[do.ID] = mc
+		return nil
+	}
+
+	// 更新记录
+	chat.IsFinished()
+	do, err := ConvertEntityChat(chat)
+	if err != nil {
+		return err
+	}
+	mc, ok := repo.chats[do.ID]
+	if !ok || mc.chat.Deleted != 0 {
+		return errors.New("data outdated")
+	}
+	if err = repo.appendLastConversation(mc, chat); err != nil {
+		return err
+	}
+	mc.chat.Counts = do.Counts
+	mc.chat.Current = do.Current
+	mc.chat.Knowledge = do.Knowledge
+	mc.chat.Deleted = do.Deleted
+	mc.chat.Version++
+	mc.chat.MTime = time.Now()
+	return nil
+}
+
+// appendLastConversation 与insertLastConversation一致，conversation完成后保存最后一条
+func (repo *memoryRepository) appendLastConversation(mc *memoryChat, chat *domain.Chat) error {
+	if chat.Current != nil || len(chat.Conversations) == 0 || len(mc.convs) >= len(chat.Conversations) {
+		return nil
+	}
+	last := chat.Conversations[len(chat.Conversations)-1]
+	toolCalls, err := convertToolCalls(last.ToolCalls)
+	if err != nil {
+		return err
+	}
+	repo.lastID++
+	now := time.Now()
+	mc.convs = append(mc.convs, Conversation{
+		ID:               sql.NullInt32{Int32: repo.lastID, Valid: true},
+		ChatID:           sql.NullString{String: mc.chat.ID, Valid: true},
+		Prompt:           sql.NullString{String: last.Prompt, Valid: true},
+		Completion:       sql.NullString{String: last.Completion, Valid: true},
+		ChannelMessageID: sql.NullString{String: string(last.MessageID), Valid: true},
+		ToolCalls:        toolCalls,
+		Model:            sql.NullString{String: last.Model, Valid: true},
+		PromptTokens:     sql.NullInt64{Int64: int64(last.PromptTokens), Valid: true},
+		CompletionTokens: sql.NullInt64{Int64: int64(last.CompletionTokens), Valid: true},
+		CTime:            sql.NullTime{Time: now, Valid: true},
+		MTime:            sql.NullTime{Time: now, Valid: true},
+	})
+	return nil
+}
+
+func (repo *memoryRepository) SearchConversations(_ context.Context, from domain.From, keyword string, limit int) ([]*domain.Conversation, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	// 与MySQL默认的排序规则一致，不区分大小写
+	keyword = strings.ToLower(keyword)
+	var matched []Conversation
+	for _, mc := range repo.chats {
+		if mc.chat.Channel != int(from.Channel) || mc.chat.ChannelUserID != string(from.ChannelUserID) {
+			continue
+		}
+		for _, do := range mc.convs {
+			if strings.Contains(strings.ToLower(do.Prompt.String), keyword) || strings.Contains(strings.ToLower(do.Completion.String), keyword) {
+				matched = append(matched, do)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID.Int32 > matched[j].ID.Int32 })
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	convs := make([]*domain.Conversation, len(matched))
+	for index := range matched {
+		conv, err := ConvertConversationDO(&matched[index])
+		if err != nil {
+			return nil, err
+		}
+		convs[index] = conv
+	}
+	return convs, nil
+}
+
+func (mc *memoryChat) entity() (*domain.Chat, error) {
+	chat := mc.chat
+	convs := make([]*Conversation, len(mc.convs))
+	for index := range mc.convs {
+		do := mc.convs[index]
+		convs[index] = &do
+	}
+	return ConverDO(&chat, convs...)
+}
@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	from := domain.From{Channel: domain.ChannelCLI, ChannelUserID: "alice"}
+
+	_, err := repo.Get(ctx, from)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	chat := domain.NewChat(from)
+	assert.NoError(t, repo.Save(ctx, chat))
+	assert.EqualError(t, repo.Save(ctx, domain.NewChat(from)), "duplicated chat")
+
+	// 读取的实体与已保存的数据相互独立
+	chat, err = repo.Get(ctx, from)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, chat.Version)
+	assert.NoError(t, chat.Prompt("What is Go?", "1"))
+	stored, _ := repo.GetByChatID(ctx, chat.ID)
+	assert.Nil(t, stored.Current)
+
+	assert.NoError(t, repo.Save(ctx, chat))
+	chat, _ = repo.Get(ctx, from)
+	assert.Equal(t, "What is Go?", chat.Current.Prompt)
+	_, err = chat.Reply("A programming language")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Save(ctx, chat))
+
+	chat, _ = repo.Get(ctx, from)
+	assert.Equal(t, 3, chat.Version)
+	assert.Nil(t, chat.Current)
+	assert.Len(t, chat.Conversations, 1)
+	assert.Equal(t, domain.ChannelMessageID("1"), chat.Conversations[0].MessageID)
+
+	convs, err := repo.SearchConversations(ctx, from, "LANGUAGE", 10)
+	assert.NoError(t, err)
+	assert.Len(t, convs, 1)
+	convs, _ = repo.SearchConversations(ctx, domain.From{Channel: domain.ChannelCLI, ChannelUserID: "bob"}, "go", 10)
+	assert.Empty(t, convs)
+
+	chat.Shutdown()
+	assert.NoError(t, repo.Save(ctx, chat))
+	_, err = repo.Get(ctx, from)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.EqualError(t, repo.Save(ctx, chat), "data outdated")
+	ended, err := repo.GetByChatID(ctx, chat.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusEnded, ended.Status)
+	assert.NoError(t, repo.Save(ctx, domain.NewChat(from)))
+}
+
+func TestMemoryRepositoryThread(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	from := domain.NewSlackFrom("T061EG9R6", "U0G9QF9C6")
+
+	assert.NoError(t, repo.Save(ctx, domain.NewThreadChat(from, "C1:1355517523.000005")))
+	assert.NoError(t, repo.Save(ctx, domain.NewThreadChat(from, "C1:1355517524.000006")))
+	assert.EqualError(t, repo.Save(ctx, domain.NewThreadChat(from, "C1:1355517524.000006")), "duplicated chat")
+	_, err := repo.Get(ctx, from)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	chat, err := repo.GetThread(ctx, from, "C1:1355517523.000005")
+	assert.NoError(t, err)
+	assert.Equal(t, "C1:1355517523.000005", chat.Thread)
+	assert.Equal(t, from, chat.From)
+}
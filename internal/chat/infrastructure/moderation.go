@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/sashabaranov/go-openai"
+)
+
+type (
+	openaiModerator struct {
+		client *openai.Client
+	}
+
+	// ModerationRule 本地审核规则，关键字忽略大小写
+	ModerationRule struct {
+		Category string   `json:"category" yaml:"category"`
+		Keywords []string `json:"keywords" yaml:"keywords"`
+		Patterns []string `json:"patterns" yaml:"patterns"`
+	}
+
+	compiledRule struct {
+		category string
+		keywords []string
+		patterns []*regexp.Regexp
+	}
+
+	keywordModerator struct {
+		rules []*compiledRule
+	}
+
+	// chainModerator 依次执行多个审核器，任一命中即拦截
+	chainModerator []domain.Moderator
+)
+
+var (
+	_ domain.Moderator = (*openaiModerator)(nil)
+	_ domain.Moderator = (*keywordModerator)(nil)
+	_ domain.Moderator = (chainModerator)(nil)
+)
+
+func NewOpenAIModerator(client *openai.Client) domain.Moderator {
+	return &openaiModerator{client: client}
+}
+
+func (om *openaiModerator) Moderate(ctx context.Context, text string) (*domain.ModerationResult, error) {
+	resp, err := om.client.Moderations(ctx, openai.ModerationRequest{Input: text, Model: openai.ModerationTextLatest})
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range resp.Results {
+		if !result.Flagged {
+			continue
+		}
+		categories := result.Categories
+		switch {
+		case categories.SexualMinors:
+			return domain.Flagged("sexual/minors"), nil
+		case categories.Sexual:
+			return domain.Flagged("sexual"), nil
+		case categories.HateThreatening:
+			return domain.Flagged("hate/threatening"), nil
+		case categories.Hate:
+			return domain.Flagged("hate"), nil
+		case categories.SelfHarm:
+			return domain.Flagged("self-harm"), nil
+		case categories.ViolenceGraphic:
+			return domain.Flagged("violence/graphic"), nil
+		case categories.Violence:
+			return domain.Flagged("violence"), nil
+		default:
+			return domain.Flagged("flagged"), nil
+		}
+	}
+	return domain.Passed(), nil
+}
+
+func NewKeywordModerator(rules []ModerationRule) (domain.Moderator, error) {
+	km := &keywordModerator{rules: make([]*compiledRule, len(rules))}
+	for index, rule := range rules {
+		cr := &compiledRule{category: rule.Category}
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				cr.keywords = append(cr.keywords, strings.ToLower(keyword))
+			}
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			cr.patterns = append(cr.patterns, re)
+		}
+		km.rules[index] = cr
+	}
+	return km, nil
+}
+
+func (km *keywordModerator) Moderate(_ context.Context, text string) (*domain.ModerationResult, error) {
+	lower := strings.ToLower(text)
+	for _, rule := range km.rules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(lower, keyword) {
+				return domain.Flagged(rule.category), nil
+			}
+		}
+		for _, re := range rule.patterns {
+			if re.MatchString(text) {
+				return domain.Flagged(rule.category), nil
+			}
+		}
+	}
+	return domain.Passed(), nil
+}
+
+func NewChainModerator(moderators ...domain.Moderator) domain.Moderator {
+	return chainModerator(moderators)
+}
+
+func (cm chainModerator) Moderate(ctx context.Context, text string) (*domain.ModerationResult, error) {
+	for _, moderator := range cm {
+		result, err := moderator.Moderate(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		if result.Flagged {
+			return result, nil
+		}
+	}
+	return domain.Passed(), nil
+}
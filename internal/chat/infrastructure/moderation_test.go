@@ -0,0 +1,30 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeywordModerator(t *testing.T) {
+	moderator, err := NewKeywordModerator([]ModerationRule{
+		{Category: "gambling", Keywords: []string{"Casino"}},
+		{Category: "phone", Patterns: []string{`1[3-9]\d{9}`}},
+	})
+	assert.NoError(t, err)
+
+	result, err := moderator.Moderate(context.Background(), "best casino in town")
+	assert.NoError(t, err)
+	assert.True(t, result.Flagged)
+	assert.Equal(t, "gambling", result.Category)
+
+	result, _ = moderator.Moderate(context.Background(), "call 13812345678")
+	assert.Equal(t, "phone", result.Category)
+
+	result, _ = moderator.Moderate(context.Background(), "hello")
+	assert.False(t, result.Flagged)
+
+	_, err = NewKeywordModerator([]ModerationRule{{Category: "bad", Patterns: []string{"("}}})
+	assert.Error(t, err)
+}
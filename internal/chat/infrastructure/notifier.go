@@ -0,0 +1,82 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/silenceper/wechat/v2/officialaccount"
+	"github.com/silenceper/wechat/v2/officialaccount/message"
+)
+
+type (
+	// telegramNotifier 私聊场景下用户ID即chat ID
+	telegramNotifier struct {
+		bot *tgbotapi.BotAPI
+	}
+
+	// wechatNotifier 客服消息仅能发送给48小时内与公众号有过互动的用户
+	wechatNotifier struct {
+		wechat *officialaccount.OfficialAccount
+	}
+
+	// channelNotifier 按用户所属通道分发
+	channelNotifier struct {
+		notifiers map[domain.Channel]domain.Notifier
+	}
+)
+
+var (
+	_ domain.Notifier        = (*telegramNotifier)(nil)
+	_ domain.Notifier        = (*wechatNotifier)(nil)
+	_ domain.ChannelNotifier = (*channelNotifier)(nil)
+)
+
+func NewTelegramNotifier(bot *tgbotapi.BotAPI) domain.Notifier {
+	return &telegramNotifier{bot: bot}
+}
+
+func (n *telegramNotifier) Notify(_ context.Context, f domain.From, text string) error {
+	chatID, err := strconv.ParseInt(string(f.ChannelUserID), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram user id %q: %w", f.ChannelUserID, err)
+	}
+	_, err = n.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+func NewWechatNotifier(wc *officialaccount.OfficialAccount) domain.Notifier {
+	return &wechatNotifier{wechat: wc}
+}
+
+func (n *wechatNotifier) Notify(_ context.Context, f domain.From, text string) error {
+	return n.wechat.GetCustomerMessageManager().Send(&message.CustomerMessage{
+		ToUser:  string(f.ChannelUserID),
+		Msgtype: message.MsgTypeText,
+		Text:    &message.MediaText{Content: text},
+	})
+}
+
+func NewChannelNotifier(notifiers map[domain.Channel]domain.Notifier) domain.ChannelNotifier {
+	return &channelNotifier{notifiers: notifiers}
+}
+
+func (n *channelNotifier) Notify(ctx context.Context, f domain.From, text string) error {
+	notifier, ok := n.notifiers[f.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %s", f.Channel.String())
+	}
+	return notifier.Notify(ctx, f, text)
+}
+
+func (n *channelNotifier) Channels() []domain.Channel {
+	channels := make([]domain.Channel, 0, len(n.notifiers))
+	for _, channel := range domain.Channels() {
+		if _, ok := n.notifiers[channel]; ok {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
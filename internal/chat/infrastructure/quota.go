@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	quotaRepository struct {
+		db *sqlx.DB
+	}
+
+	UserQuota struct {
+		Channel       int           `db:"channel"`
+		ChannelUserID string        `db:"channel_user_id"`
+		DailyLimit    sql.NullInt64 `db:"daily_limit"`
+		MonthlyLimit  sql.NullInt64 `db:"monthly_limit"`
+		ResetAt       sql.NullTime  `db:"reset_at"`
+	}
+
+	usageRecord struct {
+		PromptTokens     int64 `db:"prompt_tokens"`
+		CompletionTokens int64 `db:"completion_tokens"`
+	}
+)
+
+var _ domain.QuotaRepository = (*quotaRepository)(nil)
+
+func NewQuotaRepository(db *sqlx.DB) domain.QuotaRepository {
+	return &quotaRepository{db: db}
+}
+
+func (repo *quotaRepository) GetUserQuota(ctx context.Context, f domain.From) (*domain.UserQuota, error) {
+	do := new(UserQuota)
+	err := repo.db.GetContext(ctx, do,
+		"SELECT channel, channel_user_id, daily_limit, monthly_limit, reset_at FROM user_quota WHERE channel=? AND channel_user_id=?",
+		f.Channel, f.ChannelUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NewUserQuota(f), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	uq := domain.NewUserQuota(f)
+	if do.DailyLimit.Valid || do.MonthlyLimit.Valid {
+		uq.Limit = &domain.QuotaLimit{Daily: do.DailyLimit.Int64, Monthly: do.MonthlyLimit.Int64}
+	}
+	if do.ResetAt.Valid {
+		uq.ResetAt = do.ResetAt.Time
+	}
+	return uq, nil
+}
+
+func (repo *quotaRepository) SaveUserQuota(ctx context.Context, uq *domain.UserQuota) error {
+	do := &UserQuota{Channel: int(uq.From.Channel), ChannelUserID: string(uq.From.ChannelUserID)}
+	if uq.Limit != nil {
+		do.DailyLimit = sql.NullInt64{Int64: uq.Limit.Daily, Valid: true}
+		do.MonthlyLimit = sql.NullInt64{Int64: uq.Limit.Monthly, Valid: true}
+	}
+	if !uq.ResetAt.IsZero() {
+		do.ResetAt = sql.NullTime{Time: uq.ResetAt, Valid: true}
+	}
+	_, err := repo.db.NamedExecContext(ctx,
+		"INSERT INTO user_quota (channel, channel_user_id, daily_limit, monthly_limit, reset_at) "+
+			"VALUES (:channel, :channel_user_id, :daily_limit, :monthly_limit, :reset_at) "+
+			"ON DUPLICATE KEY UPDATE daily_limit=VALUES(daily_limit), monthly_limit=VALUES(monthly_limit), reset_at=VALUES(reset_at)",
+		do)
+	return err
+}
+
+func (repo *quotaRepository) SumUserUsage(ctx context.Context, f domain.From, since time.Time) (domain.Usage, error) {
+	rec := new(usageRecord)
+	err := repo.db.GetContext(ctx, rec,
+		"SELECT COALESCE(SUM(u.prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(u.completion_tokens), 0) AS completion_tokens FROM ("+
+			"SELECT c2.prompt_tokens, c2.completion_tokens FROM conversation AS c2 INNER JOIN chat AS c1 ON c1.id=c2.chat_id "+
+			"WHERE c1.channel=? AND c1.channel_user_id=? AND c2.ctime>=? UNION ALL "+
+			"SELECT prompt_tokens, completion_tokens FROM discarded_usage WHERE channel=? AND channel_user_id=? AND ctime>=?) AS u",
+		f.Channel, f.ChannelUserID, since, f.Channel, f.ChannelUserID, since)
+	if err != nil {
+		return domain.Usage{}, err
+	}
+	return domain.Usage{PromptTokens: rec.PromptTokens, CompletionTokens: rec.CompletionTokens}, nil
+}
+
+func (repo *quotaRepository) SumChannelUsage(ctx context.Context, ch domain.Channel, since time.Time) (domain.Usage, error) {
+	rec := new(usageRecord)
+	err := repo.db.GetContext(ctx, rec,
+		"SELECT COALESCE(SUM(u.prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(u.completion_tokens), 0) AS completion_tokens FROM ("+
+			"SELECT c2.prompt_tokens, c2.completion_tokens FROM conversation AS c2 INNER JOIN chat AS c1 ON c1.id=c2.chat_id "+
+			"WHERE c1.channel=? AND c2.ctime>=? UNION ALL "+
+			"SELECT prompt_tokens, completion_tokens FROM discarded_usage WHERE channel=? AND ctime>=?) AS u",
+		ch, since, ch, since)
+	if err != nil {
+		return domain.Usage{}, err
+	}
+	return domain.Usage{PromptTokens: rec.PromptTokens, CompletionTokens: rec.CompletionTokens}, nil
+}
+
+func (repo *quotaRepository) AddDiscardedUsage(ctx context.Context, cid string, f domain.From, c domain.Conversation) error {
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO discarded_usage (chat_id, channel, channel_user_id, model, prompt_tokens, completion_tokens) VALUES (?, ?, ?, ?, ?, ?)",
+		cid, f.Channel, f.ChannelUserID, c.Model, c.PromptTokens, c.CompletionTokens)
+	return err
+}
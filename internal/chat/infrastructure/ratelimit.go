@@ -0,0 +1,106 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	// memoryRateLimiter 定期清理已补满的令牌桶，避免匿名会话、IP等一次性的key常驻内存
+	memoryRateLimiter struct {
+		mu      sync.Mutex
+		buckets map[domain.From]*memoryBucket
+		pruneAt time.Time
+	}
+
+	memoryBucket struct {
+		bucket domain.TokenBucket
+		fullAt time.Time
+	}
+
+	// mysqlRateLimiter 令牌桶保存在MySQL中，多实例部署时共享限速状态
+	mysqlRateLimiter struct {
+		db *sqlx.DB
+	}
+
+	TokenBucket struct {
+		Channel       int       `db:"channel"`
+		ChannelUserID string    `db:"channel_user_id"`
+		Tokens        float64   `db:"tokens"`
+		UpdatedAt     time.Time `db:"updated_at"`
+	}
+)
+
+var (
+	_ domain.RateLimiter = (*memoryRateLimiter)(nil)
+	_ domain.RateLimiter = (*mysqlRateLimiter)(nil)
+)
+
+const memoryBucketPruneInterval = time.Minute
+
+func NewMemoryRateLimiter() domain.RateLimiter {
+	return &memoryRateLimiter{buckets: make(map[domain.From]*memoryBucket)}
+}
+
+func (rl *memoryRateLimiter) Take(_ context.Context, f domain.From, limit domain.RateLimit) (time.Duration, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.After(rl.pruneAt) {
+		for key, mb := range rl.buckets {
+			if !now.Before(mb.fullAt) {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.pruneAt = now.Add(memoryBucketPruneInterval)
+	}
+	mb, ok := rl.buckets[f]
+	if !ok {
+		mb = new(memoryBucket)
+		rl.buckets[f] = mb
+	}
+	wait := mb.bucket.Take(limit, now)
+	mb.fullAt = mb.bucket.FullAt(limit)
+	return wait, nil
+}
+
+func NewMySQLRateLimiter(db *sqlx.DB) domain.RateLimiter {
+	return &mysqlRateLimiter{db: db}
+}
+
+func (rl *mysqlRateLimiter) Take(ctx context.Context, f domain.From, limit domain.RateLimit) (time.Duration, error) {
+	tx, err := rl.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	do := new(TokenBucket)
+	err = tx.GetContext(ctx, do,
+		"SELECT channel, channel_user_id, tokens, updated_at FROM rate_limit_bucket WHERE channel=? AND channel_user_id=? FOR UPDATE",
+		f.Channel, f.ChannelUserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	bucket := &domain.TokenBucket{Tokens: do.Tokens, UpdatedAt: do.UpdatedAt}
+	wait := bucket.Take(limit, time.Now())
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO rate_limit_bucket (channel, channel_user_id, tokens, updated_at) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE tokens=VALUES(tokens), updated_at=VALUES(updated_at)",
+		f.Channel, f.ChannelUserID, bucket.Tokens, bucket.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return wait, nil
+}
@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	reportRepository struct {
+		db *sqlx.DB
+	}
+
+	UsageRecord struct {
+		Channel          int    `db:"channel"`
+		ChannelUserID    string `db:"channel_user_id"`
+		Model            string `db:"model"`
+		Conversations    int64  `db:"conversations"`
+		PromptTokens     int64  `db:"prompt_tokens"`
+		CompletionTokens int64  `db:"completion_tokens"`
+	}
+)
+
+var _ domain.ReportRepository = (*reportRepository)(nil)
+
+// 不同维度下的分组字段，均额外按模型分组以便计算费用
+var dimensionColumns = map[domain.ReportDimension]struct{ selects, groups string }{
+	domain.DimensionUser:    {"u.channel AS channel, u.channel_user_id AS channel_user_id", "u.channel, u.channel_user_id, u.model"},
+	domain.DimensionChannel: {"u.channel AS channel, '' AS channel_user_id", "u.channel, u.model"},
+	domain.DimensionModel:   {"0 AS channel, '' AS channel_user_id", "u.model"},
+}
+
+func NewReportRepository(db *sqlx.DB) domain.ReportRepository {
+	return &reportRepository{db: db}
+}
+
+func (repo *reportRepository) AggregateUsage(ctx context.Context, period domain.ReportPeriod, d domain.ReportDimension) ([]*domain.UsageRecord, error) {
+	columns, ok := dimensionColumns[d]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dimension: %s", d)
+	}
+
+	var data []*UsageRecord
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT "+columns.selects+", COALESCE(u.model, '') AS model, COALESCE(SUM(u.replied), 0) AS conversations, "+
+			"COALESCE(SUM(u.prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(u.completion_tokens), 0) AS completion_tokens FROM ("+
+			"SELECT c1.channel, c1.channel_user_id, c2.model, 1 AS replied, c2.prompt_tokens, c2.completion_tokens "+
+			"FROM conversation AS c2 INNER JOIN chat AS c1 ON c1.id=c2.chat_id WHERE c2.ctime>=? AND c2.ctime<? UNION ALL "+
+			"SELECT channel, channel_user_id, model, 0 AS replied, prompt_tokens, completion_tokens "+
+			"FROM discarded_usage WHERE ctime>=? AND ctime<?) AS u "+
+			"GROUP BY "+columns.groups,
+		period.From, period.To, period.From, period.To,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*domain.UsageRecord, len(data))
+	for index, do := range data {
+		records[index] = &domain.UsageRecord{
+			Channel:       domain.Channel(do.Channel),
+			ChannelUserID: domain.ChannelUserID(do.ChannelUserID),
+			Model:         do.Model,
+			Conversations: do.Conversations,
+			Usage:         domain.Usage{PromptTokens: do.PromptTokens, CompletionTokens: do.CompletionTokens},
+		}
+	}
+	return records, nil
+}
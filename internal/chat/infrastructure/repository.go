@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
 	"github.com/jmoiron/sqlx"
@@ -20,6 +21,10 @@ func NewRepository(db *sqlx.DB) domain.Repository {
 }
 
 func (repo *repository) Get(ctx context.Context, from domain.From) (*domain.Chat, error) {
+	return repo.GetThread(ctx, from, "")
+}
+
+func (repo *repository) GetThread(ctx context.Context, from domain.From, thread string) (*domain.Chat, error) {
 	type record struct {
 		*Chat         `db:"c1"`
 		*Conversation `db:"c2"`
@@ -27,10 +32,10 @@ func (repo *repository) Get(ctx context.Context, from domain.From) (*domain.Chat
 	var data []record
 	err := repo.db.SelectContext(ctx, &data,
 		"SELECT c1.id 'c1.id', c1.counts 'c1.counts', c1.current 'c1.current', c1.channel 'c1.channel', c1.channel_user_id 'c1.channel_user_id',"+
-			" c1.version 'c1.version', c1.ctime 'c1.ctime', c1.mtime 'c1.mtime', c1.deleted 'c1.deleted', c2.id 'c2.id', c2.chat_id 'c2.chat_id', "+
-			" c2.prompt 'c2.prompt', c2.completion 'c2.completion', c2.channel_message_id 'c2.channel_message_id', c2.ctime 'c2.ctime', c2.mtime 'c2.mtime' "+
-			" FROM chat AS c1 LEFT JOIN conversation AS c2 ON c1.id=c2.chat_id WHERE c1.channel=? AND c1.channel_user_id=? AND c1.deleted=0 ORDER BY c2.id",
-		from.Channel, from.ChannelUserID,
+			" c1.version 'c1.version', c1.knowledge 'c1.knowledge', c1.thread 'c1.thread', c1.ctime 'c1.ctime', c1.mtime 'c1.mtime', c1.deleted 'c1.deleted', c2.id 'c2.id', c2.chat_id 'c2.chat_id', "+
+			" c2.prompt 'c2.prompt', c2.completion 'c2.completion', c2.channel_message_id 'c2.channel_message_id', c2.tool_calls 'c2.tool_calls', c2.model 'c2.model', c2.prompt_tokens 'c2.prompt_tokens', c2.completion_tokens 'c2.completion_tokens', c2.ctime 'c2.ctime', c2.mtime 'c2.mtime' "+
+			" FROM chat AS c1 LEFT JOIN conversation AS c2 ON c1.id=c2.chat_id WHERE c1.channel=? AND c1.channel_user_id=? AND c1.thread=? AND c1.deleted=0 ORDER BY c2.id",
+		from.Channel, from.ChannelUserID, thread,
 	)
 	if err != nil {
 		return nil, err
@@ -55,8 +60,8 @@ func (repo *repository) GetByChatID(ctx context.Context, cid string) (*domain.Ch
 	var data []record
 	err := repo.db.SelectContext(ctx, &data,
 		"SELECT c1.id 'c1.id', c1.counts 'c1.counts', c1.current 'c1.current', c1.channel 'c1.channel', c1.channel_user_id 'c1.channel_user_id',"+
-			" c1.version 'c1.version', c1.ctime 'c1.ctime', c1.mtime 'c1.mtime', c1.deleted 'c1.deleted', c2.id 'c2.id', c2.chat_id 'c2.chat_id', "+
-			" c2.prompt 'c2.prompt', c2.completion 'c2.completion', c2.channel_message_id 'c2.channel_message_id', c2.ctime 'c2.ctime', c2.mtime 'c2.mtime' "+
+			" c1.version 'c1.version', c1.knowledge 'c1.knowledge', c1.thread 'c1.thread', c1.ctime 'c1.ctime', c1.mtime 'c1.mtime', c1.deleted 'c1.deleted', c2.id 'c2.id', c2.chat_id 'c2.chat_id', "+
+			" c2.prompt 'c2.prompt', c2.completion 'c2.completion', c2.channel_message_id 'c2.channel_message_id', c2.tool_calls 'c2.tool_calls', c2.model 'c2.model', c2.prompt_tokens 'c2.prompt_tokens', c2.completion_tokens 'c2.completion_tokens', c2.ctime 'c2.ctime', c2.mtime 'c2.mtime' "+
 			" FROM chat AS c1 LEFT JOIN conversation AS c2 ON c1.id=c2.chat_id WHERE c1.id=? ORDER BY c2.id",
 		cid,
 	)
@@ -86,9 +91,10 @@ func (repo *repository) Save(ctx context.Context, chat *domain.Chat) error {
 		if err != nil {
 			return err
 		}
-		ret, err := tx.NamedExec("INSERT INTO chat (id, counts, current, channel, channel_user_id, version)  SELECT * FROM ( "+
-			"SELECT :id as id, :counts as counts, :current as current, :channel as channel, :channel_user_id as channel_user_id, 1 as version) AS tmp "+
-			"WHERE NOT EXISTS(SELECT * FROM chat WHERE id<>:id AND channel_user_id=:channel_user_id AND channel=:channel AND deleted=0 LIMIT 1)",
+		// 已结束的会话(如无状态接口调用)不占用用户唯一的进行中会话
+		ret, err := tx.NamedExec("INSERT INTO chat (id, counts, current, channel, channel_user_id, version, knowledge, thread, deleted)  SELECT * FROM ( "+
+			"SELECT :id as id, :counts as counts, :current as current, :channel as channel, :channel_user_id as channel_user_id, 1 as version, :knowledge as knowledge, :thread as thread, :deleted as deleted) AS tmp "+
+			"WHERE :deleted=1 OR NOT EXISTS(SELECT * FROM chat WHERE id<>:id AND channel_user_id=:channel_user_id AND channel=:channel AND thread=:thread AND deleted=0 LIMIT 1)",
 			do,
 		)
 		if err != nil {
@@ -104,6 +110,10 @@ func (repo *repository) Save(ctx context.Context, chat *domain.Chat) error {
 			_ = tx.Rollback()
 			return errors.New("duplicated chat")
 		}
+		if err = insertLastConversation(tx, do.ID, chat); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
 		if err := tx.Commit(); err != nil {
 			_ = tx.Rollback()
 			return err
@@ -122,7 +132,7 @@ func (repo *repository) Save(ctx context.Context, chat *domain.Chat) error {
 		return err
 	}
 
-	ret, err := tx.Exec("UPDATE chat SET counts=?, current=?, version=version+1, deleted=? WHERE id=? AND deleted=0", do.Counts, do.Current, do.Deleted, do.ID)
+	ret, err := tx.Exec("UPDATE chat SET counts=?, current=?, knowledge=?, version=version+1, deleted=? WHERE id=? AND deleted=0", do.Counts, do.Current, do.Knowledge, do.Deleted, do.ID)
 	if err != nil {
 		_ = tx.Rollback()
 		return err
@@ -136,15 +146,9 @@ func (repo *repository) Save(ctx context.Context, chat *domain.Chat) error {
 		return errors.New("data outdated")
 	}
 
-	if chat.Current == nil && len(chat.Conversations) > 0 { // conversation完成，则insert最后一条
-		lastConversation := chat.Conversations[len(chat.Conversations)-1]
-		_, err = tx.Exec("INSERT INTO conversation (chat_id, prompt, completion, channel_message_id) SELECT * FROM "+
-			"(SELECT ? AS chat_id, ? AS prompt, ? AS completion, ? AS channel_message_id) AS tmp WHERE (SELECT COUNT(id) FROM conversation WHERE chat_id=?) < ?",
-			do.ID, lastConversation.Prompt, lastConversation.Completion, lastConversation.MessageID, do.ID, len(chat.Conversations))
-		if err != nil {
-			_ = tx.Rollback()
-			return err
-		}
+	if err = insertLastConversation(tx, do.ID, chat); err != nil {
+		_ = tx.Rollback()
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -153,3 +157,47 @@ func (repo *repository) Save(ctx context.Context, chat *domain.Chat) error {
 	}
 	return nil
 }
+
+// insertLastConversation conversation完成，则insert最后一条
+func insertLastConversation(tx *sqlx.Tx, cid string, chat *domain.Chat) error {
+	if chat.Current != nil || len(chat.Conversations) == 0 {
+		return nil
+	}
+	lastConversation := chat.Conversations[len(chat.Conversations)-1]
+	toolCalls, err := convertToolCalls(lastConversation.ToolCalls)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO conversation (chat_id, prompt, completion, channel_message_id, tool_calls, model, prompt_tokens, completion_tokens) SELECT * FROM "+
+		"(SELECT ? AS chat_id, ? AS prompt, ? AS completion, ? AS channel_message_id, ? AS tool_calls, ? AS model, ? AS prompt_tokens, ? AS completion_tokens) AS tmp "+
+		"WHERE (SELECT COUNT(id) FROM conversation WHERE chat_id=?) < ?",
+		cid, lastConversation.Prompt, lastConversation.Completion, lastConversation.MessageID, toolCalls,
+		lastConversation.Model, lastConversation.PromptTokens, lastConversation.CompletionTokens, cid, len(chat.Conversations))
+	return err
+}
+
+func (repo *repository) SearchConversations(ctx context.Context, from domain.From, keyword string, limit int) ([]*domain.Conversation, error) {
+	var data []*Conversation
+	pattern := "%" + escapeLike(keyword) + "%"
+	err := repo.db.SelectContext(ctx, &data,
+		"SELECT c2.id, c2.chat_id, c2.prompt, c2.completion, c2.channel_message_id, c2.tool_calls, c2.model, c2.prompt_tokens, c2.completion_tokens, c2.ctime, c2.mtime "+
+			"FROM conversation AS c2 INNER JOIN chat AS c1 ON c1.id=c2.chat_id WHERE c1.channel=? AND c1.channel_user_id=? "+
+			"AND (c2.prompt LIKE ? OR c2.completion LIKE ?) ORDER BY c2.id DESC LIMIT ?",
+		from.Channel, from.ChannelUserID, pattern, pattern, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	convs := make([]*domain.Conversation, len(data))
+	for index, do := range data {
+		if convs[index], err = ConvertConversationDO(do); err != nil {
+			return nil, err
+		}
+	}
+	return convs, nil
+}
+
+// escapeLike 转义LIKE中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
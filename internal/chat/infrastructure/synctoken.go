@@ -0,0 +1,36 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type syncTokenRepository struct {
+	db *sqlx.DB
+}
+
+var _ domain.SyncTokenRepository = (*syncTokenRepository)(nil)
+
+func NewSyncTokenRepository(db *sqlx.DB) domain.SyncTokenRepository {
+	return &syncTokenRepository{db: db}
+}
+
+// Get 没有保存过同步位置时返回空字符串
+func (repo *syncTokenRepository) Get(ctx context.Context, key string) (string, error) {
+	var token string
+	err := repo.db.GetContext(ctx, &token, "SELECT token FROM sync_token WHERE sync_key=?", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return token, err
+}
+
+func (repo *syncTokenRepository) Save(ctx context.Context, key, token string) error {
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO sync_token (sync_key, token) VALUES (?, ?) ON DUPLICATE KEY UPDATE token=VALUES(token)", key, token)
+	return err
+}
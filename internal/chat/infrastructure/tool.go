@@ -0,0 +1,392 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/safehttp"
+)
+
+type (
+	currentTimeTool struct{}
+
+	calculatorTool struct{}
+
+	httpFetchTool struct {
+		client       *http.Client
+		allowedHosts map[string]struct{}
+	}
+
+	historySearchTool struct {
+		repo domain.Repository
+	}
+
+	// calculator 四则运算表达式的递归下降解析器
+	calculator struct {
+		input []rune
+		pos   int
+	}
+)
+
+const (
+	maxFetchBytes      = 16 << 10
+	maxHistoryResults  = 5
+	defaultHTTPTimeout = 10 * time.Second
+)
+
+var (
+	_ domain.Tool = (*currentTimeTool)(nil)
+	_ domain.Tool = (*calculatorTool)(nil)
+	_ domain.Tool = (*httpFetchTool)(nil)
+	_ domain.Tool = (*historySearchTool)(nil)
+)
+
+func NewCurrentTimeTool() domain.Tool {
+	return &currentTimeTool{}
+}
+
+func (t *currentTimeTool) Name() string {
+	return "current_time"
+}
+
+func (t *currentTimeTool) Description() string {
+	return "Get the current date and time, optionally in the given IANA time zone."
+}
+
+func (t *currentTimeTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string","description":"IANA time zone, e.g. Asia/Shanghai"}}}`)
+}
+
+func (t *currentTimeTool) Call(_ context.Context, _ *domain.Chat, arguments string) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := unmarshalArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if args.Timezone != "" {
+		loc, err := time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", err
+		}
+		now = now.In(loc)
+	}
+	return now.Format(time.RFC3339), nil
+}
+
+func NewCalculatorTool() domain.Tool {
+	return &calculatorTool{}
+}
+
+func (t *calculatorTool) Name() string {
+	return "calculator"
+}
+
+func (t *calculatorTool) Description() string {
+	return "Evaluate an arithmetic expression supporting + - * / % ^ and parentheses."
+}
+
+func (t *calculatorTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"expression":{"type":"string","description":"arithmetic expression, e.g. (1+2)*3"}},"required":["expression"]}`)
+}
+
+func (t *calculatorTool) Call(_ context.Context, _ *domain.Chat, arguments string) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := unmarshalArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	result, err := evaluate(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+// NewHTTPFetchTool 仅允许访问白名单中的公网地址，重定向的每一跳同样校验白名单
+func NewHTTPFetchTool(allowedHosts []string) domain.Tool {
+	hosts := make(map[string]struct{}, len(allowedHosts))
+	for _, host := range allowedHosts {
+		hosts[strings.ToLower(host)] = struct{}{}
+	}
+	tool := &httpFetchTool{
+		client:       safehttp.NewClient(safehttp.Option{Timeout: defaultHTTPTimeout}),
+		allowedHosts: hosts,
+	}
+	checkRedirect := tool.client.CheckRedirect
+	tool.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := checkRedirect(req, via); err != nil {
+			return err
+		}
+		return tool.checkURL(req.URL)
+	}
+	return tool
+}
+
+func (t *httpFetchTool) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if _, ok := t.allowedHosts[strings.ToLower(u.Hostname())]; !ok {
+		return fmt.Errorf("host %s is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+func (t *httpFetchTool) Name() string {
+	return "http_fetch"
+}
+
+func (t *httpFetchTool) Description() string {
+	return "Fetch the content of a URL with HTTP GET. Only allow-listed hosts can be accessed."
+}
+
+func (t *httpFetchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"absolute http or https URL"}},"required":["url"]}`)
+}
+
+func (t *httpFetchTool) Call(ctx context.Context, _ *domain.Chat, arguments string) (string, error) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := unmarshalArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return "", err
+	}
+	if err = t.checkURL(u); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("status: %d\n\n%s", resp.StatusCode, body), nil
+}
+
+func NewHistorySearchTool(repo domain.Repository) domain.Tool {
+	return &historySearchTool{repo: repo}
+}
+
+func (t *historySearchTool) Name() string {
+	return "search_chat_history"
+}
+
+func (t *historySearchTool) Description() string {
+	return "Search the current user's previous conversations by keyword."
+}
+
+func (t *historySearchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"keyword":{"type":"string"}},"required":["keyword"]}`)
+}
+
+func (t *historySearchTool) Call(ctx context.Context, chat *domain.Chat, arguments string) (string, error) {
+	var args struct {
+		Keyword string `json:"keyword"`
+	}
+	if err := unmarshalArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if args.Keyword == "" {
+		return "", errors.New("empty keyword")
+	}
+	convs, err := t.repo.SearchConversations(ctx, chat.From, args.Keyword, maxHistoryResults)
+	if err != nil {
+		return "", err
+	}
+	type result struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	}
+	results := make([]result, len(convs))
+	for index, conv := range convs {
+		results[index] = result{Prompt: conv.Prompt, Completion: conv.Completion}
+	}
+	data, err := json.Marshal(results)
+	return string(data), err
+}
+
+func unmarshalArguments(arguments string, v any) error {
+	if strings.TrimSpace(arguments) == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(arguments), v)
+}
+
+// evaluate 计算四则运算表达式
+func evaluate(expression string) (float64, error) {
+	c := &calculator{input: []rune(expression)}
+	result, err := c.expression()
+	if err != nil {
+		return 0, err
+	}
+	c.skipSpaces()
+	if c.pos < len(c.input) {
+		return 0, fmt.Errorf("unexpected character %q at %d", c.input[c.pos], c.pos)
+	}
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return 0, errors.New("invalid result")
+	}
+	return result, nil
+}
+
+func (c *calculator) skipSpaces() {
+	for c.pos < len(c.input) && unicode.IsSpace(c.input[c.pos]) {
+		c.pos++
+	}
+}
+
+func (c *calculator) peek() rune {
+	c.skipSpaces()
+	if c.pos >= len(c.input) {
+		return 0
+	}
+	return c.input[c.pos]
+}
+
+// expression = term { ("+" | "-") term }
+func (c *calculator) expression() (float64, error) {
+	left, err := c.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch c.peek() {
+		case '+', '-':
+			op := c.input[c.pos]
+			c.pos++
+			right, err := c.term()
+			if err != nil {
+				return 0, err
+			}
+			if op == '+' {
+				left += right
+			} else {
+				left -= right
+			}
+		default:
+			return left, nil
+		}
+	}
+}
+
+// term = unary { ("*" | "/" | "%") unary }
+func (c *calculator) term() (float64, error) {
+	left, err := c.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch c.peek() {
+		case '*', '/', '%':
+			op := c.input[c.pos]
+			c.pos++
+			right, err := c.unary()
+			if err != nil {
+				return 0, err
+			}
+			switch op {
+			case '*':
+				left *= right
+			case '/':
+				if right == 0 {
+					return 0, errors.New("division by zero")
+				}
+				left /= right
+			case '%':
+				if right == 0 {
+					return 0, errors.New("division by zero")
+				}
+				left = math.Mod(left, right)
+			}
+		default:
+			return left, nil
+		}
+	}
+}
+
+// unary = ("-" | "+") unary | power
+func (c *calculator) unary() (float64, error) {
+	switch c.peek() {
+	case '-':
+		c.pos++
+		v, err := c.unary()
+		return -v, err
+	case '+':
+		c.pos++
+		return c.unary()
+	}
+	return c.power()
+}
+
+// power = primary [ "^" unary ]
+func (c *calculator) power() (float64, error) {
+	base, err := c.primary()
+	if err != nil {
+		return 0, err
+	}
+	if c.peek() == '^' {
+		c.pos++
+		exp, err := c.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exp), nil
+	}
+	return base, nil
+}
+
+// primary = number | "(" expression ")"
+func (c *calculator) primary() (float64, error) {
+	switch r := c.peek(); {
+	case r == '(':
+		c.pos++
+		v, err := c.expression()
+		if err != nil {
+			return 0, err
+		}
+		if c.peek() != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		c.pos++
+		return v, nil
+
+	case unicode.IsDigit(r) || r == '.':
+		start := c.pos
+		for c.pos < len(c.input) && (unicode.IsDigit(c.input[c.pos]) || c.input[c.pos] == '.') {
+			c.pos++
+		}
+		return strconv.ParseFloat(string(c.input[start:c.pos]), 64)
+
+	case r == 0:
+		return 0, errors.New("unexpected end of expression")
+
+	default:
+		return 0, fmt.Errorf("unexpected character %q at %d", r, c.pos)
+	}
+}
@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := map[string]float64{
+		"1+2*3":        7,
+		"(1+2)*3":      9,
+		"-2^2":         -4,
+		"2^3^2":        512,
+		"10 % 4 / 2":   1,
+		" 1.5 + .5 ":   2,
+		"-(3 - 5) * 2": 4,
+	}
+	for expr, expected := range cases {
+		v, err := evaluate(expr)
+		assert.NoError(t, err, expr)
+		assert.InDelta(t, expected, v, 1e-9, expr)
+	}
+
+	for _, expr := range []string{"", "1/0", "(1+2", "1+a", "2 3"} {
+		_, err := evaluate(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestHTTPFetchToolAllowList(t *testing.T) {
+	tool := NewHTTPFetchTool([]string{"example.com"})
+	_, err := tool.Call(context.Background(), nil, `{"url":"http://127.0.0.1/secret"}`)
+	assert.ErrorContains(t, err, "not allowed")
+	_, err = tool.Call(context.Background(), nil, `{"url":"file:///etc/passwd"}`)
+	assert.Error(t, err)
+
+	// 白名单中的域名重定向到其他域名时拒绝跟随
+	fetch := tool.(*httpFetchTool)
+	req, _ := http.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data", nil)
+	via, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	assert.ErrorContains(t, fetch.client.CheckRedirect(req, []*http.Request{via}), "not allowed")
+}
@@ -0,0 +1,67 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/sashabaranov/go-openai"
+)
+
+type whisperTranscriber struct {
+	client *openai.Client
+	ffmpeg string
+}
+
+var _ domain.Transcriber = (*whisperTranscriber)(nil)
+
+// Whisper接口直接支持的音频格式
+var whisperFormats = map[string]struct{}{
+	"mp3": {}, "mp4": {}, "mpeg": {}, "mpga": {}, "m4a": {}, "wav": {}, "webm": {}, "ogg": {}, "flac": {},
+}
+
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// NewWhisperTranscriber ffmpeg为可执行文件路径，用于将amr等Whisper不支持的格式转为mp3，为空时不转换
+func NewWhisperTranscriber(client *openai.Client, ffmpeg string) domain.Transcriber {
+	return &whisperTranscriber{client: client, ffmpeg: ffmpeg}
+}
+
+func (wt *whisperTranscriber) Transcribe(ctx context.Context, format string, audio []byte) (string, error) {
+	format = strings.ToLower(format)
+	if _, ok := whisperFormats[format]; !ok {
+		if wt.ffmpeg == "" {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedAudio, format)
+		}
+		converted, err := wt.convert(ctx, format, audio)
+		if err != nil {
+			return "", err
+		}
+		format, audio = "mp3", converted
+	}
+	resp, err := wt.client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    openai.Whisper1,
+		FilePath: "voice." + format,
+		Reader:   bytes.NewReader(audio),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+func (wt *whisperTranscriber) convert(ctx context.Context, format string, audio []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, wt.ffmpeg, "-loglevel", "error", "-f", format, "-i", "pipe:0", "-f", "mp3", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audio)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert %s audio: %w: %s", format, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
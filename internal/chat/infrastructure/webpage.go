@@ -0,0 +1,147 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/safehttp"
+	"golang.org/x/net/html"
+)
+
+type webReader struct {
+	client   *http.Client
+	maxBytes int64
+	maxChars int
+}
+
+var _ domain.WebReader = (*webReader)(nil)
+
+// 这些标签下的文本通常不是正文
+var skippedElements = map[string]struct{}{
+	"script": {}, "style": {}, "noscript": {}, "template": {}, "svg": {},
+	"nav": {}, "header": {}, "footer": {}, "aside": {}, "form": {}, "iframe": {},
+}
+
+var blockElements = map[string]struct{}{
+	"p": {}, "div": {}, "br": {}, "li": {}, "tr": {}, "section": {}, "article": {},
+	"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {}, "pre": {}, "blockquote": {},
+}
+
+func NewWebReader(timeout time.Duration, maxBytes int64, maxChars int) domain.WebReader {
+	return &webReader{
+		client:   safehttp.NewClient(safehttp.Option{Timeout: timeout}),
+		maxBytes: maxBytes,
+		maxChars: maxChars,
+	}
+}
+
+func (wr *webReader) Read(ctx context.Context, link string) (*domain.Document, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "chatgpt-bot/1.0")
+	req.Header.Set("Accept", "text/html,text/plain;q=0.9")
+	resp, err := wr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := io.LimitReader(resp.Body, wr.maxBytes)
+	doc := &domain.Document{URL: link}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "":
+		doc.Title, doc.Content, err = extractReadableText(body)
+		if err != nil {
+			return nil, err
+		}
+	case "text/plain", "text/markdown":
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		doc.Content = strings.TrimSpace(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+	doc.Content = truncateRunes(doc.Content, wr.maxChars)
+	return doc, nil
+}
+
+// extractReadableText 提取html的标题与正文文本
+func extractReadableText(r io.Reader) (string, string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	var title string
+	var builder strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "title" && title == "" && n.FirstChild != nil {
+				title = strings.TrimSpace(n.FirstChild.Data)
+				return
+			}
+			if _, ok := skippedElements[n.Data]; ok {
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+				builder.WriteString(text)
+				builder.WriteString(" ")
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode {
+			if _, ok := blockElements[n.Data]; ok {
+				builder.WriteString("\n")
+			}
+		}
+	}
+	walk(root)
+
+	lines := strings.Split(builder.String(), "\n")
+	content := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			content = append(content, line)
+		}
+	}
+	return title, strings.Join(content, "\n"), nil
+}
+
+func truncateRunes(s string, max int) string {
+	if max <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacexh/chatgpt-bot/internal/pkg/safehttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractReadableText(t *testing.T) {
+	page := `<html><head><title> Runbook </title><style>p{}</style></head>
+<body><nav>menu</nav><h1>Restart</h1><p>Run   <b>make restart</b>.</p><script>alert(1)</script><footer>copyright</footer></body></html>`
+	title, content, err := extractReadableText(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "Runbook", title)
+	assert.Equal(t, "Restart\nRun make restart .", content)
+}
+
+func TestWebReaderRejectsPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+
+	reader := NewWebReader(time.Second, 1024, 100)
+	_, err := reader.Read(context.Background(), srv.URL)
+	assert.True(t, errors.Is(err, safehttp.ErrForbiddenAddress))
+}
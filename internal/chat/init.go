@@ -1,35 +1,632 @@
 package chat
 
 import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
 	"github.com/go-jimu/components/logger"
 	"github.com/go-jimu/components/mediator"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/discord"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/email"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/feishu"
 	"github.com/jacexh/chatgpt-bot/internal/bootstrap/httpsrv"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/matrix"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/slack"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/telegram"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wechat"
+	"github.com/jacexh/chatgpt-bot/internal/bootstrap/wecom"
 	"github.com/jacexh/chatgpt-bot/internal/chat/application"
 	"github.com/jacexh/chatgpt-bot/internal/chat/application/transport"
+	"github.com/jacexh/chatgpt-bot/internal/chat/domain"
 	"github.com/jacexh/chatgpt-bot/internal/chat/infrastructure"
+	pkgCtx "github.com/jacexh/chatgpt-bot/internal/pkg/context"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
+	"github.com/jacexh/chatgpt-bot/internal/pkg/safehttp"
 	"github.com/jmoiron/sqlx"
 	"github.com/sashabaranov/go-openai"
 	"github.com/silenceper/wechat/v2/officialaccount"
 )
 
+type (
+	Option struct {
+		Knowledge  KnowledgeOption  `json:"knowledge" yaml:"knowledge"`
+		Tools      ToolsOption      `json:"tools" yaml:"tools"`
+		WebReader  WebReaderOption  `json:"web_reader" yaml:"web_reader"`
+		Moderation ModerationOption `json:"moderation" yaml:"moderation"`
+		Access     AccessOption     `json:"access" yaml:"access"`
+		Quota      QuotaOption      `json:"quota" yaml:"quota"`
+		Pricing    []PriceOption    `json:"pricing" yaml:"pricing"`
+		RateLimit  RateLimitOption  `json:"rate_limit" yaml:"rate_limit"`
+		// Admins 各通道管理员的用户ID，key为通道名称
+		Admins    map[string][]string `json:"admins" yaml:"admins"`
+		Broadcast BroadcastOption     `json:"broadcast" yaml:"broadcast"`
+		AdminAPI  AdminAPIOption      `json:"admin_api" yaml:"admin_api"`
+		Web       WebOption           `json:"web" yaml:"web"`
+		Gateway   GatewayOption       `json:"gateway" yaml:"gateway"`
+		REST      RESTOption          `json:"rest" yaml:"rest"`
+		Feishu    FeishuOption        `json:"feishu" yaml:"feishu"`
+		Voice     VoiceOption         `json:"voice" yaml:"voice"`
+		Email     EmailOption         `json:"email" yaml:"email"`
+		Wechat    WechatOption        `json:"wechat" yaml:"wechat"`
+		I18n      I18nOption          `json:"i18n" yaml:"i18n"`
+	}
+
+	// I18nOption default_locale为用户未设置语言且客户端未上报语言时使用的语言
+	I18nOption struct {
+		DefaultLocale string `json:"default_locale" yaml:"default_locale"`
+	}
+
+	// WechatOption menu为true时启动时以内置命令覆盖公众号的自定义菜单
+	WechatOption struct {
+		Menu bool `json:"menu" yaml:"menu"`
+	}
+
+	// EmailOption 邮件通道的用户ID为发件人与线程的摘要，无法按用户配置权限，以allowed_senders限制发件人，
+	// 可以是完整地址或@domain，为空时拒绝所有邮件
+	EmailOption struct {
+		AllowedSenders []string `json:"allowed_senders" yaml:"allowed_senders"`
+	}
+
+	// VoiceOption 语音消息通过Whisper转写，ffmpeg用于转换amr等Whisper不支持的格式，为空时不转换
+	VoiceOption struct {
+		Enabled bool   `json:"enabled" yaml:"enabled"`
+		FFmpeg  string `json:"ffmpeg" yaml:"ffmpeg"`
+	}
+
+	// FeishuOption card为true时以消息卡片回复completion，可渲染markdown
+	FeishuOption struct {
+		Card bool `json:"card" yaml:"card"`
+	}
+
+	// RESTOption 通用REST通道，事件以secret签名后POST到调用方提交的callback_url，
+	// 失败时最多尝试max_attempts次
+	RESTOption struct {
+		Enabled     bool               `json:"enabled" yaml:"enabled"`
+		MaxAttempts int                `json:"max_attempts" yaml:"max_attempts"`
+		Clients     []RESTClientOption `json:"clients" yaml:"clients"`
+	}
+
+	// RESTClientOption 回调地址默认不允许为内网地址，callback_hosts中的主机名(或IP)不受此限制
+	RESTClientOption struct {
+		Name          string   `json:"name" yaml:"name"`
+		Key           string   `json:"key" yaml:"key"`
+		Secret        string   `json:"secret" yaml:"secret"`
+		CallbackHosts []string `json:"callback_hosts" yaml:"callback_hosts"`
+	}
+
+	// GatewayOption OpenAI兼容接口/v1/chat/completions，每个客户端使用独立的API key，
+	// 客户端名称作为api通道的用户ID参与权限、额度、限速及用量统计
+	GatewayOption struct {
+		Enabled bool                  `json:"enabled" yaml:"enabled"`
+		Clients []GatewayClientOption `json:"clients" yaml:"clients"`
+	}
+
+	GatewayClientOption struct {
+		Name string `json:"name" yaml:"name"`
+		Key  string `json:"key" yaml:"key"`
+	}
+
+	// WebOption 浏览器聊天通道，session_secret用于签名会话cookie，为空时每次启动随机生成
+	// WebOption trusted_header仅应在反向代理会覆盖该请求头时配置；未配置时为匿名会话，
+	// new_sessions限制同一IP签发新会话的速度
+	WebOption struct {
+		Enabled       bool                   `json:"enabled" yaml:"enabled"`
+		SessionSecret string                 `json:"session_secret" yaml:"session_secret"`
+		SessionTTL    string                 `json:"session_ttl" yaml:"session_ttl"`
+		TrustedHeader string                 `json:"trusted_header" yaml:"trusted_header"`
+		NewSessions   ChannelRateLimitOption `json:"new_sessions" yaml:"new_sessions"`
+	}
+
+	// AdminAPIOption 管理接口、报表接口及管理后台的API key，未配置时拒绝所有请求；
+	// 浏览器访问/admin/时以任意用户名及API key作为密码登录
+	AdminAPIOption struct {
+		Keys []string `json:"keys" yaml:"keys"`
+	}
+
+	// BroadcastOption 群发时各通道相邻两条消息的最小间隔，key为通道名称
+	BroadcastOption struct {
+		Intervals map[string]string `json:"intervals" yaml:"intervals"`
+	}
+
+	KnowledgeOption struct {
+		ChunkSize int `json:"chunk_size" yaml:"chunk_size"`
+		TopK      int `json:"top_k" yaml:"top_k"`
+	}
+
+	ToolsOption struct {
+		Enabled       bool     `json:"enabled" yaml:"enabled"`
+		MaxIterations int      `json:"max_iterations" yaml:"max_iterations"`
+		AllowedHosts  []string `json:"allowed_hosts" yaml:"allowed_hosts"`
+	}
+
+	WebReaderOption struct {
+		Enabled  bool   `json:"enabled" yaml:"enabled"`
+		MaxPages int    `json:"max_pages" yaml:"max_pages"`
+		MaxBytes int64  `json:"max_bytes" yaml:"max_bytes"`
+		MaxChars int    `json:"max_chars" yaml:"max_chars"`
+		Timeout  string `json:"timeout" yaml:"timeout"`
+	}
+
+	ModerationOption struct {
+		Enabled bool                            `json:"enabled" yaml:"enabled"`
+		OpenAI  bool                            `json:"openai" yaml:"openai"`
+		Rules   []infrastructure.ModerationRule `json:"rules" yaml:"rules"`
+	}
+
+	AccessOption struct {
+		Enabled  bool                           `json:"enabled" yaml:"enabled"`
+		Channels map[string]ChannelAccessOption `json:"channels" yaml:"channels"`
+	}
+
+	// ChannelAccessOption 通道的默认策略(allow|deny)及静态名单
+	ChannelAccessOption struct {
+		Default string   `json:"default" yaml:"default"`
+		Allow   []string `json:"allow" yaml:"allow"`
+		Deny    []string `json:"deny" yaml:"deny"`
+	}
+
+	QuotaOption struct {
+		Enabled  bool                          `json:"enabled" yaml:"enabled"`
+		Channels map[string]ChannelQuotaOption `json:"channels" yaml:"channels"`
+	}
+
+	// ChannelQuotaOption 通道内单用户及通道整体的每日、每月token上限，0表示不限制
+	ChannelQuotaOption struct {
+		UserDaily      int64 `json:"user_daily" yaml:"user_daily"`
+		UserMonthly    int64 `json:"user_monthly" yaml:"user_monthly"`
+		ChannelDaily   int64 `json:"channel_daily" yaml:"channel_daily"`
+		ChannelMonthly int64 `json:"channel_monthly" yaml:"channel_monthly"`
+	}
+
+	// RateLimitOption backend为memory时仅在单实例内生效，多实例部署需使用mysql
+	RateLimitOption struct {
+		Enabled  bool                              `json:"enabled" yaml:"enabled"`
+		Backend  string                            `json:"backend" yaml:"backend"`
+		Channels map[string]ChannelRateLimitOption `json:"channels" yaml:"channels"`
+	}
+
+	// ChannelRateLimitOption 令牌桶容量及恢复一个令牌的间隔
+	ChannelRateLimitOption struct {
+		Burst  int    `json:"burst" yaml:"burst"`
+		Refill string `json:"refill" yaml:"refill"`
+	}
+
+	// PriceOption 模型每1K tokens的单价，model可以是前缀，例如gpt-3.5-turbo
+	PriceOption struct {
+		Model      string  `json:"model" yaml:"model"`
+		Prompt     float64 `json:"prompt" yaml:"prompt"`
+		Completion float64 `json:"completion" yaml:"completion"`
+	}
+)
+
 func Init(
+	opt Option,
 	log logger.Logger,
 	db *sqlx.DB,
 	http httpsrv.HTTPServer,
 	mediator mediator.Mediator,
 	bot *tgbotapi.BotAPI,
 	gpt *openai.Client,
-	wc *officialaccount.OfficialAccount) {
+	wc *officialaccount.OfficialAccount,
+	sc *slack.Client,
+	dc *discord.Client,
+	fc *feishu.Client,
+	wec *wecom.Client,
+	mc *matrix.Client,
+	ec *email.Client) {
 	repo := infrastructure.NewRepository(db)
-	gptSrv := infrastructure.NewChatGTPServer(gpt)
-	app := application.NewApplication(repo, mediator, gptSrv)
-	controller := transport.NewController(app, bot, wc)
+	localizer := newLocalizer(opt.I18n, infrastructure.NewLocaleRepository(db))
+	app, access := newApplication(opt, db, repo, mediator, gpt, localizer)
+	notifier := infrastructure.NewChannelNotifier(map[domain.Channel]domain.Notifier{
+		domain.ChannelTelegram: infrastructure.NewTelegramNotifier(bot),
+		domain.ChannelWechat:   infrastructure.NewWechatNotifier(wc),
+	})
+	adminRepo := infrastructure.NewAdminRepository(db)
+	broadcast := application.NewBroadcastApplication(infrastructure.NewBroadcastRepository(db), adminRepo, notifier, newBroadcastIntervals(opt.Broadcast))
+	admin := application.NewAdminApplication(app, newAdminList(opt.Admins), adminRepo, access, broadcast)
+	router := transport.NewDefaultCommandRouter(app, admin, localizer)
+	registerCommandMenus(log, opt.Wechat, router, localizer.Catalog(), bot, wc)
+	controller := transport.NewController(app, router, bot, wc)
 	http.With(controller)
+	http.With(transport.NewReportController(NewReportApplication(opt, db), opt.AdminAPI.Keys))
+	http.With(transport.NewAdminController(admin, opt.AdminAPI.Keys))
+	http.With(transport.NewConsoleController(opt.AdminAPI.Keys))
+
+	mediator.Subscribe(application.NewUsageEventHandler(log, infrastructure.NewQuotaRepository(db)))
+
+	handler := application.NewTelegramEventHandler(log, localizer, bot)
+	mediator.Subscribe(handler)
+
+	go broadcast.Resume(pkgCtx.RootContext(), log)
 
-	handler := application.NewTelegramEventHandler(log, bot)
+	handler = application.NewWechatEventHandler(log, localizer, wc)
 	mediator.Subscribe(handler)
 
-	handler = application.NewWechatEventHandler(log, wc)
+	// 未配置slack.bot_token时不启用Slack通道
+	if sc != nil {
+		http.With(transport.NewSlackController(app, router, sc))
+		mediator.Subscribe(application.NewSlackEventHandler(log, localizer, sc))
+	}
+
+	// 未配置discord.application_id时不启用Discord通道；斜杠命令仅影响展示，注册失败时记录错误并继续启动
+	if dc != nil {
+		ctx, cancel := pkgCtx.GenDefaultContext()
+		err := dc.RegisterCommands(ctx, transport.NewDiscordCommands(localizer.Catalog()))
+		cancel()
+		if err != nil {
+			logger.NewHelper(log).Error("failed to register discord commands", "error", err.Error())
+		}
+		interactions := application.NewDiscordInteractions()
+		http.With(transport.NewDiscordController(app, router, dc, interactions))
+		mediator.Subscribe(application.NewDiscordEventHandler(log, localizer, dc, interactions))
+	}
+
+	// 未配置feishu.app_id时不启用飞书通道
+	if fc != nil {
+		http.With(transport.NewFeishuController(app, router, fc))
+		mediator.Subscribe(application.NewFeishuEventHandler(log, localizer, fc, opt.Feishu.Card))
+	}
+
+	// 未配置wecom.corp_id时不启用企业微信通道
+	if wec != nil {
+		http.With(transport.NewWecomController(app, router, wec))
+		mediator.Subscribe(application.NewWecomEventHandler(log, localizer, wec))
+	}
+
+	// 未配置matrix.homeserver_url时不启用Matrix通道
+	if mc != nil {
+		replies := application.NewMatrixReplies()
+		worker := transport.NewMatrixWorker(log, app, router, mc, replies, infrastructure.NewSyncTokenRepository(db))
+		pkgCtx.Go(worker.Run)
+		mediator.Subscribe(application.NewMatrixEventHandler(log, localizer, mc, replies))
+	}
+
+	// 未配置email.imap_addr时不启用邮件通道
+	if ec != nil {
+		if len(opt.Email.AllowedSenders) == 0 {
+			logger.NewHelper(log).Warn("email.allowed_senders is empty, all incoming emails will be ignored")
+		}
+		replies := application.NewEmailReplies()
+		pkgCtx.Go(transport.NewEmailWorker(log, app, router, ec, replies, opt.Email.AllowedSenders).Run)
+		mediator.Subscribe(application.NewEmailEventHandler(log, localizer, ec, replies))
+	}
+
+	if opt.Web.Enabled {
+		hub := application.NewWebHub()
+		http.With(newWebController(opt.Web, app, router, hub))
+		mediator.Subscribe(application.NewWebEventHandler(log, localizer, hub))
+	}
+
+	if opt.Gateway.Enabled {
+		http.With(transport.NewGatewayController(app, newGatewayClients(opt.Gateway)))
+	}
+
+	if opt.REST.Enabled {
+		clients, secrets := newRESTClients(opt.REST)
+		senders := make(map[string]domain.CallbackSender, len(opt.REST.Clients))
+		for _, client := range opt.REST.Clients {
+			senders[client.Name] = infrastructure.NewCallbackSender(
+				safehttp.NewClient(safehttp.Option{Timeout: 10 * time.Second, AllowedHosts: client.CallbackHosts}))
+		}
+		callback := application.NewCallbackApplication(infrastructure.NewCallbackRepository(db), senders, secrets, opt.REST.MaxAttempts)
+		callback.Resume(pkgCtx.RootContext(), log)
+		http.With(transport.NewRESTController(app, router, callback, clients))
+		mediator.Subscribe(application.NewCallbackEventHandler(log, callback))
+	}
+}
+
+// newApplication db为nil时使用进程内仓储，不启用依赖MySQL的知识库、访问控制、封禁及额度
+func newApplication(opt Option, db *sqlx.DB, repo domain.Repository, mediator mediator.Mediator, gpt *openai.Client,
+	localizer *application.Localizer) (*application.Application, *application.AccessApplication) {
+	srvOpts := []infrastructure.ServiceOption{infrastructure.WithCatalog(localizer.Catalog())}
+	if db != nil {
+		kr := infrastructure.NewKnowledgeRepository(db)
+		es := infrastructure.NewEmbeddingService(gpt)
+		srvOpts = append(srvOpts, infrastructure.WithKnowledge(kr, es, opt.Knowledge.TopK))
+	}
+	if opt.Tools.Enabled {
+		srvOpts = append(srvOpts, infrastructure.WithTools(newToolRegistry(opt.Tools, repo), opt.Tools.MaxIterations))
+	}
+	appOpts := []application.Option{application.WithLocalizer(localizer)}
+	if opt.Moderation.Enabled {
+		moderator := newModerator(opt.Moderation, gpt)
+		srvOpts = append(srvOpts, infrastructure.WithModerator(moderator))
+		appOpts = append(appOpts, application.WithModerator(moderator))
+	}
+	var access *application.AccessApplication
+	if db != nil {
+		if opt.Access.Enabled {
+			access = NewAccessApplication(opt, db)
+			appOpts = append(appOpts, application.WithAccessControl(access))
+		} else {
+			// 管理员的封禁始终生效，与是否启用访问控制无关
+			access = application.NewAccessApplication(infrastructure.NewAccessRepository(db), domain.NewAccessPolicy())
+			appOpts = append(appOpts, application.WithBlocklist(access))
+		}
+	}
+	if opt.Quota.Enabled && db != nil {
+		appOpts = append(appOpts, application.WithQuota(NewQuotaApplication(opt, db)))
+	}
+	if opt.RateLimit.Enabled {
+		if db == nil {
+			opt.RateLimit.Backend = "memory"
+		}
+		appOpts = append(appOpts, application.WithRateLimiter(newRateLimiter(opt.RateLimit, db)))
+	}
+	if opt.Voice.Enabled {
+		appOpts = append(appOpts, application.WithTranscriber(infrastructure.NewWhisperTranscriber(gpt, opt.Voice.FFmpeg)))
+	}
+	gptSrv := infrastructure.NewChatGTPServer(gpt, srvOpts...)
+	if opt.WebReader.Enabled {
+		timeout, err := time.ParseDuration(opt.WebReader.Timeout)
+		if err != nil {
+			panic(err)
+		}
+		reader := infrastructure.NewWebReader(timeout, opt.WebReader.MaxBytes, opt.WebReader.MaxChars)
+		appOpts = append(appOpts, application.WithWebReader(reader, opt.WebReader.MaxPages))
+	}
+	return application.NewApplication(repo, mediator, gptSrv, appOpts...), access
+}
+
+// NewCLI 终端交互通道，db为nil时使用进程内仓储，无需MySQL；lang为终端的语言，例如LANG环境变量
+func NewCLI(opt Option, log logger.Logger, db *sqlx.DB, mediator mediator.Mediator, gpt *openai.Client, user, lang string, in io.Reader, out io.Writer) *transport.CLI {
+	var repo domain.Repository
+	var locales domain.LocaleRepository
+	if db == nil {
+		repo = infrastructure.NewMemoryRepository()
+		locales = infrastructure.NewMemoryLocaleRepository()
+	} else {
+		repo = infrastructure.NewRepository(db)
+		locales = infrastructure.NewLocaleRepository(db)
+	}
+	localizer := newLocalizer(opt.I18n, locales)
+	app, _ := newApplication(opt, db, repo, mediator, gpt, localizer)
+	handler := application.NewCLIEventHandler(log, localizer, out)
 	mediator.Subscribe(handler)
+	return transport.NewCLI(log, app, transport.NewDefaultCommandRouter(app, nil, localizer), handler, user, lang, in, out)
+}
+
+func newLocalizer(opt I18nOption, repo domain.LocaleRepository) *application.Localizer {
+	catalog, err := i18n.NewCatalog(opt.DefaultLocale)
+	if err != nil {
+		panic(err)
+	}
+	return application.NewLocalizer(catalog, repo)
+}
+
+// registerCommandMenus 将内置命令同步到Telegram的命令菜单，以及可选的公众号自定义菜单。
+// Telegram按客户端语言展示对应的翻译，其他语言的用户及公众号菜单使用缺省语言。菜单仅影响展示，注册失败时记录错误并继续启动
+func registerCommandMenus(log logger.Logger, opt WechatOption, router *transport.CommandRouter, catalog *i18n.Catalog, bot *tgbotapi.BotAPI, wc *officialaccount.OfficialAccount) {
+	tgCommands := func(locale string) []telegram.Command {
+		var commands []telegram.Command
+		for _, cmd := range router.Commands(false) {
+			commands = append(commands, telegram.Command{Name: cmd.Name, Description: router.T(locale, cmd.Description)})
+		}
+		return commands
+	}
+	helper := logger.NewHelper(log)
+	if err := telegram.SetCommands(bot, "", tgCommands(catalog.Fallback())); err != nil {
+		helper.Error("failed to set telegram commands", "error", err.Error())
+	}
+	for _, locale := range catalog.Locales() {
+		if err := telegram.SetCommands(bot, locale, tgCommands(locale)); err != nil {
+			helper.Error("failed to set telegram commands", "locale", locale, "error", err.Error())
+		}
+	}
+
+	if opt.Menu {
+		var menuCommands []wechat.MenuCommand
+		for _, cmd := range router.Commands(false) {
+			if !cmd.RequiresArgs() {
+				menuCommands = append(menuCommands, wechat.MenuCommand{Name: router.T(catalog.Fallback(), cmd.Description), Key: "/" + cmd.Name})
+			}
+		}
+		if err := wechat.SetCommandMenu(wc, router.T(catalog.Fallback(), "wechat.menu_title"), menuCommands); err != nil {
+			helper.Error("failed to set wechat command menu", "error", err.Error())
+		}
+	}
+}
+
+func newToolRegistry(opt ToolsOption, repo domain.Repository) *domain.ToolRegistry {
+	registry := domain.NewToolRegistry()
+	tools := []domain.Tool{
+		infrastructure.NewCurrentTimeTool(),
+		infrastructure.NewCalculatorTool(),
+		infrastructure.NewHistorySearchTool(repo),
+	}
+	if len(opt.AllowedHosts) > 0 {
+		tools = append(tools, infrastructure.NewHTTPFetchTool(opt.AllowedHosts))
+	}
+	for _, tool := range tools {
+		if err := registry.Register(tool); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+func newModerator(opt ModerationOption, gpt *openai.Client) domain.Moderator {
+	keyword, err := infrastructure.NewKeywordModerator(opt.Rules)
+	if err != nil {
+		panic(err)
+	}
+	moderators := []domain.Moderator{keyword}
+	if opt.OpenAI {
+		moderators = append(moderators, infrastructure.NewOpenAIModerator(gpt))
+	}
+	return infrastructure.NewChainModerator(moderators...)
+}
+
+func NewKnowledgeApplication(opt Option, db *sqlx.DB, gpt *openai.Client) *application.KnowledgeApplication {
+	kr := infrastructure.NewKnowledgeRepository(db)
+	es := infrastructure.NewEmbeddingService(gpt)
+	return application.NewKnowledgeApplication(kr, es, opt.Knowledge.ChunkSize)
+}
+
+func NewAccessApplication(opt Option, db *sqlx.DB) *application.AccessApplication {
+	return application.NewAccessApplication(infrastructure.NewAccessRepository(db), newAccessPolicy(opt.Access))
+}
+
+func newAccessPolicy(opt AccessOption) *domain.AccessPolicy {
+	policy := domain.NewAccessPolicy()
+	for name, co := range opt.Channels {
+		channel, err := domain.ParseChannel(name)
+		if err != nil {
+			panic(err)
+		}
+		switch co.Default {
+		case "", "allow":
+			policy.SetDefault(channel, domain.AccessAllowed)
+		case "deny":
+			policy.SetDefault(channel, domain.AccessDenied)
+		default:
+			panic(fmt.Sprintf("unknown access policy %q of channel %s", co.Default, name))
+		}
+		for _, uid := range co.Allow {
+			policy.Allow(domain.From{Channel: channel, ChannelUserID: domain.ChannelUserID(uid)})
+		}
+		for _, uid := range co.Deny {
+			policy.Deny(domain.From{Channel: channel, ChannelUserID: domain.ChannelUserID(uid)})
+		}
+	}
+	return policy
+}
+
+func NewQuotaApplication(opt Option, db *sqlx.DB) *application.QuotaApplication {
+	policy := domain.NewQuotaPolicy()
+	for name, co := range opt.Quota.Channels {
+		channel, err := domain.ParseChannel(name)
+		if err != nil {
+			panic(err)
+		}
+		policy.SetUserLimit(channel, domain.QuotaLimit{Daily: co.UserDaily, Monthly: co.UserMonthly})
+		policy.SetChannelLimit(channel, domain.QuotaLimit{Daily: co.ChannelDaily, Monthly: co.ChannelMonthly})
+	}
+	return application.NewQuotaApplication(infrastructure.NewQuotaRepository(db), policy)
+}
+
+func NewReportApplication(opt Option, db *sqlx.DB) *application.ReportApplication {
+	prices := make(domain.PriceTable, len(opt.Pricing))
+	for _, po := range opt.Pricing {
+		prices[po.Model] = domain.Price{Prompt: po.Prompt, Completion: po.Completion}
+	}
+	return application.NewReportApplication(infrastructure.NewReportRepository(db), prices)
+}
+
+func newWebController(opt WebOption, app *application.Application, router *transport.CommandRouter, hub *application.WebHub) httpsrv.Controller {
+	ttl, err := time.ParseDuration(opt.SessionTTL)
+	if err != nil {
+		panic(err)
+	}
+	if ttl <= 0 && opt.TrustedHeader == "" {
+		panic(fmt.Sprintf("web session_ttl must be positive, got %q", opt.SessionTTL))
+	}
+	secret := []byte(opt.SessionSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	session := transport.WebSession{Secret: secret, TTL: ttl, TrustedHeader: opt.TrustedHeader}
+	if opt.TrustedHeader == "" && opt.NewSessions.Burst > 0 {
+		refill, err := time.ParseDuration(opt.NewSessions.Refill)
+		if err != nil {
+			panic(err)
+		}
+		session.Limiter = infrastructure.NewMemoryRateLimiter()
+		session.NewSessions = domain.RateLimit{Burst: opt.NewSessions.Burst, Refill: refill}
+	}
+	return transport.NewWebController(app, router, hub, session)
+}
+
+func newGatewayClients(opt GatewayOption) map[string]string {
+	clients := make(map[string]string, len(opt.Clients))
+	for _, client := range opt.Clients {
+		if client.Name == "" || client.Key == "" {
+			panic(fmt.Errorf("gateway client requires both name and key"))
+		}
+		if _, ok := clients[client.Key]; ok {
+			panic(fmt.Errorf("duplicated gateway key for client %s", client.Name))
+		}
+		clients[client.Key] = client.Name
+	}
+	return clients
+}
+
+// newRESTClients 返回API key到客户端名称、客户端名称到签名密钥的映射
+func newRESTClients(opt RESTOption) (map[string]string, map[string][]byte) {
+	clients := make(map[string]string, len(opt.Clients))
+	secrets := make(map[string][]byte, len(opt.Clients))
+	for _, client := range opt.Clients {
+		if client.Name == "" || client.Key == "" || client.Secret == "" {
+			panic(fmt.Errorf("rest client requires name, key and secret"))
+		}
+		if err := domain.ValidateRESTClient(client.Name); err != nil {
+			panic(err)
+		}
+		if _, ok := clients[client.Key]; ok {
+			panic(fmt.Errorf("duplicated rest key for client %s", client.Name))
+		}
+		clients[client.Key] = client.Name
+		secrets[client.Name] = []byte(client.Secret)
+	}
+	return clients, secrets
+}
+
+func newAdminList(admins map[string][]string) *domain.AdminList {
+	list := domain.NewAdminList()
+	for name, uids := range admins {
+		channel, err := domain.ParseChannel(name)
+		if err != nil {
+			panic(err)
+		}
+		for _, uid := range uids {
+			list.Add(domain.From{Channel: channel, ChannelUserID: domain.ChannelUserID(uid)})
+		}
+	}
+	return list
+}
+
+func newBroadcastIntervals(opt BroadcastOption) map[domain.Channel]time.Duration {
+	intervals := make(map[domain.Channel]time.Duration, len(opt.Intervals))
+	for name, value := range opt.Intervals {
+		channel, err := domain.ParseChannel(name)
+		if err != nil {
+			panic(err)
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			panic(err)
+		}
+		intervals[channel] = interval
+	}
+	return intervals
+}
+
+func newRateLimiter(opt RateLimitOption, db *sqlx.DB) (domain.RateLimiter, *domain.RateLimitPolicy) {
+	policy := domain.NewRateLimitPolicy()
+	for name, co := range opt.Channels {
+		channel, err := domain.ParseChannel(name)
+		if err != nil {
+			panic(err)
+		}
+		refill, err := time.ParseDuration(co.Refill)
+		if err != nil {
+			panic(err)
+		}
+		policy.SetLimit(channel, domain.RateLimit{Burst: co.Burst, Refill: refill})
+	}
+
+	switch opt.Backend {
+	case "", "memory":
+		return infrastructure.NewMemoryRateLimiter(), policy
+	case "mysql":
+		return infrastructure.NewMySQLRateLimiter(db), policy
+	default:
+		panic(fmt.Sprintf("unknown rate limit backend %q", opt.Backend))
+	}
 }
@@ -43,6 +43,7 @@ func New(opt Option) {
 		panic(err)
 	}
 	shutdownTimeout = duration
+	resetWorkers()
 }
 
 func RootContext() context.Context {
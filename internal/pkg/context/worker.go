@@ -0,0 +1,51 @@
+package context
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	workers                sync.WaitGroup
+	workerCtx, stopWorkers = context.WithCancel(parent)
+	workerMu               sync.Mutex
+)
+
+// Go 启动受管理的后台任务，ctx在StopWorkers或RootContext取消时结束，任务应尽快退出
+func Go(fn func(ctx context.Context)) {
+	workerMu.Lock()
+	ctx := workerCtx
+	workerMu.Unlock()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		fn(ctx)
+	}()
+}
+
+// StopWorkers 通知所有后台任务退出，最多等待shutdown_timeout，返回是否全部退出
+func StopWorkers() bool {
+	workerMu.Lock()
+	stopWorkers()
+	workerMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(shutdownTimeout):
+		return false
+	}
+}
+
+func resetWorkers() {
+	workerMu.Lock()
+	defer workerMu.Unlock()
+	workerCtx, stopWorkers = context.WithCancel(parent)
+}
@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog 按语言保存消息模板，每种语言对应locales目录下的一个yml文件，文件名即语言代码
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]string
+	locales  []string
+}
+
+//go:embed locales/*.yml
+var embedded embed.FS
+
+// NewCatalog 加载内置的消息文件，fallback为缺省语言
+func NewCatalog(fallback string) (*Catalog, error) {
+	sub, err := fs.Sub(embedded, "locales")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub, fallback)
+}
+
+// Load 加载fsys根目录下的*.yml，缺省语言必须存在
+func Load(fsys fs.FS, fallback string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.yml")
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{fallback: fallback, messages: make(map[string]map[string]string)}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		messages := make(map[string]string)
+		if err = yaml.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		locale := strings.TrimSuffix(path.Base(file), ".yml")
+		c.messages[locale] = messages
+		c.locales = append(c.locales, locale)
+	}
+	if _, ok := c.messages[fallback]; !ok {
+		return nil, fmt.Errorf("fallback locale %q not found", fallback)
+	}
+	sort.Strings(c.locales)
+	return c, nil
+}
+
+// Locales 已加载的语言代码，按字母排序
+func (c *Catalog) Locales() []string {
+	return append([]string(nil), c.locales...)
+}
+
+func (c *Catalog) Fallback() string {
+	return c.fallback
+}
+
+// Keys 语言包含的全部消息key
+func (c *Catalog) Keys(locale string) []string {
+	keys := make([]string, 0, len(c.messages[locale]))
+	for key := range c.messages[locale] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Match 将en-US、zh_CN等语言标签匹配到已加载的语言，Accept-Language形式的列表仅取第一项，
+// 无法匹配时ok为false
+func (c *Catalog) Match(tag string) (string, bool) {
+	if i := strings.IndexAny(tag, ",;"); i >= 0 {
+		tag = tag[:i]
+	}
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	if _, ok := c.messages[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := c.messages[base]; ok {
+		return base, true
+	}
+	return "", false
+}
+
+// T 以args格式化消息，语言中缺少该key时使用缺省语言，仍缺少时返回key本身
+func (c *Catalog) T(locale, key string, args ...interface{}) string {
+	msg, ok := c.messages[locale][key]
+	if !ok {
+		if msg, ok = c.messages[c.fallback][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,47 @@
+package i18n_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jacexh/chatgpt-bot/internal/pkg/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedLocales(t *testing.T) {
+	c, err := i18n.NewCatalog("zh")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"en", "zh"}, c.Locales())
+
+	// 各语言的消息key必须一致，避免遗漏翻译
+	for _, locale := range c.Locales() {
+		assert.Equal(t, c.Keys("zh"), c.Keys(locale), locale)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	c, err := i18n.Load(fstest.MapFS{
+		"zh.yml": {Data: []byte("hello: 你好\ngreet: \"你好, %s\"\nonly_zh: 仅中文\n")},
+		"en.yml": {Data: []byte("hello: hello\ngreet: \"hello, %s\"\n")},
+	}, "zh")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "hello", c.T("en", "hello"))
+	assert.Equal(t, "hello, bob", c.T("en", "greet", "bob"))
+	assert.Equal(t, "仅中文", c.T("en", "only_zh"))
+	assert.Equal(t, "你好", c.T("fr", "hello"))
+	assert.Equal(t, "missing", c.T("en", "missing"))
+
+	for tag, want := range map[string]string{"en": "en", "en-US": "en", "zh_CN": "zh", "ZH-hans": "zh", "en-GB,en;q=0.9,zh;q=0.8": "en"} {
+		locale, ok := c.Match(tag)
+		assert.True(t, ok, tag)
+		assert.Equal(t, want, locale, tag)
+	}
+	_, ok := c.Match("fr")
+	assert.False(t, ok)
+	_, ok = c.Match("")
+	assert.False(t, ok)
+
+	_, err = i18n.Load(fstest.MapFS{"en.yml": {Data: []byte("hello: hello\n")}}, "zh")
+	assert.Error(t, err)
+}
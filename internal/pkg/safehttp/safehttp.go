@@ -0,0 +1,117 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Option AllowedHosts中的主机名(或IP)不做地址校验，用于访问明确信任的内网服务
+type Option struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	AllowedHosts []string
+}
+
+var (
+	ErrForbiddenAddress = errors.New("forbidden address")
+
+	// 运营商级NAT等未被net.IP方法覆盖的保留网段
+	reservedNets = mustParseCIDRs(
+		"0.0.0.0/8",
+		"100.64.0.0/10",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"240.0.0.0/4",
+		"64:ff9b::/96",
+		"2001:db8::/32",
+	)
+)
+
+// IsPublicIP 判断地址是否可以被安全访问，内网、回环、链路本地等地址均返回false
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient 创建拒绝访问非公网地址的http.Client，校验发生在DNS解析之后的建连阶段，可防御DNS rebinding
+func NewClient(opt Option) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+	trusted := &net.Dialer{Timeout: dialer.Timeout}
+	allowed := make(map[string]bool, len(opt.AllowedHosts))
+	for _, host := range opt.AllowedHosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	transport := &http.Transport{
+		Proxy: nil, // 代理会绕过地址校验
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// addr为解析前的主机名
+			if host, _, err := net.SplitHostPort(addr); err == nil && allowed[strings.ToLower(host)] {
+				return trusted.DialContext(ctx, network, addr)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	}
+	maxRedirects := opt.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = 3
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opt.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported scheme: %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for index, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[index] = n
+	}
+	return nets
+}
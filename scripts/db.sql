@@ -3,13 +3,16 @@ CREATE TABLE `chat` (
   `counts` tinyint NOT NULL DEFAULT '0',
   `current` text NOT NULL,
   `channel` tinyint NOT NULL,
-  `channel_user_id` varchar(45) NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
   `version` int NOT NULL DEFAULT '0',
   `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   `deleted` tinyint(1) NOT NULL DEFAULT '0',
+  `knowledge` tinyint(1) NOT NULL DEFAULT '0',
+  `thread` varchar(64) NOT NULL DEFAULT '',
   PRIMARY KEY (`id`),
-  UNIQUE KEY `id_UNIQUE` (`id`)
+  UNIQUE KEY `id_UNIQUE` (`id`),
+  KEY `idx_channel_user` (`channel`, `channel_user_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 
@@ -19,7 +22,157 @@ CREATE TABLE `conversation` (
   `prompt` text NOT NULL,
   `completion` text,
   `channel_message_id` varchar(48) NOT NULL,
+  `tool_calls` text,
+  `model` varchar(64) NOT NULL DEFAULT '',
+  `prompt_tokens` int NOT NULL DEFAULT '0',
+  `completion_tokens` int NOT NULL DEFAULT '0',
   `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `mtime` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`)
+  PRIMARY KEY (`id`),
+  KEY `idx_chat_id` (`chat_id`),
+  KEY `idx_ctime` (`ctime`)
 ) ENGINE=InnoDB AUTO_INCREMENT=11 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE `discarded_usage` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `chat_id` varchar(32) NOT NULL,
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `model` varchar(64) NOT NULL DEFAULT '',
+  `prompt_tokens` int NOT NULL DEFAULT '0',
+  `completion_tokens` int NOT NULL DEFAULT '0',
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_user` (`channel`, `channel_user_id`, `ctime`),
+  KEY `idx_ctime` (`ctime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE `knowledge_chunk` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `source` varchar(255) NOT NULL,
+  `heading` varchar(255) NOT NULL DEFAULT '',
+  `content` text NOT NULL,
+  `vector` mediumblob NOT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_source` (`source`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE `access_rule` (
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `rule` tinyint NOT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`channel`, `channel_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE `invitation` (
+  `code` varchar(32) NOT NULL,
+  `redeemed_channel` tinyint DEFAULT NULL,
+  `redeemed_user_id` varchar(255) DEFAULT NULL,
+  `redeemed_at` timestamp NULL DEFAULT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE `user_quota` (
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `daily_limit` bigint DEFAULT NULL,
+  `monthly_limit` bigint DEFAULT NULL,
+  `reset_at` timestamp NULL DEFAULT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`channel`, `channel_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `rate_limit_bucket` (
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `tokens` double NOT NULL DEFAULT '0',
+  `updated_at` timestamp(3) NOT NULL,
+  PRIMARY KEY (`channel`, `channel_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `broadcast` (
+  `id` varchar(32) NOT NULL,
+  `text` text NOT NULL,
+  `channel` tinyint NOT NULL DEFAULT '0',
+  `active_since` timestamp NULL DEFAULT NULL,
+  `status` tinyint NOT NULL,
+  `total` int NOT NULL DEFAULT '0',
+  `sent` int NOT NULL DEFAULT '0',
+  `failed` int NOT NULL DEFAULT '0',
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `broadcast_delivery` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `broadcast_id` varchar(32) NOT NULL,
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `status` tinyint NOT NULL DEFAULT '0',
+  `error` varchar(255) NOT NULL DEFAULT '',
+  `owner` varchar(32) NOT NULL DEFAULT '',
+  `lease_until` timestamp NULL DEFAULT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_broadcast_user` (`broadcast_id`, `channel`, `channel_user_id`),
+  KEY `idx_broadcast_status` (`broadcast_id`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `rest_callback_endpoint` (
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `client` varchar(32) NOT NULL,
+  `url` varchar(512) NOT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`channel`, `channel_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `rest_callback_delivery` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `chat_id` varchar(32) NOT NULL,
+  `event` varchar(16) NOT NULL,
+  `url` varchar(512) NOT NULL,
+  `status` tinyint NOT NULL DEFAULT '0',
+  `attempts` int NOT NULL DEFAULT '0',
+  `response_status` int NOT NULL DEFAULT '0',
+  `error` varchar(255) NOT NULL DEFAULT '',
+  `payload` text NOT NULL,
+  `owner` varchar(32) NOT NULL DEFAULT '',
+  `lease_until` timestamp NULL DEFAULT NULL,
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_user` (`channel`, `channel_user_id`),
+  KEY `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `sync_token` (
+  `sync_key` varchar(255) NOT NULL,
+  `token` varchar(1024) NOT NULL,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`sync_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `user_locale` (
+  `channel` tinyint NOT NULL,
+  `channel_user_id` varchar(255) NOT NULL,
+  `locale` varchar(16) NOT NULL,
+  `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`channel`, `channel_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;